@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"mtt-indexer/config"
+	"mtt-indexer/db"
+	"mtt-indexer/logger"
+	"mtt-indexer/service"
+	"os"
+	"strings"
+)
+
+// runBackfill re-indexes a fixed height range through the registered parsers and exits.
+// The stored chain tip is left untouched so the range can be re-run to repair gaps.
+func runBackfill(chainService *service.ChainService, args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	from := fs.Int64("from", 0, "First height to index")
+	to := fs.Int64("to", 0, "Last height to index (inclusive)")
+	_ = fs.Parse(args)
+
+	if *from <= 0 || *to < *from {
+		logger.Logger.Fatalf("Invalid backfill range --from %d --to %d", *from, *to)
+	}
+
+	logger.Logger.Infof("Backfilling blocks %d to %d", *from, *to)
+	if err := chainService.Backfill(*from, *to); err != nil {
+		logger.Logger.Fatalf("Backfill failed: %v", err)
+	}
+	logger.Logger.Infof("Backfill of blocks %d to %d complete", *from, *to)
+}
+
+// runRollback removes everything indexed for blocks above --to-height and exits. The
+// next start resumes syncing from --to-height + 1.
+func runRollback(chainService *service.ChainService, args []string) {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	toHeight := fs.Int64("to-height", -1, "Height to roll the index back to")
+	_ = fs.Parse(args)
+
+	if *toHeight < 0 {
+		logger.Logger.Fatalf("Invalid rollback height --to-height %d", *toHeight)
+	}
+
+	logger.Logger.Infof("Rolling back to height %d", *toHeight)
+	if err := chainService.Rollback(*toHeight); err != nil {
+		logger.Logger.Fatalf("Rollback failed: %v", err)
+	}
+	logger.Logger.Infof("Rollback to height %d complete", *toHeight)
+}
+
+// runReindex re-indexes from stored raw blocks after parsers were fixed and exits. The
+// index is rolled back to before the first block the parsers have anything in and
+// replayed from there up to the indexed height.
+func runReindex(chainService *service.ChainService, args []string) {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	parserList := fs.String("parsers", "", "Comma separated identifiers of the fixed parsers")
+	from := fs.Int64("from", 0, "First height to look for blocks of the parsers in")
+	_ = fs.Parse(args)
+
+	if *parserList == "" {
+		logger.Logger.Fatalf("Missing --parsers")
+	}
+	if *from <= 0 {
+		logger.Logger.Fatalf("Invalid --from %d, it must be the first height with a stored raw block or later", *from)
+	}
+
+	if err := chainService.Reindex(strings.Split(*parserList, ","), *from); err != nil {
+		logger.Logger.Fatalf("Reindex failed: %v", err)
+	}
+	logger.Logger.Infof("Reindex complete")
+}
+
+// runVerify recomputes the delegation balances and claimed totals from the stored history
+// and reports every stored value that differs, exiting non-zero when any does. With --fix
+// the differing records are rewritten, with --on-chain the balances are also checked
+// against the delegations on chain at the indexed height.
+func runVerify(chainService *service.ChainService, args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "Rewrite records that differ from the history")
+	onChain := fs.Bool("on-chain", false, "Compare the balances with the delegations on chain")
+	_ = fs.Parse(args)
+
+	report, err := chainService.Verify(*fix, *onChain)
+	if err != nil {
+		logger.Logger.Fatalf("Verify failed: %v", err)
+	}
+
+	for _, unresolved := range report.Unresolved {
+		logger.Logger.Warnf("Not replayed: %s", unresolved)
+	}
+	for _, m := range report.Mismatches {
+		logger.Logger.Warnf("%s validator %s: stored %q, history gives %q", m.Record, m.Validator, m.Actual, m.Expected)
+	}
+	for _, m := range report.ChainMismatches {
+		logger.Logger.Warnf("Delegation of %s to %s: history gives %s, chain has %s", m.Record, m.Validator, m.Actual, m.Expected)
+	}
+	logger.Logger.Infof("Verified %d delegators and %d validators at height %d: %d mismatches, %d on chain, %d records not replayed, %d records fixed",
+		report.Delegators, report.Validators, report.Height, len(report.Mismatches), len(report.ChainMismatches), len(report.Unresolved), report.Fixed)
+
+	if (len(report.Mismatches) > 0 && report.Fixed == 0) || len(report.ChainMismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+// runMigrate applies pending storage migrations and exits. With --dry-run it only reports
+// the LevelDB migrations that would run and how many keys each would change.
+func runMigrate(cfg *config.Conf, args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Report pending migrations without applying them")
+	_ = fs.Parse(args)
+
+	if cfg.Storage != "" && cfg.Storage != db.BackendLevelDB {
+		if *dryRun {
+			logger.Logger.Fatalf("--dry-run is only supported for the %s backend", db.BackendLevelDB)
+		}
+		store, err := db.NewStore(cfg.Storage, ldbConfig(cfg), cfg.PostgresDsn)
+		if err != nil {
+			logger.Logger.Fatalf("Failed to open storage. Err: %v", err)
+		}
+		defer store.Close()
+		if err := store.Migrate(); err != nil {
+			logger.Logger.Fatalf("Migration failed: %v", err)
+		}
+		logger.Logger.Infof("Storage schema is up to date")
+		return
+	}
+
+	ldb := db.OpenLdb(ldbConfig(cfg))
+	defer ldb.Close()
+
+	version, err := ldb.SchemaVersion()
+	if err != nil {
+		logger.Logger.Fatalf("Failed to read schema version: %v", err)
+	}
+	logger.Logger.Infof("Schema version %d, latest %d", version, db.LatestSchemaVersion())
+
+	reports, err := ldb.RunMigrations(*dryRun)
+	if *dryRun {
+		for _, report := range reports {
+			logger.Logger.Infof("Would apply migration %d (%s), %d keys would change", report.Version, report.Description, report.Changes)
+		}
+	}
+	if err != nil {
+		logger.Logger.Fatalf("Migration failed: %v", err)
+	}
+	if len(reports) == 0 {
+		logger.Logger.Infof("No pending migrations")
+	}
+}
+
+// runRecode rewrites the records of the LevelDB database in the configured encoding and
+// exits. The indexer must not be running.
+func runRecode(cfg *config.Conf) {
+	if cfg.Storage != "" && cfg.Storage != db.BackendLevelDB {
+		logger.Logger.Fatalf("recode is only supported for the %s backend", db.BackendLevelDB)
+	}
+
+	ldb := db.NewLdb(ldbConfig(cfg))
+	defer ldb.Close()
+
+	changes, err := ldb.Recode()
+	if err != nil {
+		logger.Logger.Fatalf("Recode failed after %d records: %v", changes, err)
+	}
+	logger.Logger.Infof("Recoded %d records", changes)
+}
+
+// runBackup writes a backup archive of the LevelDB database and exits. The database is
+// opened read-only, so this also works while the indexer is running.
+func runBackup(cfg *config.Conf, args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("out", "", "Backup archive to write")
+	_ = fs.Parse(args)
+
+	if *out == "" {
+		logger.Logger.Fatalf("Missing --out")
+	}
+	if cfg.Storage != "" && cfg.Storage != db.BackendLevelDB {
+		logger.Logger.Fatalf("backup is only supported for the %s backend", db.BackendLevelDB)
+	}
+
+	ldbCfg := ldbConfig(cfg)
+	ldbCfg.ReadOnly = true
+	ldb := db.OpenLdb(ldbCfg)
+	defer ldb.Close()
+
+	manifest, err := db.BackupToFile(ldb, *out, chainName)
+	if err != nil {
+		logger.Logger.Fatalf("Backup failed: %v", err)
+	}
+	logger.Logger.Infof("Backed up %s at height %d (schema version %d) to %s", manifest.ChainName, manifest.Height, manifest.SchemaVersion, *out)
+}
+
+// runRestore recreates a LevelDB database from a backup archive and exits.
+func runRestore(cfg *config.Conf, args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := fs.String("in", "", "Backup archive to restore")
+	tailFix := fs.String("db-tail-fix", cfg.DbTailFix, "db_tail_fix of the database to create")
+	path := fs.String("db-path", cfg.DbPath, "Directory of the database to create, overrides --db-tail-fix")
+	_ = fs.Parse(args)
+
+	if *in == "" {
+		logger.Logger.Fatalf("Missing --in")
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		logger.Logger.Fatalf("Failed to open backup: %v", err)
+	}
+	defer file.Close()
+
+	ldbCfg := ldbConfig(cfg)
+	ldbCfg.Path = *path
+	ldbCfg.TailFix = *tailFix
+	ldbCfg.ReadOnly = false
+	manifest, err := db.Restore(file, ldbCfg)
+	if err != nil {
+		logger.Logger.Fatalf("Restore failed: %v", err)
+	}
+	logger.Logger.Infof("Restored %s at height %d (schema version %d) into %s", manifest.ChainName, manifest.Height, manifest.SchemaVersion, ldbCfg.Dir())
+}
@@ -5,5 +5,45 @@ var Cfg Conf
 type Conf struct {
 	Port      int    `yaml:"port"`
 	DbTailFix string `yaml:"db_tail_fix"`
-	Rpc       string `yaml:"rpc"`
+	// DbPath is the LevelDB directory, $HOME/.mtt_index_<db_tail_fix> when empty
+	DbPath  string  `yaml:"db_path"`
+	Leveldb Leveldb `yaml:"leveldb"`
+	// ReadOnly only serves the API from a database another process is indexing into
+	ReadOnly     bool   `yaml:"read_only"`
+	Rpc          string `yaml:"rpc"`
+	FetchWorkers int    `yaml:"fetch_workers"`
+	FetchWindow  int    `yaml:"fetch_window"`
+	StartHeight  int64  `yaml:"start_height"`
+	SkipPruned   bool   `yaml:"skip_pruned"`
+	// StoreRawBlocks keeps every fetched block so it can be re-indexed without the RPC node
+	StoreRawBlocks bool `yaml:"store_raw_blocks"`
+	// FailedBlockMaxAttempts is how often a failed block is retried before it waits for a
+	// requeue over /admin/failedBlocks/requeue, 20 when zero
+	FailedBlockMaxAttempts int `yaml:"failed_block_max_attempts"`
+	// UndoRetention is how many blocks below the indexed height keep their undo logs, which
+	// is as far as rollback can go, 10000 when zero
+	UndoRetention int64 `yaml:"undo_retention"`
+	// Storage is the storage backend, leveldb (default) or postgres
+	Storage     string `yaml:"storage"`
+	PostgresDsn string `yaml:"postgres_dsn"`
+	// AdminToken enables the /admin endpoints, which require it as a bearer token
+	AdminToken string `yaml:"admin_token"`
+	// BackupDir is where /admin/backup writes its archives
+	BackupDir string `yaml:"backup_dir"`
+}
+
+// Leveldb tunes the LevelDB engine, zero values keep the goleveldb defaults.
+type Leveldb struct {
+	BlockCacheMB  int `yaml:"block_cache_mb"`
+	WriteBufferMB int `yaml:"write_buffer_mb"`
+	// Compression is snappy (default) or none
+	Compression     string `yaml:"compression"`
+	BloomFilterBits int    `yaml:"bloom_filter_bits"`
+	OpenFilesLimit  int    `yaml:"open_files_limit"`
+	// Encoding is how records are written, json (default) or binary. Binary is opt-in:
+	// after switching, run recode to rewrite the existing records. Builds without the
+	// binary codec cannot read the database any more.
+	Encoding string `yaml:"encoding"`
+	// RefreshSeconds is how often a read-only process reopens the database to see new blocks
+	RefreshSeconds int `yaml:"refresh_seconds"`
 }
@@ -1,12 +1,18 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"mtt-indexer/db"
 	"mtt-indexer/logger"
 	"mtt-indexer/service"
+	"mtt-indexer/types"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
+	"time"
 )
 
 const (
@@ -19,6 +25,8 @@ type Response struct {
 	Msg   string      `json:"msg"`
 	Data  interface{} `json:"data"`
 	Total int         `json:"total"`
+	// Cursor is passed as the cursor query parameter to get the next page, empty on the last page
+	Cursor string `json:"cursor,omitempty"`
 }
 
 type Endpoint func(c *gin.Context)
@@ -72,6 +80,7 @@ type History struct {
 	TxHash         string `json:"tx_hash"`
 	DelegationType uint8  `json:"delegation_type"` // true: add, false: rm
 	DelegationTime int64  `json:"delegation_time"`
+	Grantee        string `json:"grantee"` // authz grantee that executed the message, empty when signed by the delegator
 }
 
 func DelegatorHistoryEndpoint(s service.IService) gin.HandlerFunc {
@@ -86,10 +95,7 @@ func DelegatorHistoryEndpoint(s service.IService) gin.HandlerFunc {
 			c.JSON(http.StatusOK, resp)
 			return
 		}
-		limitStr, _ := c.GetQuery("limit")
-		limit, _ := strconv.Atoi(limitStr)
-		offsetStr, _ := c.GetQuery("offset")
-		offset, _ := strconv.Atoi(offsetStr)
+		params := parseCursorParams(c)
 
 		ascStr, _ := c.GetQuery("asc")
 		asc := false
@@ -97,7 +103,10 @@ func DelegatorHistoryEndpoint(s service.IService) gin.HandlerFunc {
 			asc = true
 		}
 
-		records, total, err := s.GetDelegatorHistory(delegator, validLimit(limit, 20, 100), validOffset(offset), asc)
+		records, next, total, err := s.GetDelegatorHistory(delegator, params.limit, params.offset, params.cursor, asc)
+		if writeInvalidCursor(c, err) {
+			return
+		}
 		if err != nil {
 			logger.Logger.Error(fmt.Sprintf("GetRecord endpoint error : %s", err))
 			return
@@ -114,14 +123,16 @@ func DelegatorHistoryEndpoint(s service.IService) gin.HandlerFunc {
 				TxHash:         record.TxHash,
 				DelegationType: uint8(record.DelegationType),
 				DelegationTime: record.DelegationTime.Unix(),
+				Grantee:        record.Grantee,
 			})
 		}
 
 		resp := &Response{
-			Code:  ResponseCodeOk,
-			Msg:   "",
-			Data:  result,
-			Total: total,
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   result,
+			Total:  total,
+			Cursor: next,
 		}
 		c.JSON(http.StatusOK, resp)
 		return
@@ -140,10 +151,7 @@ func ValidatorHistoryEndpoint(s service.IService) gin.HandlerFunc {
 			c.JSON(http.StatusOK, resp)
 			return
 		}
-		limitStr, _ := c.GetQuery("limit")
-		limit, _ := strconv.Atoi(limitStr)
-		offsetStr, _ := c.GetQuery("offset")
-		offset, _ := strconv.Atoi(offsetStr)
+		params := parseCursorParams(c)
 
 		ascStr, _ := c.GetQuery("asc")
 		asc := false
@@ -151,7 +159,10 @@ func ValidatorHistoryEndpoint(s service.IService) gin.HandlerFunc {
 			asc = true
 		}
 
-		records, total, err := s.GetValidatorHistory(validator, validLimit(limit, 20, 100), validOffset(offset), asc)
+		records, next, total, err := s.GetValidatorHistory(validator, params.limit, params.offset, params.cursor, asc)
+		if writeInvalidCursor(c, err) {
+			return
+		}
 		if err != nil {
 			logger.Logger.Errorf("GetRecord endpoint error : %s", err)
 			return
@@ -168,14 +179,16 @@ func ValidatorHistoryEndpoint(s service.IService) gin.HandlerFunc {
 				TxHash:         record.TxHash,
 				DelegationType: uint8(record.DelegationType),
 				DelegationTime: record.DelegationTime.Unix(),
+				Grantee:        record.Grantee,
 			})
 		}
 
 		resp := &Response{
-			Code:  ResponseCodeOk,
-			Msg:   "",
-			Data:  result,
-			Total: total,
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   result,
+			Total:  total,
+			Cursor: next,
 		}
 		c.JSON(http.StatusOK, resp)
 		return
@@ -193,12 +206,12 @@ func RewardHistoryEndpoint(s service.IService) gin.HandlerFunc {
 		if !exist {
 			return
 		}
-		limitStr, _ := c.GetQuery("limit")
-		limit, _ := strconv.Atoi(limitStr)
-		offsetStr, _ := c.GetQuery("offset")
-		offset, _ := strconv.Atoi(offsetStr)
+		params := parseCursorParams(c)
 
-		records, total, err := s.GetRewardHistory(validatorStr, validLimit(limit, 20, 100), validOffset(offset))
+		records, next, total, err := s.GetRewardHistory(validatorStr, params.limit, params.offset, params.cursor)
+		if writeInvalidCursor(c, err) {
+			return
+		}
 		if err != nil {
 			logger.Logger.Errorf("GetRecord endpoint error : %s", err)
 			return
@@ -214,10 +227,11 @@ func RewardHistoryEndpoint(s service.IService) gin.HandlerFunc {
 		}
 
 		resp := &Response{
-			Code:  ResponseCodeOk,
-			Msg:   "",
-			Data:  result,
-			Total: total,
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   result,
+			Total:  total,
+			Cursor: next,
 		}
 		c.JSON(http.StatusOK, resp)
 		return
@@ -225,9 +239,16 @@ func RewardHistoryEndpoint(s service.IService) gin.HandlerFunc {
 }
 
 type Commission struct {
-	Validator  string  `json:"validator"`
-	Commission float64 `json:"commission"`
-	Time       int64   `json:"time"`
+	Validator     string  `json:"validator"`
+	Commission    float64 `json:"commission"`
+	Time          int64   `json:"time"`
+	Type          uint8   `json:"type"` // 0: create, 1: edit
+	Rate          string  `json:"rate"`
+	PreviousRate  string  `json:"previous_rate"`
+	MaxRate       string  `json:"max_rate"`
+	MaxChangeRate string  `json:"max_change_rate"`
+	Height        int64   `json:"height"`
+	TxHash        string  `json:"tx_hash"`
 }
 
 func CommissionRecordEndpoint(s service.IService) gin.HandlerFunc {
@@ -242,12 +263,12 @@ func CommissionRecordEndpoint(s service.IService) gin.HandlerFunc {
 			c.JSON(http.StatusOK, resp)
 			return
 		}
-		limitStr, _ := c.GetQuery("limit")
-		limit, _ := strconv.Atoi(limitStr)
-		offsetStr, _ := c.GetQuery("offset")
-		offset, _ := strconv.Atoi(offsetStr)
+		params := parseCursorParams(c)
 
-		records, total, err := s.GetCommissionRecord(validator, validLimit(limit, 20, 100), validOffset(offset))
+		records, next, total, err := s.GetCommissionRecord(validator, params.limit, params.offset, params.cursor)
+		if writeInvalidCursor(c, err) {
+			return
+		}
 		if err != nil {
 			logger.Logger.Errorf("GetRecord endpoint error : %s", err)
 			return
@@ -257,23 +278,754 @@ func CommissionRecordEndpoint(s service.IService) gin.HandlerFunc {
 
 		for _, record := range records {
 			result = append(result, &Commission{
-				Validator:  record.Validator,
-				Commission: record.Commission,
-				Time:       record.Time.Unix(),
+				Validator:     record.Validator,
+				Commission:    record.Commission,
+				Time:          record.Time.Unix(),
+				Type:          uint8(record.Type),
+				Rate:          record.Rate,
+				PreviousRate:  record.PreviousRate,
+				MaxRate:       record.MaxRate,
+				MaxChangeRate: record.MaxChangeRate,
+				Height:        record.Height,
+				TxHash:        record.TxHash,
+			})
+		}
+
+		resp := &Response{
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   result,
+			Total:  total,
+			Cursor: next,
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+type Coin struct {
+	Denom  string `json:"denom"`
+	Amount string `json:"amount"`
+}
+
+type CommissionWithdrawal struct {
+	Validator string `json:"validator"`
+	Coins     []Coin `json:"coins"`
+	Height    int64  `json:"height"`
+	TxHash    string `json:"tx_hash"`
+	Time      int64  `json:"time"`
+}
+
+func CommissionWithdrawalsEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		validator, exist := c.GetQuery("validator")
+		if !exist {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		params := parseCursorParams(c)
+
+		records, next, total, err := s.GetCommissionWithdrawals(validator, params.limit, params.offset, params.cursor)
+		if writeInvalidCursor(c, err) {
+			return
+		}
+		if err != nil {
+			logger.Logger.Errorf("GetCommissionWithdrawals endpoint error : %s", err)
+			return
+		}
+
+		result := []*CommissionWithdrawal{}
+
+		for _, record := range records {
+			result = append(result, &CommissionWithdrawal{
+				Validator: record.Validator,
+				Coins:     toCoins(record.Coins),
+				Height:    record.Height,
+				TxHash:    record.TxHash,
+				Time:      record.Time.Unix(),
+			})
+		}
+
+		resp := &Response{
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   result,
+			Total:  total,
+			Cursor: next,
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+type Transfer struct {
+	Address      string `json:"address"`
+	Counterparty string `json:"counterparty"`
+	Direction    uint8  `json:"direction"` // 0: in, 1: out
+	Amount       string `json:"amount"`
+	Denom        string `json:"denom"`
+	MessageType  string `json:"message_type"`
+	Height       int64  `json:"height"`
+	TxHash       string `json:"tx_hash"`
+	Time         int64  `json:"time"`
+}
+
+func TransfersEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address, exist := c.GetQuery("address")
+		if !exist {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		params := parseCursorParams(c)
+
+		records, next, total, err := s.GetTransfers(address, params.limit, params.offset, params.cursor)
+		if writeInvalidCursor(c, err) {
+			return
+		}
+		if err != nil {
+			logger.Logger.Errorf("GetTransfers endpoint error : %s", err)
+			return
+		}
+
+		result := []*Transfer{}
+
+		for _, record := range records {
+			result = append(result, &Transfer{
+				Address:      record.Address,
+				Counterparty: record.Counterparty,
+				Direction:    uint8(record.Direction),
+				Amount:       record.Amount,
+				Denom:        record.Denom,
+				MessageType:  record.MessageType,
+				Height:       record.Height,
+				TxHash:       record.TxHash,
+				Time:         record.Time.Unix(),
 			})
 		}
 
+		resp := &Response{
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   result,
+			Total:  total,
+			Cursor: next,
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+type Authorization struct {
+	Granter       string `json:"granter"`
+	Grantee       string `json:"grantee"`
+	Type          uint8  `json:"type"` // 0: grant, 1: revoke
+	MsgTypeURL    string `json:"msg_type_url"`
+	Authorization string `json:"authorization"`
+	Expiration    int64  `json:"expiration"`
+	Height        int64  `json:"height"`
+	TxHash        string `json:"tx_hash"`
+	Time          int64  `json:"time"`
+}
+
+func AuthorizationsEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granter, exist := c.GetQuery("granter")
+		if !exist {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		params := parseCursorParams(c)
+
+		records, next, total, err := s.GetAuthorizations(granter, params.limit, params.offset, params.cursor)
+		if writeInvalidCursor(c, err) {
+			return
+		}
+		if err != nil {
+			logger.Logger.Errorf("GetAuthorizations endpoint error : %s", err)
+			return
+		}
+
+		result := []*Authorization{}
+
+		for _, record := range records {
+			result = append(result, &Authorization{
+				Granter:       record.Granter,
+				Grantee:       record.Grantee,
+				Type:          uint8(record.Type),
+				MsgTypeURL:    record.MsgTypeURL,
+				Authorization: record.Authorization,
+				Expiration:    unixTime(record.Expiration),
+				Height:        record.Height,
+				TxHash:        record.TxHash,
+				Time:          record.Time.Unix(),
+			})
+		}
+
+		resp := &Response{
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   result,
+			Total:  total,
+			Cursor: next,
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+type Proposal struct {
+	ProposalID        uint64   `json:"proposal_id"`
+	Title             string   `json:"title"`
+	Summary           string   `json:"summary"`
+	Metadata          string   `json:"metadata"`
+	Proposer          string   `json:"proposer"`
+	Messages          []string `json:"messages"`
+	Status            uint8    `json:"status"` // 0: deposit period, 1: voting period, 2: passed, 3: rejected, 4: failed, 5: dropped
+	TotalDeposit      []Coin   `json:"total_deposit"`
+	SubmitHeight      int64    `json:"submit_height"`
+	SubmitTime        int64    `json:"submit_time"`
+	SubmitTxHash      string   `json:"submit_tx_hash"`
+	VotingStartHeight int64    `json:"voting_start_height"`
+	VotingStartTime   int64    `json:"voting_start_time"`
+	EndHeight         int64    `json:"end_height"`
+	EndTime           int64    `json:"end_time"`
+}
+
+type ProposalEvent struct {
+	Type    uint8  `json:"type"` // 0: submit, 1: deposit, 2: voting start, 3: passed, 4: rejected, 5: failed, 6: dropped
+	Address string `json:"address"`
+	Coins   []Coin `json:"coins"`
+	Height  int64  `json:"height"`
+	TxHash  string `json:"tx_hash"`
+	Time    int64  `json:"time"`
+}
+
+type ProposalDetail struct {
+	Proposal *Proposal        `json:"proposal"`
+	Timeline []*ProposalEvent `json:"timeline"`
+}
+
+type VoteOption struct {
+	Option string `json:"option"`
+	Weight string `json:"weight"`
+}
+
+type Vote struct {
+	ProposalID uint64       `json:"proposal_id"`
+	Voter      string       `json:"voter"`
+	Validator  string       `json:"validator"`
+	Options    []VoteOption `json:"options"`
+	Height     int64        `json:"height"`
+	TxHash     string       `json:"tx_hash"`
+	Time       int64        `json:"time"`
+}
+
+func ProposalsEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		records, err := s.GetProposals()
+		if err != nil {
+			logger.Logger.Errorf("GetProposals endpoint error : %s", err)
+			return
+		}
+
+		result := []*Proposal{}
+
+		for _, record := range records {
+			result = append(result, toProposal(record))
+		}
+
 		resp := &Response{
 			Code:  ResponseCodeOk,
 			Msg:   "",
 			Data:  result,
-			Total: total,
+			Total: len(result),
 		}
 		c.JSON(http.StatusOK, resp)
 		return
 	}
 }
 
+// ProposalEndpoint returns a proposal with a page of its timeline.
+func ProposalEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr, _ := c.GetQuery("id")
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+
+		record, err := s.GetProposal(id)
+		if err != nil {
+			logger.Logger.Errorf("GetProposal endpoint error : %s", err)
+			return
+		}
+		if record == nil {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  fmt.Sprintf("proposal %d not found", id),
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+
+		params := parseCursorParams(c)
+		events, next, total, err := s.GetProposalTimeline(id, params.limit, params.offset, params.cursor)
+		if writeInvalidCursor(c, err) {
+			return
+		}
+		if err != nil {
+			logger.Logger.Errorf("GetProposalTimeline endpoint error : %s", err)
+			return
+		}
+
+		result := ProposalDetail{
+			Proposal: toProposal(record),
+			Timeline: []*ProposalEvent{},
+		}
+		for _, event := range events {
+			result.Timeline = append(result.Timeline, &ProposalEvent{
+				Type:    uint8(event.Type),
+				Address: event.Address,
+				Coins:   toCoins(event.Coins),
+				Height:  event.Height,
+				TxHash:  event.TxHash,
+				Time:    event.Time.Unix(),
+			})
+		}
+
+		resp := &Response{
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   result,
+			Total:  total,
+			Cursor: next,
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+// ProposalVotesEndpoint lists the votes on a proposal, with validators=true only the votes
+// of validators.
+func ProposalVotesEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr, _ := c.GetQuery("id")
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		validatorsStr, _ := c.GetQuery("validators")
+		validators, _ := strconv.ParseBool(validatorsStr)
+		params := parseCursorParams(c)
+
+		records, next, total, err := s.GetProposalVotes(id, validators, params.limit, params.offset, params.cursor)
+		if writeInvalidCursor(c, err) {
+			return
+		}
+		if err != nil {
+			logger.Logger.Errorf("GetProposalVotes endpoint error : %s", err)
+			return
+		}
+
+		resp := &Response{
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   toVotes(records),
+			Total:  total,
+			Cursor: next,
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+// VotesEndpoint lists the votes of an account, or of a validator by its operator address.
+func VotesEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address, exist := c.GetQuery("address")
+		if !exist {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		params := parseCursorParams(c)
+
+		records, next, total, err := s.GetVotes(address, params.limit, params.offset, params.cursor)
+		if writeInvalidCursor(c, err) {
+			return
+		}
+		if err != nil {
+			logger.Logger.Errorf("GetVotes endpoint error : %s", err)
+			return
+		}
+
+		resp := &Response{
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   toVotes(records),
+			Total:  total,
+			Cursor: next,
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+func toProposal(record *types.Proposal) *Proposal {
+	messages := record.Messages
+	if messages == nil {
+		messages = []string{}
+	}
+	return &Proposal{
+		ProposalID:        record.ProposalID,
+		Title:             record.Title,
+		Summary:           record.Summary,
+		Metadata:          record.Metadata,
+		Proposer:          record.Proposer,
+		Messages:          messages,
+		Status:            uint8(record.Status),
+		TotalDeposit:      toCoins(record.TotalDeposit),
+		SubmitHeight:      record.SubmitHeight,
+		SubmitTime:        unixTime(record.SubmitTime),
+		SubmitTxHash:      record.SubmitTxHash,
+		VotingStartHeight: record.VotingStartHeight,
+		VotingStartTime:   unixTime(record.VotingStartTime),
+		EndHeight:         record.EndHeight,
+		EndTime:           unixTime(record.EndTime),
+	}
+}
+
+func toVotes(records []*types.ProposalVote) []*Vote {
+	result := []*Vote{}
+	for _, record := range records {
+		options := []VoteOption{}
+		for _, option := range record.Options {
+			options = append(options, VoteOption{Option: option.Option, Weight: option.Weight})
+		}
+		result = append(result, &Vote{
+			ProposalID: record.ProposalID,
+			Voter:      record.Voter,
+			Validator:  record.Validator,
+			Options:    options,
+			Height:     record.Height,
+			TxHash:     record.TxHash,
+			Time:       record.Time.Unix(),
+		})
+	}
+	return result
+}
+
+func toCoins(coins []types.Coin) []Coin {
+	result := []Coin{}
+	for _, coin := range coins {
+		result = append(result, Coin{Denom: coin.Denom, Amount: coin.Amount})
+	}
+	return result
+}
+
+// unixTime returns 0 for unset times, like proposal stages that were not reached.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+type Unbonding struct {
+	Delegator      string `json:"delegator"`
+	Validator      string `json:"validator"`
+	CreationHeight int64  `json:"creation_height"`
+	CompletionTime int64  `json:"completion_time"`
+	InitialBalance string `json:"initial_balance"`
+	Balance        string `json:"balance"`
+	Denom          string `json:"denom"`
+	TxHash         string `json:"tx_hash"`
+}
+
+func UnbondingsEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var records []*types.UnbondingEntry
+		var err error
+		if delegator, exist := c.GetQuery("delegator"); exist {
+			records, err = s.GetDelegatorUnbondings(delegator)
+		} else if validator, exist := c.GetQuery("validator"); exist {
+			records, err = s.GetValidatorUnbondings(validator)
+		} else {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		if err != nil {
+			logger.Logger.Errorf("GetUnbondings error : %s", err)
+			return
+		}
+
+		result := []*Unbonding{}
+
+		for _, record := range records {
+			result = append(result, &Unbonding{
+				Delegator:      record.Delegator,
+				Validator:      record.Validator,
+				CreationHeight: record.CreationHeight,
+				CompletionTime: record.CompletionTime.Unix(),
+				InitialBalance: record.InitialBalance,
+				Balance:        record.Balance,
+				Denom:          record.Denom,
+				TxHash:         record.TxHash,
+			})
+		}
+
+		resp := &Response{
+			Code:  ResponseCodeOk,
+			Msg:   "",
+			Data:  result,
+			Total: len(result),
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+type Incident struct {
+	Validator    string `json:"validator"`
+	ConsAddress  string `json:"cons_address"`
+	Type         uint8  `json:"type"` // 0: slash, 1: jail, 2: unjail
+	Reason       string `json:"reason"`
+	SlashedPower string `json:"slashed_power"`
+	BurnedCoins  string `json:"burned_coins"`
+	Jailed       bool   `json:"jailed"`
+	JailedUntil  int64  `json:"jailed_until"`
+	Height       int64  `json:"height"`
+	TxHash       string `json:"tx_hash"`
+	Time         int64  `json:"time"`
+}
+
+func ValidatorIncidentsEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		validator, exist := c.GetQuery("validator")
+		if !exist {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		params := parseCursorParams(c)
+
+		records, next, total, err := s.GetValidatorIncidents(validator, params.limit, params.offset, params.cursor)
+		if writeInvalidCursor(c, err) {
+			return
+		}
+		if err != nil {
+			logger.Logger.Errorf("GetValidatorIncidents error : %s", err)
+			return
+		}
+
+		result := []*Incident{}
+
+		for _, record := range records {
+			jailedUntil := int64(0)
+			if record.Jailed {
+				jailedUntil = record.JailedUntil.Unix()
+			}
+			result = append(result, &Incident{
+				Validator:    record.Validator,
+				ConsAddress:  record.ConsAddress,
+				Type:         uint8(record.Type),
+				Reason:       record.Reason,
+				SlashedPower: record.SlashedPower,
+				BurnedCoins:  record.BurnedCoins,
+				Jailed:       record.Jailed,
+				JailedUntil:  jailedUntil,
+				Height:       record.Height,
+				TxHash:       record.TxHash,
+				Time:         record.Time.Unix(),
+			})
+		}
+
+		resp := &Response{
+			Code:   ResponseCodeOk,
+			Msg:    "",
+			Data:   result,
+			Total:  total,
+			Cursor: next,
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+type Liveness struct {
+	Validator        string `json:"validator"`
+	MissedBlocks     int64  `json:"missed_blocks"`
+	LastMissedHeight int64  `json:"last_missed_height"`
+	LastMissedTime   int64  `json:"last_missed_time"`
+}
+
+func ValidatorLivenessEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		validator, exist := c.GetQuery("validator")
+		if !exist {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+
+		record, err := s.GetValidatorLiveness(validator)
+		if err != nil {
+			logger.Logger.Errorf("GetValidatorLiveness error : %s", err)
+			return
+		}
+
+		lastMissedTime := int64(0)
+		if record.LastMissedHeight != 0 {
+			lastMissedTime = record.LastMissedTime.Unix()
+		}
+		resp := &Response{
+			Code: ResponseCodeOk,
+			Msg:  "",
+			Data: Liveness{
+				Validator:        validator,
+				MissedBlocks:     record.MissedBlocks,
+				LastMissedHeight: record.LastMissedHeight,
+				LastMissedTime:   lastMissedTime,
+			},
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+type FailedBlock struct {
+	Height    int64  `json:"height"`
+	Code      int    `json:"code"`
+	Error     string `json:"error"`
+	Attempts  int    `json:"attempts"`
+	FailedAt  int64  `json:"failed_at"`
+	NextRetry int64  `json:"next_retry"`
+}
+
+func toFailedBlock(record *types.FailedBlock) *FailedBlock {
+	return &FailedBlock{
+		Height:    record.Height,
+		Code:      record.Code,
+		Error:     record.Error,
+		Attempts:  record.Attempts,
+		FailedAt:  record.FailedAt.Unix(),
+		NextRetry: record.NextRetry.Unix(),
+	}
+}
+
+func FailedBlocksEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		records, err := s.GetFailedBlocks()
+		if err != nil {
+			logger.Logger.Errorf("GetFailedBlocks error : %s", err)
+			return
+		}
+
+		result := []*FailedBlock{}
+
+		for _, record := range records {
+			result = append(result, toFailedBlock(record))
+		}
+
+		resp := &Response{
+			Code:  ResponseCodeOk,
+			Msg:   "",
+			Data:  result,
+			Total: len(result),
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+}
+
+// RequeueFailedBlockEndpoint makes the retrier try a queued block again from its first
+// attempt, for blocks that reached the max attempts after the cause was fixed.
+func RequeueFailedBlockEndpoint(s service.IService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		heightStr, _ := c.GetQuery("height")
+		height, err := strconv.ParseInt(heightStr, 10, 64)
+		if err != nil || height <= 0 {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  "",
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+
+		record, err := s.RequeueFailedBlock(height)
+		if err != nil {
+			logger.Logger.Errorf("RequeueFailedBlock error : %s", err)
+			c.JSON(http.StatusInternalServerError, &Response{Code: http.StatusInternalServerError, Msg: err.Error()})
+			return
+		}
+		if record == nil {
+			resp := &Response{
+				Code: ResponseCodeParamsError,
+				Msg:  fmt.Sprintf("block %d is not in the failed block queue", height),
+				Data: "",
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		logger.Logger.Infof("Requeued failed block %d", height)
+
+		resp := &Response{
+			Code: ResponseCodeOk,
+			Msg:  "",
+			Data: toFailedBlock(record),
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
 func HeightEndpoint(s service.IService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		height, err := s.GetChainHeight()
@@ -292,8 +1044,82 @@ func HeightEndpoint(s service.IService) gin.HandlerFunc {
 	}
 }
 
+type BackupResp struct {
+	File          string `json:"file"`
+	Height        int64  `json:"height"`
+	SchemaVersion uint64 `json:"schema_version"`
+}
+
+// BackupEndpoint writes a backup archive into backupDir and responds once it is complete.
+func BackupEndpoint(s service.IService, backupDir string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := os.MkdirAll(backupDir, 0o755); err != nil {
+			logger.Logger.Errorf("Backup error : %s", err)
+			c.JSON(http.StatusInternalServerError, &Response{Code: http.StatusInternalServerError, Msg: err.Error()})
+			return
+		}
+
+		file := filepath.Join(backupDir, fmt.Sprintf("mtt-indexer-%s.backup", time.Now().UTC().Format("20060102T150405Z")))
+		manifest, err := s.Backup(file)
+		if err != nil {
+			logger.Logger.Errorf("Backup error : %s", err)
+			c.JSON(http.StatusInternalServerError, &Response{Code: http.StatusInternalServerError, Msg: err.Error()})
+			return
+		}
+		logger.Logger.Infof("Backed up height %d to %s", manifest.Height, file)
+
+		resp := &Response{
+			Code: ResponseCodeOk,
+			Msg:  "",
+			Data: &BackupResp{
+				File:          file,
+				Height:        manifest.Height,
+				SchemaVersion: manifest.SchemaVersion,
+			},
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// cursorParams are the paging query parameters of the cursor paged endpoints.
+type cursorParams struct {
+	limit  int
+	offset int
+	cursor string
+}
+
+// parseCursorParams reads limit (20 by default, at most 100), offset and cursor. Offset
+// skips records after the cursor, so it stays cheap when combined with one.
+func parseCursorParams(c *gin.Context) cursorParams {
+	limitStr, _ := c.GetQuery("limit")
+	limit, _ := strconv.Atoi(limitStr)
+	offsetStr, _ := c.GetQuery("offset")
+	offset, _ := strconv.Atoi(offsetStr)
+	cursor, _ := c.GetQuery("cursor")
+	return cursorParams{
+		limit:  validLimit(limit, 20, 100),
+		offset: validOffset(offset),
+		cursor: cursor,
+	}
+}
+
+// writeInvalidCursor answers with a params error when err is a cursor that does not
+// belong to the listed records, and reports whether it did.
+func writeInvalidCursor(c *gin.Context, err error) bool {
+	if !errors.Is(err, db.ErrInvalidCursor) {
+		return false
+	}
+	resp := &Response{
+		Code: ResponseCodeParamsError,
+		Msg:  err.Error(),
+		Data: "",
+	}
+	c.JSON(http.StatusOK, resp)
+	return true
+}
+
 func validLimit(originLimit, defaultLimit, maxLimit int) int {
-	if originLimit == 0 {
+	if originLimit <= 0 {
 		return defaultLimit
 	}
 	if originLimit > maxLimit {
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"mtt-indexer/db"
+	"mtt-indexer/service"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testContext(query string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/records?"+query, nil)
+	return c, w
+}
+
+func TestParseCursorParams(t *testing.T) {
+	for _, test := range []struct {
+		query string
+		want  cursorParams
+	}{
+		{"", cursorParams{limit: 20}},
+		{"limit=5&offset=3&cursor=abc", cursorParams{limit: 5, offset: 3, cursor: "abc"}},
+		{"limit=500", cursorParams{limit: 100}},
+		{"limit=-1&offset=-4", cursorParams{limit: 20}},
+		{"limit=x&offset=y", cursorParams{limit: 20}},
+	} {
+		c, _ := testContext(test.query)
+		if got := parseCursorParams(c); got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestWriteInvalidCursor(t *testing.T) {
+	c, w := testContext("")
+	if writeInvalidCursor(c, errors.New("storage failed")) || w.Body.Len() != 0 {
+		t.Fatal("answered an error that is not about the cursor")
+	}
+
+	if !writeInvalidCursor(c, fmt.Errorf("page: %w", db.ErrInvalidCursor)) {
+		t.Fatal("did not answer an invalid cursor")
+	}
+	resp := &Response{}
+	if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || resp.Code != ResponseCodeParamsError {
+		t.Errorf("got status %d and code %d, want a params error", w.Code, resp.Code)
+	}
+}
+
+func TestBackupEndpoint(t *testing.T) {
+	store := db.NewLdb(db.LdbConfig{Path: t.TempDir()})
+	defer store.Close()
+	backupDir := filepath.Join(t.TempDir(), "backups")
+
+	c, w := testContext("")
+	BackupEndpoint(service.NewService(store), backupDir)(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	resp := &struct {
+		Code int
+		Data BackupResp
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != ResponseCodeOk || filepath.Dir(resp.Data.File) != backupDir || resp.Data.SchemaVersion != db.LatestSchemaVersion() {
+		t.Errorf("got response %+v", resp)
+	}
+	if _, err := os.Stat(resp.Data.File); err != nil {
+		t.Errorf("the archive was not written: %v", err)
+	}
+}
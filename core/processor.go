@@ -18,6 +18,7 @@ const (
 	OsmosisNodeRewardIndexError
 	NodeMissingHistoryForBlock
 	FailedBlockEventHandling
+	RolledBackBlock
 )
 
 type FailedBlockHandler func(height int64, code BlockProcessingFailure, err error)
@@ -25,8 +26,10 @@ type FailedBlockHandler func(height int64, code BlockProcessingFailure, err erro
 // Process RPC Block data into the model object used by the application.
 func ProcessBlock(blockData *ctypes.ResultBlock, chainID uint) (types.Block, error) {
 	block := types.Block{
-		Height:  blockData.Block.Height,
-		ChainID: chainID,
+		Height:     blockData.Block.Height,
+		Hash:       blockData.BlockID.Hash.String(),
+		ParentHash: blockData.Block.LastBlockID.Hash.String(),
+		ChainID:    chainID,
 	}
 
 	propAddressFromHex, err := sdkTypes.ConsAddressFromHex(blockData.Block.ProposerAddress.String())
@@ -40,23 +43,29 @@ func ProcessBlock(blockData *ctypes.ResultBlock, chainID uint) (types.Block, err
 	return block, nil
 }
 
-// Log error to stdout. Not much else we can do to handle right now.
-func HandleFailedBlock(height int64, code BlockProcessingFailure, err error) {
-	reason := "{unknown error}"
+func (code BlockProcessingFailure) String() string {
 	switch code {
 	case NodeMissingBlockTxs:
-		reason = "node has no TX history for block"
+		return "node has no TX history for block"
 	case BlockQueryError:
-		reason = "failed to query block result for block"
+		return "failed to query block result for block"
+	case UnprocessableTxError:
+		return "failed to process TXs for block"
 	case OsmosisNodeRewardLookupError:
-		reason = "Failed Osmosis rewards lookup for block"
+		return "Failed Osmosis rewards lookup for block"
 	case OsmosisNodeRewardIndexError:
-		reason = "Failed Osmosis rewards indexing for block"
+		return "Failed Osmosis rewards indexing for block"
 	case NodeMissingHistoryForBlock:
-		reason = "Node has no TX history for block"
+		return "Node has no TX history for block"
 	case FailedBlockEventHandling:
-		reason = "Failed to process block event"
+		return "Failed to process block event"
+	case RolledBackBlock:
+		return "block was unwound by a rollback"
 	}
+	return "{unknown error}"
+}
 
-	logger.Logger.Errorf("Block %v failed. Reason: %v  err:%v", height, reason, err)
+// Log error to stdout. Not much else we can do to handle right now.
+func HandleFailedBlock(height int64, code BlockProcessingFailure, err error) {
+	logger.Logger.Errorf("Block %v failed. Reason: %v  err:%v", height, code, err)
 }
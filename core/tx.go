@@ -14,6 +14,7 @@ import (
 	"mtt-indexer/types"
 	"mtt-indexer/util"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 	"unsafe"
@@ -23,6 +24,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/crypto/keys/multisig"
 	cryptoTypes "github.com/cosmos/cosmos-sdk/crypto/types"
 	cosmosTx "github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/cosmos/cosmos-sdk/x/authz"
 
 	indexerEvents "mtt-indexer/cosmos/events"
 )
@@ -420,6 +422,12 @@ func ProcessTx(tx txtypes.MergedTx, messagesRaw [][]byte, messageTypeURLs []stri
 		return txDBWapper, txTime, err
 	}
 
+	txHeight, err := strconv.ParseInt(tx.TxResponse.Height, 10, 64)
+	if err != nil {
+		logger.Logger.Error("Error parsing tx height.", err)
+		return txDBWapper, txTime, err
+	}
+
 	code := tx.TxResponse.Code
 
 	var messages []model.MessageDBWrapper
@@ -434,26 +442,21 @@ func ProcessTx(tx txtypes.MergedTx, messagesRaw [][]byte, messageTypeURLs []stri
 				messageLog := txtypes.GetMessageLogForIndex(tx.TxResponse.Log, messageIndex)
 				messageType, currMessageDBWrapper := ProcessMessage(messageIndex, message, messageTypeURLs[messageIndex], messageLog, uniqueEventTypes, uniqueEventAttributeKeys)
 				currMessageDBWrapper.Message.Tx.Block.TimeStamp = txTime
+				currMessageDBWrapper.Message.Tx.Block.Height = txHeight
 				currMessageDBWrapper.Message.MessageBytes = messagesRaw[messageIndex]
 				uniqueMessageTypes[messageType] = currMessageDBWrapper.Message.MessageType
 				logger.Logger.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Found msg of type '%v'.", tx.TxResponse.Height, tx.TxResponse.TxHash, messageType))
 
-				if customParsers != nil {
-					if customMessageParsers, ok := customParsers[messageType]; ok {
-						for index, customParser := range customMessageParsers {
-							// We deliberately ignore the error here, as we want to continue processing the message even if a custom parser fails
-							parsedData, err := customParser.ParseMessage(message, messageLog)
-
-							currMessageDBWrapper.MessageParsedDatasets = append(currMessageDBWrapper.MessageParsedDatasets, parsers.MessageParsedData{
-								Data:   parsedData,
-								Error:  err,
-								Parser: &customMessageParsers[index],
-							})
-						}
+				parseCustomMessage(&currMessageDBWrapper, messageType, message, messageLog, customParsers)
+				messages = append(messages, currMessageDBWrapper)
+
+				if msgExec, ok := message.(*authz.MsgExec); ok {
+					execMessages, err := processExecMessages(tx, msgExec, currMessageDBWrapper.Message, messageLog, customParsers, uniqueMessageTypes, uniqueEventTypes, uniqueEventAttributeKeys)
+					if err != nil {
+						return txDBWapper, txTime, err
 					}
+					messages = append(messages, execMessages...)
 				}
-
-				messages = append(messages, currMessageDBWrapper)
 			}
 		}
 	}
@@ -466,6 +469,61 @@ func ProcessTx(tx txtypes.MergedTx, messagesRaw [][]byte, messageTypeURLs []stri
 	return txDBWapper, txTime, nil
 }
 
+func parseCustomMessage(messageDBWrapper *model.MessageDBWrapper, messageType string, message sdktypes.Msg, messageLog *txtypes.LogMessage, customParsers map[string][]parsers.MessageParser) {
+	if customParsers == nil {
+		return
+	}
+	if customMessageParsers, ok := customParsers[messageType]; ok {
+		for index, customParser := range customMessageParsers {
+			// We deliberately ignore the error here, as we want to continue processing the message even if a custom parser fails
+			parsedData, err := customParser.ParseMessage(message, messageLog)
+
+			messageDBWrapper.MessageParsedDatasets = append(messageDBWrapper.MessageParsedDatasets, parsers.MessageParsedData{
+				Data:   parsedData,
+				Error:  err,
+				Parser: &customMessageParsers[index],
+			})
+		}
+	}
+}
+
+// processExecMessages processes the messages executed by an authz MsgExec as if they were
+// sent on their own, so they reach the parsers registered for their type. Each gets its
+// part of the MsgExec log and the grantee that executed it, nested MsgExec messages are
+// expanded too.
+func processExecMessages(tx txtypes.MergedTx, msgExec *authz.MsgExec, execMessage types.Message, execLog *txtypes.LogMessage, customParsers map[string][]parsers.MessageParser, uniqueMessageTypes map[string]types.MessageType, uniqueEventTypes map[string]types.MessageEventType, uniqueEventAttributeKeys map[string]types.MessageEventAttributeKey) ([]model.MessageDBWrapper, error) {
+	innerMessages, err := msgExec.GetMessages()
+	if err != nil {
+		return nil, fmt.Errorf("MsgExec messages could not be processed. TX Hash: %s, Msg index: %d, Err: %v", tx.TxResponse.TxHash, execMessage.MessageIndex, err)
+	}
+
+	var messages []model.MessageDBWrapper
+	execLogs := txtypes.GetExecMessageLogs(execLog, len(innerMessages))
+	for innerIndex, innerMessage := range innerMessages {
+		messageLog := &execLogs[innerIndex]
+		messageType, currMessageDBWrapper := ProcessMessage(execMessage.MessageIndex, innerMessage, msgExec.Msgs[innerIndex].TypeUrl, messageLog, uniqueEventTypes, uniqueEventAttributeKeys)
+		currMessageDBWrapper.Message.Tx = execMessage.Tx
+		currMessageDBWrapper.Message.ExecIndex = append(append([]int{}, execMessage.ExecIndex...), innerIndex)
+		currMessageDBWrapper.Message.MessageBytes = msgExec.Msgs[innerIndex].Value
+		currMessageDBWrapper.Message.Grantee = msgExec.Grantee
+		uniqueMessageTypes[messageType] = currMessageDBWrapper.Message.MessageType
+		logger.Logger.Debug(fmt.Sprintf("[Block: %v] [TX: %v] Found msg of type '%v' executed by grantee %v.", tx.TxResponse.Height, tx.TxResponse.TxHash, messageType, msgExec.Grantee))
+
+		parseCustomMessage(&currMessageDBWrapper, messageType, innerMessage, messageLog, customParsers)
+		messages = append(messages, currMessageDBWrapper)
+
+		if nestedExec, ok := innerMessage.(*authz.MsgExec); ok {
+			nestedMessages, err := processExecMessages(tx, nestedExec, currMessageDBWrapper.Message, messageLog, customParsers, uniqueMessageTypes, uniqueEventTypes, uniqueEventAttributeKeys)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, nestedMessages...)
+		}
+	}
+
+	return messages, nil
+}
+
 // Processes signers in a deterministic order.
 // 1. Processes signers from the auth info
 // 2. Processes signers from the signers array
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"fmt"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	distributionTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	txtypes "mtt-indexer/cosmos/modules/tx"
+	"mtt-indexer/types"
+	"testing"
+)
+
+func TestProcessExecMessagesIndexesInnerMessages(t *testing.T) {
+	grantee := sdktypes.AccAddress([]byte("grantee_address_____"))
+	withdraw := func(validator string) sdktypes.Msg {
+		return &distributionTypes.MsgWithdrawDelegatorReward{DelegatorAddress: "delegator", ValidatorAddress: validator}
+	}
+	nested := authz.NewMsgExec(grantee, []sdktypes.Msg{withdraw("c"), withdraw("d")})
+	msgExec := authz.NewMsgExec(grantee, []sdktypes.Msg{withdraw("a"), &nested, withdraw("b")})
+
+	tx := txtypes.MergedTx{TxResponse: txtypes.Response{TxHash: "HASH", Height: "5"}}
+	execMessage := types.Message{MessageIndex: 3, Tx: types.Tx{Hash: "HASH"}}
+	messages, err := processExecMessages(tx, &msgExec, execMessage, &txtypes.LogMessage{MessageIndex: 3}, nil,
+		map[string]types.MessageType{}, map[string]types.MessageEventType{}, map[string]types.MessageEventAttributeKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, message := range messages {
+		got = append(got, fmt.Sprintf("%d%v", message.Message.MessageIndex, message.Message.ExecIndex))
+		if message.Message.Grantee != grantee.String() || message.Message.Tx.Hash != "HASH" {
+			t.Errorf("got message %+v", message.Message)
+		}
+	}
+	if fmt.Sprint(got) != "[3[0] 3[1] 3[1 0] 3[1 1] 3[2]]" {
+		t.Errorf("got message indexes %v", got)
+	}
+}
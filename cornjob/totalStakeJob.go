@@ -4,7 +4,6 @@ import (
 	"context"
 	sdkmath "cosmossdk.io/math"
 	"github.com/DefiantLabs/probe/client"
-	"github.com/syndtr/goleveldb/leveldb"
 	"mtt-indexer/db"
 	"mtt-indexer/logger"
 	"mtt-indexer/rpc"
@@ -14,21 +13,21 @@ import (
 )
 
 type TotalStakeJob struct {
-	ldb *db.LDB
-	cl  *client.ChainClient
+	store db.Store
+	cl    *client.ChainClient
 }
 
-func CronJobLedgerInit(db *db.LDB, cl *client.ChainClient) {
+func CronJobLedgerInit(store db.Store, cl *client.ChainClient) {
 	c := cron.NewCron()
 	//0 0 */8 * * *
 	//0 0 0 * * *
-	c.Register("Ledger job", "0 0 0 * * *", NewTotalStakeJob(db, cl).saveValidatorsReward)
+	c.Register("Ledger job", "0 0 0 * * *", NewTotalStakeJob(store, cl).saveValidatorsReward)
 	c.Run()
 	defer c.Stop()
 }
 
-func NewTotalStakeJob(ldb *db.LDB, cl *client.ChainClient) *TotalStakeJob {
-	return &TotalStakeJob{ldb: ldb, cl: cl}
+func NewTotalStakeJob(store db.Store, cl *client.ChainClient) *TotalStakeJob {
+	return &TotalStakeJob{store: store, cl: cl}
 }
 
 func (t *TotalStakeJob) saveValidatorsReward(ctx context.Context) error {
@@ -61,10 +60,14 @@ func (t *TotalStakeJob) saveValidatorReward(validator string) error {
 	if err != nil {
 		return err
 	}
-	record.Amount = recordAmount.Sub(claimed).String()
-	return t.ldb.Transaction(
-		func(l *db.LDB, batch *leveldb.Batch) error {
-			err := db.StoreRecord(l.DB, batch, record)
+	withdrawn, err := t.getValidatorCommissionWithdrawn(validator)
+	if err != nil {
+		return err
+	}
+	record.Amount = recordAmount.Sub(claimed).Sub(withdrawn).String()
+	return t.store.Transaction(
+		func(view db.View) error {
+			err := view.StoreRecord(record)
 			if err != nil {
 				return err
 			}
@@ -73,7 +76,7 @@ func (t *TotalStakeJob) saveValidatorReward(validator string) error {
 }
 
 func (t *TotalStakeJob) getValidatorClaimed24H(validator string) (sdkmath.Int, error) {
-	IRecord, err := t.ldb.GetRecordByType(&types.Claimed24H{Validator: validator})
+	IRecord, err := t.store.GetRecordByType(&types.Claimed24H{Validator: validator})
 	if err != nil {
 		return sdkmath.NewInt(0), err
 	}
@@ -88,3 +91,17 @@ func (t *TotalStakeJob) getValidatorClaimed24H(validator string) (sdkmath.Int, e
 		return amount, nil
 	}
 }
+
+// getValidatorCommissionWithdrawn returns the commission withdrawn from the outstanding rewards.
+func (t *TotalStakeJob) getValidatorCommissionWithdrawn(validator string) (sdkmath.Int, error) {
+	IRecord, err := t.store.GetRecordByType(&types.CommissionWithdrawn{Validator: validator})
+	if err != nil {
+		return sdkmath.NewInt(0), err
+	}
+	if record, ok := IRecord.(*types.CommissionWithdrawn); ok {
+		if amount, ok := sdkmath.NewIntFromString(record.Amount); ok {
+			return amount, nil
+		}
+	}
+	return sdkmath.NewInt(0), nil
+}
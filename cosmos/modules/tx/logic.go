@@ -3,11 +3,15 @@ package tx
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
 )
 
-const EventAttributeAmount = "amount"
+const (
+	EventAttributeAmount        = "amount"
+	EventAttributeAuthzMsgIndex = "authz_msg_index"
+)
 
 func GetMessageLogForIndex(logs []LogMessage, index int) *LogMessage {
 	for _, log := range logs {
@@ -19,6 +23,49 @@ func GetMessageLogForIndex(logs []LogMessage, index int) *LogMessage {
 	return nil
 }
 
+// GetExecMessageLogs splits the log of an authz MsgExec into the logs of its numMessages
+// inner messages. The attributes of an inner message's events end with its
+// authz_msg_index, one per MsgExec level with the outermost last, and events of the same
+// type may have been merged into one. Only the outermost index is removed, so the logs of
+// nested MsgExec messages can be split again.
+func GetExecMessageLogs(log *LogMessage, numMessages int) []LogMessage {
+	logs := make([]LogMessage, numMessages)
+	if log == nil {
+		return logs
+	}
+	for i := range logs {
+		logs[i].MessageIndex = log.MessageIndex
+	}
+
+	for _, evt := range log.Events {
+		// The position of this event in the log of each inner message it belongs to
+		positions := map[int]int{}
+		start := 0
+		for i, attr := range evt.Attributes {
+			if attr.Key != EventAttributeAuthzMsgIndex {
+				continue
+			}
+			if i+1 < len(evt.Attributes) && evt.Attributes[i+1].Key == EventAttributeAuthzMsgIndex {
+				continue
+			}
+
+			index, err := strconv.Atoi(attr.Value)
+			if err == nil && index >= 0 && index < numMessages {
+				position, ok := positions[index]
+				if !ok {
+					position = len(logs[index].Events)
+					positions[index] = position
+					logs[index].Events = append(logs[index].Events, LogMessageEvent{Type: evt.Type})
+				}
+				logs[index].Events[position].Attributes = append(logs[index].Events[position].Attributes, evt.Attributes[start:i]...)
+			}
+			start = i + 1
+		}
+	}
+
+	return logs
+}
+
 func GetEventWithType(eventType string, msg *LogMessage) *LogMessageEvent {
 	if msg == nil || msg.Events == nil {
 		return nil
@@ -0,0 +1,58 @@
+package tx
+
+import (
+	"fmt"
+	"testing"
+)
+
+func attr(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func TestGetExecMessageLogs(t *testing.T) {
+	log := &LogMessage{MessageIndex: 2, Events: []LogMessageEvent{
+		{Type: "message", Attributes: []Attribute{
+			attr("action", "/cosmos.authz.v1beta1.MsgExec"),
+		}},
+		// Events of the same type of both messages merged into one
+		{Type: "coin_received", Attributes: []Attribute{
+			attr("receiver", "a"), attr("amount", "1amtt"), attr(EventAttributeAuthzMsgIndex, "0"),
+			attr("receiver", "b"), attr("amount", "2amtt"), attr(EventAttributeAuthzMsgIndex, "1"),
+			attr("receiver", "c"), attr("amount", "3amtt"), attr(EventAttributeAuthzMsgIndex, "0"),
+		}},
+		// An event of a nested MsgExec keeps its inner index
+		{Type: "withdraw_rewards", Attributes: []Attribute{
+			attr("amount", "4amtt"), attr(EventAttributeAuthzMsgIndex, "0"), attr(EventAttributeAuthzMsgIndex, "1"),
+		}},
+		// Indexes outside the MsgExec are dropped
+		{Type: "unbond", Attributes: []Attribute{
+			attr("amount", "5amtt"), attr(EventAttributeAuthzMsgIndex, "2"),
+			attr("amount", "6amtt"), attr(EventAttributeAuthzMsgIndex, "x"),
+		}},
+	}}
+
+	logs := GetExecMessageLogs(log, 2)
+	want := []string{
+		"{2 [{coin_received [{receiver a} {amount 1amtt} {receiver c} {amount 3amtt}]}]}",
+		"{2 [{coin_received [{receiver b} {amount 2amtt}]} {withdraw_rewards [{amount 4amtt} {authz_msg_index 0}]}]}",
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("got %d logs, want %d", len(logs), len(want))
+	}
+	for i := range logs {
+		if got := fmt.Sprint(logs[i]); got != want[i] {
+			t.Errorf("log %d: got %s, want %s", i, got, want[i])
+		}
+	}
+
+	// The nested MsgExec splits its part of the log again
+	nested := GetExecMessageLogs(&logs[1], 1)
+	if got := fmt.Sprint(nested[0].Events); got != "[{withdraw_rewards [{amount 4amtt}]}]" {
+		t.Errorf("got nested events %s", got)
+	}
+
+	logs = GetExecMessageLogs(nil, 3)
+	if len(logs) != 3 || len(logs[0].Events) != 0 {
+		t.Errorf("got logs %v without a MsgExec log, want 3 empty ones", logs)
+	}
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"io"
+	"mtt-indexer/types"
+	"os"
+	"time"
+)
+
+// backupMagic starts every backup archive and names its format version.
+const backupMagic = "MTTIDX-BACKUP-1\n"
+
+// BackupManifest describes the database state a backup archive holds.
+type BackupManifest struct {
+	ChainName     string
+	Height        int64
+	SchemaVersion uint64
+	CreatedAt     time.Time
+}
+
+// Backuper is implemented by stores that can write a consistent backup while indexing continues.
+type Backuper interface {
+	Backup(w io.Writer, chainName string) (*BackupManifest, error)
+}
+
+// Backup writes a gzip archive of a snapshot of the database to w. The archive holds the
+// magic line, the JSON manifest and then every key/value pair, each length prefixed,
+// followed by an empty key and the number of pairs. Commits keep running during the
+// backup since the snapshot does not change.
+func (l *LDB) Backup(w io.Writer, chainName string) (*BackupManifest, error) {
+	// Taken under the lock so the snapshot never falls between the writes of a migration
+	l.lock.RLock()
+	snapshot, err := l.DB.GetSnapshot()
+	if l.readOnly {
+		// Nothing writes through a read-only database, holding the lock only keeps the
+		// refresh from closing it under the snapshot
+		defer l.lock.RUnlock()
+	} else {
+		l.lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	manifest := &BackupManifest{
+		ChainName: chainName,
+		CreatedAt: time.Now().UTC(),
+	}
+	data, err := snapshot.Get([]byte(schemaVersionKey), nil)
+	if err == nil {
+		manifest.SchemaVersion = BytesToUint64(data)
+	} else if !errors.Is(err, leveldb.ErrNotFound) {
+		return nil, err
+	}
+	chain := &types.Chain{Name: chainName}
+	data, err = snapshot.Get(RecordKey(chain), nil)
+	if err == nil {
+		if err := decodeRecord(data, chain); err != nil {
+			return nil, err
+		}
+		manifest.Height = chain.Height
+	} else if !errors.Is(err, leveldb.ErrNotFound) {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	out := bufio.NewWriter(gz)
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := out.WriteString(backupMagic); err != nil {
+		return nil, err
+	}
+	if err := writeBackupField(out, manifestData); err != nil {
+		return nil, err
+	}
+
+	count := uint64(0)
+	iter := snapshot.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := writeBackupField(out, iter.Key()); err != nil {
+			return nil, err
+		}
+		if err := writeBackupField(out, iter.Value()); err != nil {
+			return nil, err
+		}
+		count++
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	if err := writeBackupField(out, nil); err != nil {
+		return nil, err
+	}
+	if err := writeBackupField(out, Uint64ToBytes(count)); err != nil {
+		return nil, err
+	}
+	if err := out.Flush(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+func writeBackupField(w *bufio.Writer, data []byte) error {
+	var length [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(length[:], uint64(len(data)))
+	if _, err := w.Write(length[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func readBackupField(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	return data, err
+}
+
+// Restore creates the database described by cfg from a backup archive. The database must
+// not exist yet. A partially restored database is removed again when the archive is invalid.
+func Restore(r io.Reader, cfg LdbConfig) (manifest *BackupManifest, err error) {
+	if cfg.ReadOnly {
+		return nil, leveldb.ErrReadOnly
+	}
+	path := cfg.Dir()
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("database %s already exists", path)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	in := bufio.NewReader(gz)
+
+	magic := make([]byte, len(backupMagic))
+	if _, err := io.ReadFull(in, magic); err != nil || string(magic) != backupMagic {
+		return nil, errors.New("not an index backup archive")
+	}
+	manifestData, err := readBackupField(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	manifest = &BackupManifest{}
+	if err := json.Unmarshal(manifestData, manifest); err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+
+	ldb, err := openLeveldb(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		closeErr := ldb.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.RemoveAll(path)
+		}
+	}()
+
+	count := uint64(0)
+	batch := new(leveldb.Batch)
+	for {
+		key, err := readBackupField(in)
+		if err != nil {
+			return nil, fmt.Errorf("backup archive is truncated: %w", err)
+		}
+		if len(key) == 0 {
+			break
+		}
+		value, err := readBackupField(in)
+		if err != nil {
+			return nil, fmt.Errorf("backup archive is truncated: %w", err)
+		}
+		batch.Put(key, value)
+		count++
+
+		if batch.Len() >= migrationBatchSize {
+			if err := ldb.Write(batch, nil); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := ldb.Write(batch, nil); err != nil {
+		return nil, err
+	}
+
+	expected, err := readBackupField(in)
+	if err != nil || len(expected) != 8 {
+		return nil, errors.New("backup archive is truncated: missing record count")
+	}
+	if BytesToUint64(expected) != count {
+		return nil, fmt.Errorf("backup archive holds %d records but %d were read", BytesToUint64(expected), count)
+	}
+	return manifest, nil
+}
+
+// BackupToFile writes a backup to path. The archive is written next to it first and only
+// renamed to path once complete, so path never holds a partial backup.
+func BackupToFile(b Backuper, path, chainName string) (*BackupManifest, error) {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := b.Backup(file, chainName)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return manifest, os.Rename(tmpPath, path)
+}
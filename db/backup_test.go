@@ -0,0 +1,88 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"mtt-indexer/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	source := newTestLdb(t)
+	err := source.Transaction(func(view View) error {
+		for i := 0; i < 3; i++ {
+			if err := view.StoreRecord(&types.ValidatorRecord{Validator: testKeysValidator, TxHash: fmt.Sprintf("tx%d", i)}); err != nil {
+				return err
+			}
+		}
+		return view.StoreRecord(&types.Chain{Name: "mtt", Height: 42, Hash: "hash42"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "backup.gz")
+	manifest, err := BackupToFile(source, path, "mtt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ChainName != "mtt" || manifest.Height != 42 || manifest.SchemaVersion != LatestSchemaVersion() {
+		t.Errorf("got manifest %+v", manifest)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("the partial archive was left behind")
+	}
+
+	// The restored database goes where the db_tail_fix points
+	t.Setenv("HOME", t.TempDir())
+	cfg := LdbConfig{TailFix: "_replica"}
+	archive, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	restoredManifest, err := Restore(archive, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restoredManifest.Height != 42 || !restoredManifest.CreatedAt.Equal(manifest.CreatedAt) {
+		t.Errorf("got manifest %+v from the archive, want %+v", restoredManifest, manifest)
+	}
+
+	restored := NewLdb(cfg)
+	defer restored.Close()
+	if got, want := dump(t, restored), dump(t, source); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("restored database differs from the source:\n%v\n%v", got, want)
+	}
+
+	// An existing database is never overwritten
+	if _, err := archive.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(archive, cfg); err == nil {
+		t.Error("restored over an existing database")
+	}
+}
+
+func TestRestoreRemovesTruncatedDatabase(t *testing.T) {
+	source := newTestLdb(t)
+	storeValidatorRecords(t, source, testKeysValidator, 20)
+	var archive bytes.Buffer
+	if _, err := source.Backup(&archive, "mtt"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := LdbConfig{Path: filepath.Join(t.TempDir(), "restored")}
+	if _, err := Restore(bytes.NewReader(archive.Bytes()[:archive.Len()-20]), cfg); err == nil {
+		t.Fatal("restored a truncated archive")
+	}
+	if _, err := os.Stat(cfg.Path); !os.IsNotExist(err) {
+		t.Errorf("the partially restored database was kept (%v)", err)
+	}
+
+	if _, err := Restore(bytes.NewReader([]byte("not a backup")), cfg); err == nil {
+		t.Error("restored something that is not an archive")
+	}
+}
@@ -0,0 +1,481 @@
+package db
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	EncodingJSON   = "json"
+	EncodingBinary = "binary"
+)
+
+// binaryFormatV1 starts every value written by the binary codec. JSON records always
+// start with '{', so values of both encodings can be told apart and read side by side.
+const binaryFormatV1 byte = 0x01
+
+// The binary codec writes the exported fields of a record in declaration order, preceded
+// by their number. Fields may only be appended to a record type: values written before
+// a field existed are read with the field left at its zero value.
+//
+// Strings are stored with a kind in the low two bits of their length, so the decimal
+// amounts and hex tx hashes that make up most records are stored as their bytes.
+const (
+	stringRaw = iota
+	stringDecimal
+	stringNegativeDecimal
+	stringHex
+)
+
+var errTruncatedRecord = errors.New("binary record is truncated")
+
+type fieldCodec struct {
+	encode func(buf []byte, v reflect.Value) []byte
+	decode func(r *binaryReader, v reflect.Value) error
+}
+
+// codecs caches the fieldCodec of every record type, keyed by reflect.Type.
+var codecs sync.Map
+
+// encodeRecord encodes record in the encoding the database was opened with.
+func (l *LDB) encodeRecord(record interface{}) ([]byte, error) {
+	if l.encoding == EncodingBinary {
+		return marshalBinary(record)
+	}
+	return json.Marshal(record)
+}
+
+// decodeRecord decodes a record written in either encoding into the pointer record.
+func decodeRecord(data []byte, record interface{}) error {
+	if len(data) > 0 && data[0] == binaryFormatV1 {
+		return unmarshalBinary(data[1:], record)
+	}
+	if len(data) > 0 && data[0] < ' ' {
+		return fmt.Errorf("unknown record encoding %d", data[0])
+	}
+	return json.Unmarshal(data, record)
+}
+
+func isEncodedAs(data []byte, encoding string) bool {
+	binaryValue := len(data) > 0 && data[0] == binaryFormatV1
+	return binaryValue == (encoding == EncodingBinary)
+}
+
+func marshalBinary(record interface{}) ([]byte, error) {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	codec, err := codecFor(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	return codec.encode([]byte{binaryFormatV1}, v), nil
+}
+
+func unmarshalBinary(data []byte, record interface{}) error {
+	v := reflect.ValueOf(record)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("cannot decode into %T", record)
+	}
+	codec, err := codecFor(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+	r := &binaryReader{data: data}
+	if err := codec.decode(r, v.Elem()); err != nil {
+		return err
+	}
+	if r.pos != len(r.data) {
+		return fmt.Errorf("binary record has %d trailing bytes", len(r.data)-r.pos)
+	}
+	return nil
+}
+
+func codecFor(t reflect.Type) (*fieldCodec, error) {
+	if codec, ok := codecs.Load(t); ok {
+		return codec.(*fieldCodec), nil
+	}
+	codec, err := newFieldCodec(t)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %s: %w", t, err)
+	}
+	codecs.Store(t, codec)
+	return codec, nil
+}
+
+func newFieldCodec(t reflect.Type) (*fieldCodec, error) {
+	if t == timeType {
+		return &fieldCodec{encode: encodeTime, decode: decodeTime}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &fieldCodec{
+			encode: func(buf []byte, v reflect.Value) []byte {
+				if v.Bool() {
+					return append(buf, 1)
+				}
+				return append(buf, 0)
+			},
+			decode: func(r *binaryReader, v reflect.Value) error {
+				b, err := r.byte()
+				v.SetBool(b != 0)
+				return err
+			},
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &fieldCodec{
+			encode: func(buf []byte, v reflect.Value) []byte {
+				return binary.AppendVarint(buf, v.Int())
+			},
+			decode: func(r *binaryReader, v reflect.Value) error {
+				i, err := r.varint()
+				if err != nil {
+					return err
+				}
+				if v.OverflowInt(i) {
+					return fmt.Errorf("%d overflows %s", i, v.Type())
+				}
+				v.SetInt(i)
+				return nil
+			},
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &fieldCodec{
+			encode: func(buf []byte, v reflect.Value) []byte {
+				return binary.AppendUvarint(buf, v.Uint())
+			},
+			decode: func(r *binaryReader, v reflect.Value) error {
+				u, err := r.uvarint()
+				if err != nil {
+					return err
+				}
+				if v.OverflowUint(u) {
+					return fmt.Errorf("%d overflows %s", u, v.Type())
+				}
+				v.SetUint(u)
+				return nil
+			},
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		return &fieldCodec{
+			encode: func(buf []byte, v reflect.Value) []byte {
+				return binary.BigEndian.AppendUint64(buf, math.Float64bits(v.Float()))
+			},
+			decode: func(r *binaryReader, v reflect.Value) error {
+				data, err := r.bytes(8)
+				if err != nil {
+					return err
+				}
+				v.SetFloat(math.Float64frombits(binary.BigEndian.Uint64(data)))
+				return nil
+			},
+		}, nil
+	case reflect.String:
+		return &fieldCodec{
+			encode: func(buf []byte, v reflect.Value) []byte {
+				return encodeString(buf, v.String())
+			},
+			decode: func(r *binaryReader, v reflect.Value) error {
+				s, err := decodeString(r)
+				v.SetString(s)
+				return err
+			},
+		}, nil
+	case reflect.Slice:
+		return newSliceCodec(t)
+	case reflect.Struct:
+		return newStructCodec(t)
+	default:
+		return nil, fmt.Errorf("unsupported kind %s", t.Kind())
+	}
+}
+
+// newSliceCodec stores the length plus one, so that nil and empty slices stay apart.
+// Undo logs rely on it: a nil value marks a key that did not exist before the block.
+func newSliceCodec(t reflect.Type) (*fieldCodec, error) {
+	if t.Elem().Kind() == reflect.Uint8 {
+		return &fieldCodec{
+			encode: func(buf []byte, v reflect.Value) []byte {
+				if v.IsNil() {
+					return append(buf, 0)
+				}
+				buf = binary.AppendUvarint(buf, uint64(v.Len())+1)
+				return append(buf, v.Bytes()...)
+			},
+			decode: func(r *binaryReader, v reflect.Value) error {
+				n, err := r.length()
+				if err != nil || n < 0 {
+					v.SetBytes(nil)
+					return err
+				}
+				data, err := r.bytes(n)
+				if err != nil {
+					return err
+				}
+				v.SetBytes(append(make([]byte, 0, n), data...))
+				return nil
+			},
+		}, nil
+	}
+
+	elem, err := newFieldCodec(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	return &fieldCodec{
+		encode: func(buf []byte, v reflect.Value) []byte {
+			if v.IsNil() {
+				return append(buf, 0)
+			}
+			buf = binary.AppendUvarint(buf, uint64(v.Len())+1)
+			for i := 0; i < v.Len(); i++ {
+				buf = elem.encode(buf, v.Index(i))
+			}
+			return buf
+		},
+		decode: func(r *binaryReader, v reflect.Value) error {
+			n, err := r.length()
+			if err != nil || n < 0 {
+				v.Set(reflect.Zero(t))
+				return err
+			}
+			// Every element takes at least a byte
+			if n > len(r.data)-r.pos {
+				return errTruncatedRecord
+			}
+			slice := reflect.MakeSlice(t, n, n)
+			for i := 0; i < n; i++ {
+				if err := elem.decode(r, slice.Index(i)); err != nil {
+					return err
+				}
+			}
+			v.Set(slice)
+			return nil
+		},
+	}, nil
+}
+
+func newStructCodec(t reflect.Type) (*fieldCodec, error) {
+	var indexes []int
+	var fields []*fieldCodec
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		field, err := newFieldCodec(t.Field(i).Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", t.Field(i).Name, err)
+		}
+		indexes = append(indexes, i)
+		fields = append(fields, field)
+	}
+
+	return &fieldCodec{
+		encode: func(buf []byte, v reflect.Value) []byte {
+			buf = binary.AppendUvarint(buf, uint64(len(fields)))
+			for i, field := range fields {
+				buf = field.encode(buf, v.Field(indexes[i]))
+			}
+			return buf
+		},
+		decode: func(r *binaryReader, v reflect.Value) error {
+			n, err := r.uvarint()
+			if err != nil {
+				return err
+			}
+			if n > uint64(len(fields)) {
+				return fmt.Errorf("binary %s has %d fields, this build knows %d", t, n, len(fields))
+			}
+			for i := 0; i < int(n); i++ {
+				if err := fields[i].decode(r, v.Field(indexes[i])); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}, nil
+}
+
+// encodeTime keeps the instant only. Times are read back in UTC, as the chain reports them.
+func encodeTime(buf []byte, v reflect.Value) []byte {
+	t := v.Interface().(time.Time)
+	buf = binary.AppendVarint(buf, t.Unix())
+	return binary.AppendUvarint(buf, uint64(t.Nanosecond()))
+}
+
+func decodeTime(r *binaryReader, v reflect.Value) error {
+	seconds, err := r.varint()
+	if err != nil {
+		return err
+	}
+	nanos, err := r.uvarint()
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(time.Unix(seconds, int64(nanos)).UTC()))
+	return nil
+}
+
+func encodeString(buf []byte, s string) []byte {
+	kind, payload := stringRaw, []byte(s)
+	switch {
+	case isCanonicalDecimal(s):
+		kind, payload = stringDecimal, decimalBytes(s)
+	case len(s) > 1 && s[0] == '-' && s != "-0" && isCanonicalDecimal(s[1:]):
+		kind, payload = stringNegativeDecimal, decimalBytes(s[1:])
+	case isUpperHex(s):
+		payload, _ = hex.DecodeString(s)
+		kind = stringHex
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(payload))<<2|uint64(kind))
+	return append(buf, payload...)
+}
+
+func decodeString(r *binaryReader) (string, error) {
+	header, err := r.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if header>>2 > uint64(len(r.data)-r.pos) {
+		return "", errTruncatedRecord
+	}
+	payload, err := r.bytes(int(header >> 2))
+	if err != nil {
+		return "", err
+	}
+
+	switch header & 3 {
+	case stringDecimal:
+		return decimalString(payload), nil
+	case stringNegativeDecimal:
+		return "-" + decimalString(payload), nil
+	case stringHex:
+		return hexUpper(payload), nil
+	default:
+		return string(payload), nil
+	}
+}
+
+// isCanonicalDecimal reports whether s is a non-negative integer that reads back the same
+// from its bytes, i.e. without sign or leading zeros.
+func isCanonicalDecimal(s string) bool {
+	if s == "" || (s[0] == '0' && len(s) > 1) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func decimalBytes(s string) []byte {
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		var data [8]byte
+		binary.BigEndian.PutUint64(data[:], u)
+		i := 0
+		for i < len(data) && data[i] == 0 {
+			i++
+		}
+		return data[i:]
+	}
+	n, _ := new(big.Int).SetString(s, 10)
+	return n.Bytes()
+}
+
+func decimalString(data []byte) string {
+	if len(data) <= 8 {
+		var u uint64
+		for _, b := range data {
+			u = u<<8 | uint64(b)
+		}
+		return strconv.FormatUint(u, 10)
+	}
+	return new(big.Int).SetBytes(data).String()
+}
+
+func isUpperHex(s string) bool {
+	if len(s) < 2 || len(s)%2 != 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func hexUpper(data []byte) string {
+	const digits = "0123456789ABCDEF"
+	s := make([]byte, len(data)*2)
+	for i, b := range data {
+		s[i*2] = digits[b>>4]
+		s[i*2+1] = digits[b&0x0f]
+	}
+	return string(s)
+}
+
+type binaryReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *binaryReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncatedRecord
+	}
+	r.pos++
+	return r.data[r.pos-1], nil
+}
+
+func (r *binaryReader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data)-r.pos {
+		return nil, errTruncatedRecord
+	}
+	r.pos += n
+	return r.data[r.pos-n : r.pos], nil
+}
+
+func (r *binaryReader) uvarint() (uint64, error) {
+	u, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, errTruncatedRecord
+	}
+	r.pos += n
+	return u, nil
+}
+
+func (r *binaryReader) varint() (int64, error) {
+	i, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, errTruncatedRecord
+	}
+	r.pos += n
+	return i, nil
+}
+
+// length reads a slice length written as length plus one, returning -1 for nil.
+func (r *binaryReader) length() (int, error) {
+	u, err := r.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if u > uint64(len(r.data)-r.pos)+1 {
+		return 0, errTruncatedRecord
+	}
+	return int(u) - 1, nil
+}
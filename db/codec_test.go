@@ -0,0 +1,194 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mtt-indexer/types"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fill sets every exported field of v to a value that differs from its zero value.
+func fill(v reflect.Value, seed int) {
+	if v.Type() == timeType {
+		v.Set(reflect.ValueOf(time.Unix(1718103061+int64(seed), 477179159).UTC()))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(seed%100 + 1))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(seed%100 + 1))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(seed) + 0.25)
+	case reflect.String:
+		v.SetString(fmt.Sprintf("value%d", seed))
+	case reflect.Slice:
+		slice := reflect.MakeSlice(v.Type(), 2, 2)
+		for i := 0; i < slice.Len(); i++ {
+			fill(slice.Index(i), seed*10+i)
+		}
+		v.Set(slice)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), seed*10+i)
+			}
+		}
+	}
+}
+
+func TestCodecRoundTripsRecordTypes(t *testing.T) {
+	for _, recordType := range recordTypes {
+		record := reflect.New(reflect.TypeOf(recordType).Elem())
+		fill(record.Elem(), 1)
+
+		for _, encoding := range []string{EncodingJSON, EncodingBinary} {
+			l := &LDB{encoding: encoding}
+			data, err := l.encodeRecord(record.Interface())
+			if err != nil {
+				t.Fatalf("%T %s: %v", recordType, encoding, err)
+			}
+			if !isEncodedAs(data, encoding) {
+				t.Errorf("%T: value is not encoded as %s", recordType, encoding)
+			}
+
+			decoded := reflect.New(record.Elem().Type())
+			if err := decodeRecord(data, decoded.Interface()); err != nil {
+				t.Fatalf("%T %s: %v", recordType, encoding, err)
+			}
+			if !reflect.DeepEqual(decoded.Interface(), record.Interface()) {
+				t.Errorf("%T %s: got %+v, want %+v", recordType, encoding, decoded.Interface(), record.Interface())
+			}
+		}
+	}
+}
+
+func TestCodecStrings(t *testing.T) {
+	for _, s := range []string{
+		"", "0", "7", "1000000000000000000000000", "18446744073709551616", "-25", "-0", "007",
+		"1.5", "A1B2C3D4", "a1b2c3d4", "ABC", "mtt12x07g3270742n42heupleuwvjuzn5j6x4dmysj",
+	} {
+		data, err := marshalBinary(&types.BlockEventRecordAttribute{Key: s, Value: s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := &types.BlockEventRecordAttribute{}
+		if err := decodeRecord(data, decoded); err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if decoded.Key != s || decoded.Value != s {
+			t.Errorf("got %q, want %q", decoded.Key, s)
+		}
+	}
+}
+
+// delegatorRecordV1 is types.DelegatorRecord before Grantee was appended.
+type delegatorRecordV1 struct {
+	ID             uint64
+	Delegator      string
+	Validator      string
+	Amount         string
+	Denom          string
+	TxHash         string
+	DelegationType types.DelegationType
+	DelegationTime time.Time
+}
+
+func TestCodecReadsValuesWrittenBeforeAppendedFields(t *testing.T) {
+	old := &delegatorRecordV1{
+		ID:             3,
+		Delegator:      "mtt12x07g3270742n42heupleuwvjuzn5j6x4dmysj",
+		Validator:      "mttvaloper12x07g3270742n42heupleuwvjuzn5j6x2ekcn0",
+		Amount:         "1000000000000000000",
+		Denom:          "amtt",
+		TxHash:         "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08",
+		DelegationType: types.Undelegate,
+		DelegationTime: time.Date(2024, 6, 11, 10, 51, 1, 0, time.UTC),
+	}
+	data, err := marshalBinary(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := &types.DelegatorRecord{}
+	if err := decodeRecord(data, record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Grantee != "" || record.ID != old.ID || record.Amount != old.Amount || record.TxHash != old.TxHash ||
+		record.DelegationType != old.DelegationType || !record.DelegationTime.Equal(old.DelegationTime) {
+		t.Errorf("got %+v from %+v", record, old)
+	}
+
+	// A value with fields this build does not know is refused instead of misread
+	data, err = marshalBinary(&types.DelegatorRecord{Grantee: "mtt10wpwl4mqpgdgz8597kphgahx3a8degvg58kjx5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decodeRecord(data, &delegatorRecordV1{}); err == nil {
+		t.Error("decoded a value with more fields than the record type")
+	}
+}
+
+func TestCodecKeepsNilAndEmptySlicesApart(t *testing.T) {
+	undoLog := &types.UndoLog{Height: 5, Entries: []types.UndoEntry{
+		{Key: []byte("missing")},
+		{Key: []byte("empty"), Value: []byte{}},
+	}}
+	data, err := marshalBinary(undoLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &types.UndoLog{}
+	if err := decodeRecord(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Entries[0].Value != nil || decoded.Entries[1].Value == nil {
+		t.Errorf("got values %#v and %#v", decoded.Entries[0].Value, decoded.Entries[1].Value)
+	}
+}
+
+func TestCodecRejectsBrokenValues(t *testing.T) {
+	data, err := marshalBinary(&types.Chain{Name: "mtt", Rpc: "https://cosmos-rpc.mtt.network:443", Height: 5199872})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(data); i++ {
+		if err := decodeRecord(data[:i], &types.Chain{}); err == nil {
+			t.Errorf("decoded a value truncated to %d bytes", i)
+		}
+	}
+	if err := decodeRecord(append(data, 0), &types.Chain{}); err == nil {
+		t.Error("decoded a value with trailing bytes")
+	}
+	if err := decodeRecord([]byte{0x02, 0x00}, &types.Chain{}); err == nil {
+		t.Error("decoded a value of an unknown encoding")
+	}
+}
+
+func TestCodecReadsJSONValues(t *testing.T) {
+	chain := &types.Chain{Name: "mtt", ChainID: "mtt_6880-1", Height: 5199872}
+	data, err := json.Marshal(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &types.Chain{}
+	if err := decodeRecord(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != *chain {
+		t.Errorf("got %+v, want %+v", decoded, chain)
+	}
+
+	binaryData, err := marshalBinary(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(binaryData) >= len(data) || bytes.Equal(binaryData, data) {
+		t.Errorf("binary value takes %d bytes, JSON %d", len(binaryData), len(data))
+	}
+}
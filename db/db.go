@@ -1,12 +1,12 @@
 package db
 
 import (
-	"encoding/binary"
-	"encoding/json"
+	"bytes"
 	"errors"
 	"fmt"
 	_ "github.com/shopspring/decimal"
 	"github.com/syndtr/goleveldb/leveldb"
+	leveldberrors "github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/util"
 	_ "github.com/syndtr/goleveldb/leveldb/util"
 	"log"
@@ -15,6 +15,7 @@ import (
 	"os"
 	"reflect"
 	"sync"
+	"time"
 )
 
 const dbName = "mtt_index_"
@@ -23,50 +24,162 @@ const indexKey = "index"
 type LDB struct {
 	DB   *leveldb.DB
 	lock sync.RWMutex
+
+	readOnly bool
+	stop     chan struct{}
+	// stale asks refreshLoop to reopen a read-only database right away
+	stale chan struct{}
+	// encoding is the record encoding writes use
+	encoding string
 }
 
-func NewLdb(tailFix string) *LDB {
-	l := &LDB{}
+// LdbPath returns the directory of the database with the given db_tail_fix.
+func LdbPath(tailFix string) string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal(err)
 	}
-	db, err := leveldb.OpenFile(homeDir+"/."+dbName+tailFix, nil)
+	return homeDir + "/." + dbName + tailFix
+}
+
+// NewLdb opens the database and applies pending migrations before anything reads it.
+// A read-only database is only checked to be migrated already.
+func NewLdb(cfg LdbConfig) *LDB {
+	l := OpenLdb(cfg)
+	if err := l.Migrate(); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	return l
+}
+
+// OpenLdb opens the database without migrating it.
+func OpenLdb(cfg LdbConfig) *LDB {
+	encoding, err := cfg.encoding()
+	if err != nil {
+		panic(err)
+	}
+	l := &LDB{readOnly: cfg.ReadOnly, encoding: encoding}
+	if l.readOnly {
+		l.stale = make(chan struct{}, 1)
+	}
+	db, err := openLeveldb(cfg, l.stale)
 	if err != nil {
 		panic(err)
 	}
 	l.DB = db
 	l.lock = sync.RWMutex{}
+	if l.readOnly {
+		l.stop = make(chan struct{})
+		go l.refreshLoop(cfg)
+	}
 	return l
 }
 
-func (l *LDB) Transaction(fc func(l *LDB, batch *leveldb.Batch) error) error {
-	batch := new(leveldb.Batch)
+func openLeveldb(cfg LdbConfig, stale chan<- struct{}) (*leveldb.DB, error) {
+	o, err := cfg.options()
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.ReadOnly {
+		return leveldb.OpenFile(cfg.Dir(), o)
+	}
+	stor, err := newReadOnlyStorage(cfg.Dir(), stale)
+	if err != nil {
+		return nil, err
+	}
+	return leveldb.Open(stor, o)
+}
+
+// refreshLoop reopens a read-only database so it sees what the writer committed since.
+// Reopening also drops tables the writer has compacted away in the meantime. Reads that
+// failed on such a table reopen it right away, see readFailed; the failed read itself is
+// not retried.
+func (l *LDB) refreshLoop(cfg LdbConfig) {
+	ticker := time.NewTicker(cfg.refreshInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		case <-l.stale:
+			logger.Logger.Infof("Read-only database is stale, reopening")
+		}
+
+		db, err := openLeveldb(cfg, l.stale)
+		if err != nil {
+			logger.Logger.Errorf("Failed to refresh read-only database: %v", err)
+			continue
+		}
+		l.lock.Lock()
+		old := l.DB
+		l.DB = db
+		l.lock.Unlock()
+		if err := old.Close(); err != nil {
+			logger.Logger.Errorf("Failed to close stale read-only database: %v", err)
+		}
+	}
+}
+
+func (l *LDB) Transaction(fc func(view View) error) error {
 	l.lock.Lock()
 	defer l.lock.Unlock()
-	err := fc(l, batch)
+
+	view := newView(l)
+	err := fc(view)
 	if err != nil {
 		return err
 	}
 
-	return l.DB.Write(batch, nil)
+	return l.DB.Write(view.batch, nil)
+}
+
+func (l *LDB) Close() error {
+	if l.stop != nil {
+		close(l.stop)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.DB.Close()
+}
+
+// Migrate applies pending migrations. NewLdb already ran them, so this only has work to
+// do for databases opened with OpenLdb. A read-only database cannot be migrated and
+// fails instead when it is behind.
+func (l *LDB) Migrate() error {
+	if l.readOnly {
+		l.lock.RLock()
+		defer l.lock.RUnlock()
+		version, err := l.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		if version != LatestSchemaVersion() {
+			return fmt.Errorf("read-only database is at schema version %d, this build expects %d", version, LatestSchemaVersion())
+		}
+		return nil
+	}
+	_, err := l.RunMigrations(false)
+	return err
 }
 
 func (l *LDB) GetRecordByType(record types.DbRecord) (interface{}, error) {
-	key := []byte(record.Key())
+	key := RecordKey(record)
+	l.lock.RLock()
 	data, err := l.DB.Get(key, nil)
+	l.lock.RUnlock()
 	if err != nil {
 		if errors.Is(err, leveldb.ErrNotFound) {
 			return 1, nil
 		}
-		return nil, err
+		return nil, l.readFailed(err)
 	}
 
 	//recordType := reflect.TypeOf(record).Elem()
 	//newRecordPtr := reflect.New(recordType)
 	//newRecord := newRecordPtr.Interface()
 	recordPtr := reflect.New(reflect.TypeOf(record).Elem()).Interface()
-	err = json.Unmarshal(data, recordPtr)
+	err = decodeRecord(data, recordPtr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal record: %v", err)
 	}
@@ -82,185 +195,189 @@ func getRecordType(record interface{}) string {
 	return t.Name()
 }
 
+// GetAllRecordsWithAutoId returns one page of the records under record.Prefix() in id
+// order, oldest first when ascending, together with the total number of records.
+// Skipping offset records costs O(offset); GetRecordsWithAutoIdCursor does not.
 func (l *LDB) GetAllRecordsWithAutoId(record types.DbRecordAutoId, limit, offset int, ascending bool) ([]interface{}, int, error) {
-	if limit <= 0 {
-		return nil, 0, fmt.Errorf("limit must be greater than 0")
-	}
 	if offset < 0 {
 		return nil, 0, fmt.Errorf("offset cannot be negative")
 	}
+	records, _, total, err := l.getAutoIdPage(record, nil, limit, offset, ascending)
+	return records, total, err
+}
+
+// GetRecordsWithAutoIdCursor returns the page of records that follows cursor in id order
+// (from the first record for an empty cursor) after skipping offset records, the cursor of
+// the next page ("" after the last page) and the total number of records.
+func (l *LDB) GetRecordsWithAutoIdCursor(record types.DbRecordAutoId, limit, offset int, cursor string, ascending bool) ([]interface{}, string, int, error) {
+	if offset < 0 {
+		return nil, "", 0, fmt.Errorf("offset cannot be negative")
+	}
+	var after []byte
+	if cursor != "" {
+		var err error
+		after, err = DecodeCursor(record.Prefix(), cursor)
+		if err != nil {
+			return nil, "", 0, err
+		}
+	}
+	return l.getAutoIdPage(record, after, limit, offset, ascending)
+}
+
+func (l *LDB) getAutoIdPage(record types.DbRecordAutoId, after []byte, limit, offset int, ascending bool) ([]interface{}, string, int, error) {
+	if limit <= 0 {
+		return nil, "", 0, fmt.Errorf("limit must be greater than 0")
+	}
 
 	var records []interface{}
-	prefix := []byte(record.Prefix())
+	prefix := AutoIdPrefix(record.Prefix())
 
 	l.lock.RLock()
 	defer l.lock.RUnlock()
 
-	// Create an iterator for the prefix
-	iter := l.DB.NewIterator(util.BytesPrefix(prefix), nil)
-	defer iter.Release()
-
-	// Count total records
-	total := 0
-	for iter.Seek(prefix); iter.Valid(); iter.Next() {
-		total++
-	}
-	if err := iter.Error(); err != nil {
-		logger.Logger.Errorf("iterator error during total count: %v", err)
-		return nil, 0, err
+	total, err := l.getU64(recordCountKey(record.Prefix()))
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return nil, "", 0, err
 	}
 
-	iter.Release()
-	iter = l.DB.NewIterator(util.BytesPrefix(prefix), nil)
-	if !ascending {
-		iter.Last() // Start from the end if descending
-	}
+	iter := l.DB.NewIterator(util.BytesPrefix(prefix), nil)
 	defer iter.Release()
 
-	count := 0
-	started := 0
-
-	for {
-		if len(iter.Value()) == 0 {
-			break
+	var valid bool
+	switch {
+	case after == nil && ascending:
+		valid = iter.First()
+	case after == nil:
+		valid = iter.Last()
+	case ascending:
+		valid = iter.Seek(after)
+		if valid && bytes.Equal(iter.Key(), after) {
+			valid = iter.Next()
 		}
-
-		if started < offset { // Skip until the offset
-			started++
-			continue
-		}
-
-		if count >= limit { // Stop if we reached the limit
-			break
+	default:
+		// Seek lands on the first key >= after, the previous one is the next page
+		if iter.Seek(after) {
+			valid = iter.Prev()
+		} else {
+			valid = iter.Last()
 		}
+	}
 
-		data := iter.Value()
-
-		recordType := reflect.TypeOf(record).Elem()
-		newRecordPtr := reflect.New(recordType)
-		newRecord := newRecordPtr.Interface()
-
-		err := json.Unmarshal(data, &newRecord)
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to unmarshal record: %v", err)
+	var lastKey []byte
+	recordType := reflect.TypeOf(record).Elem()
+	for skipped := 0; valid && len(records) < limit; {
+		if skipped < offset {
+			skipped++
+		} else {
+			newRecord := reflect.New(recordType).Interface()
+			err := decodeRecord(iter.Value(), newRecord)
+			if err != nil {
+				return nil, "", 0, fmt.Errorf("failed to unmarshal record: %v", err)
+			}
+			records = append(records, newRecord)
+			lastKey = append(lastKey[:0], iter.Key()...)
 		}
 
-		records = append(records, newRecord)
-		count++
-
 		if ascending {
-			if !iter.Next() { // Move forward
-				break
-			}
+			valid = iter.Next()
 		} else {
-			if !iter.Prev() { // Move backward
-				break
-			}
+			valid = iter.Prev()
 		}
 	}
 
 	if err := iter.Error(); err != nil {
 		logger.Logger.Errorf("iterator error: %v", err)
-		return nil, 0, err
+		return nil, "", 0, l.readFailed(err)
+	}
+
+	next := ""
+	if valid && lastKey != nil {
+		next = EncodeCursor(lastKey)
 	}
-	return records, total, nil
+	return records, next, int(total), nil
 }
 
 func (l *LDB) GetLast(record types.DbRecordAutoId) (interface{}, error) {
-	prefix := []byte(record.Prefix())
+	prefix := AutoIdPrefix(record.Prefix())
 
 	l.lock.RLock()
 	defer l.lock.RUnlock()
 
 	iter := l.DB.NewIterator(util.BytesPrefix(prefix), nil)
-	iter.Last()
 	defer iter.Release()
 
-	if len(iter.Value()) == 0 {
-		return nil, nil
+	if !iter.Last() {
+		return nil, l.readFailed(iter.Error())
 	}
-	data := iter.Value()
 
-	recordType := reflect.TypeOf(record).Elem()
-	newRecordPtr := reflect.New(recordType)
-	newRecord := newRecordPtr.Interface()
-
-	err := json.Unmarshal(data, &newRecord)
+	newRecord := reflect.New(reflect.TypeOf(record).Elem()).Interface()
+	err := decodeRecord(iter.Value(), newRecord)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal record: %v", err)
 	}
 	return newRecord, nil
 }
 
-func getNextID(db *leveldb.DB, recordType string) (uint64, error) {
-	key := autoIncrementKey(recordType)
-
-	data, err := db.Get([]byte(key), nil)
-	if err != nil {
-		if errors.Is(err, leveldb.ErrNotFound) {
-			return 1, nil
-		}
-		return 0, err
-	}
-
-	return binary.BigEndian.Uint64(data) + 1, nil
-}
-
 func autoIncrementKey(recordType string) string {
 	return fmt.Sprintf("auto_increment_%s", recordType)
 }
 
-func storeRecordWithAutoID(db *leveldb.DB, batch *leveldb.Batch, record types.DbRecordAutoId) error {
-	nextID, err := getNextID(db, record.Prefix())
-	if err != nil {
-		return err
-	}
+func recordCountKey(prefix string) string {
+	return fmt.Sprintf("record_count_%s", prefix)
+}
 
-	record.SetId(nextID)
+// GetAllRecordsWithPrefix loads every record stored under record.Prefix(), in key order.
+func (l *LDB) GetAllRecordsWithPrefix(record types.DbRecordPrefix) ([]interface{}, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
 
-	data, err := json.Marshal(record)
-	if err != nil {
-		return err
-	}
+	iter := l.DB.NewIterator(util.BytesPrefix([]byte(record.Prefix())), nil)
+	defer iter.Release()
 
-	key := record.Key()
+	var records []interface{}
+	recordType := reflect.TypeOf(record).Elem()
+	for iter.Next() {
+		newRecord := reflect.New(recordType).Interface()
+		err := decodeRecord(iter.Value(), newRecord)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal record: %v", err)
+		}
+		records = append(records, newRecord)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, l.readFailed(err)
+	}
+	return records, nil
+}
 
-	batch.Put([]byte(key), data)
+// ForEachRecord relies on every record key starting with the record type name and "_".
+func (l *LDB) ForEachRecord(record types.DbRecord, fn func(record interface{}) error) error {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
 
-	idData := make([]byte, 8)
-	binary.BigEndian.PutUint64(idData, nextID)
-	batch.Put([]byte(autoIncrementKey(record.Prefix())), idData)
-	return nil
-}
+	iter := l.DB.NewIterator(util.BytesPrefix([]byte(getRecordType(record)+"_")), nil)
+	defer iter.Release()
 
-func StoreRecord(db *leveldb.DB, batch *leveldb.Batch, record types.DbRecord) error {
-	if recordAuto, ok := record.(types.DbRecordAutoId); ok {
-		err := storeRecordWithAutoID(db, batch, recordAuto)
+	recordType := reflect.TypeOf(record).Elem()
+	for iter.Next() {
+		newRecord := reflect.New(recordType).Interface()
+		err := decodeRecord(iter.Value(), newRecord)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to unmarshal record: %v", err)
 		}
-		return nil
-	} else {
-		data, err := json.Marshal(record)
-		if err != nil {
+		if err := fn(newRecord); err != nil {
 			return err
 		}
-
-		key := record.Key()
-
-		batch.Put([]byte(key), data)
-		return db.Write(batch, nil)
 	}
+	return l.readFailed(iter.Error())
 }
 
-func SaveIdRecord(batch *leveldb.Batch, record types.DbRecordAutoId) error {
-	data, err := json.Marshal(record)
-	if err != nil {
-		return err
+// readFailed asks a read-only database to reopen when a read hit a table the writer has
+// compacted away. Missing files are reported by readOnlyStorage already, but goleveldb
+// may also see a half-replaced table as corrupted.
+func (l *LDB) readFailed(err error) error {
+	if err != nil && leveldberrors.IsCorrupted(err) {
+		signalStale(l.stale)
 	}
-
-	key := record.Key()
-
-	batch.Put([]byte(key), data)
-	return nil
+	return err
 }
@@ -1,77 +1,25 @@
 package db
 
 import (
+	"errors"
 	"fmt"
-	"github.com/syndtr/goleveldb/leveldb"
 	"mtt-indexer/types"
+	"strings"
 	"testing"
 	"time"
 )
 
-var (
-	tailFix = "main"
-)
-
-func TestDb(t *testing.T) {
-	db := NewLdb(tailFix)
-
-	delegator := ""
-	recordsIFace, total, err := db.GetAllRecordsWithAutoId(&types.DelegatorRecord{}, 10, 0, false)
-	if err != nil {
-		t.Error(err)
-	} else {
-		for _, record := range recordsIFace {
-			if delegatorRecord, ok := record.(*types.DelegatorRecord); ok {
-				fmt.Printf("Delegator Record: %+v\n", delegatorRecord)
-				delegator = delegatorRecord.Delegator
-			}
-		}
-	}
-	fmt.Printf("total: %d\n", total)
-
-	recordsIFace, total, err = db.GetAllRecordsWithAutoId(&types.ValidatorRecord{}, 10, 0, false)
-	if err != nil {
-		t.Error(err)
-	} else {
-		for _, record := range recordsIFace {
-			if delegatorRecord, ok := record.(*types.ValidatorRecord); ok {
-				fmt.Printf("ValidatorRecord Record: %+v\n", delegatorRecord)
-			}
-		}
-	}
-	fmt.Printf("total: %d\n", total)
-
-	outList := &types.DelegatorOutList{
-		Delegator: delegator,
-	}
-	record, err := db.GetRecordByType(outList)
-	if err != nil {
-		t.Error(err)
-	}
-	if record != nil {
-		if delegatorOutList, ok := record.(*types.DelegatorOutList); ok {
-			outList = delegatorOutList
-		}
-	}
+var errTest = errors.New("test error")
 
-	chain := &types.Chain{
-		Name: "mtt",
-	}
-	recordInterface, err := db.GetRecordByType(chain)
-	if err != nil {
-		return
-	}
-	if record != nil {
-		if chainRecord, ok := recordInterface.(*types.Chain); ok {
-			chain = chainRecord
-		}
-	}
-	fmt.Printf("outList: %+v\n", chain)
+func newTestLdb(t *testing.T) *LDB {
+	t.Helper()
+	db := NewLdb(LdbConfig{Path: t.TempDir()})
+	t.Cleanup(func() { db.Close() })
+	return db
 }
 
-func TestDbH(t *testing.T) {
-	db := NewLdb(tailFix)
-
+func storeGenesis(t *testing.T, db *LDB) *types.ValidatorRecord {
+	t.Helper()
 	time, _ := time.Parse(time.RFC3339, "2024-06-11T10:51:01.477179159Z")
 	vRecord := &types.ValidatorRecord{
 		Delegator:      "mtt10wpwl4mqpgdgz8597kphgahx3a8degvg58kjx5",
@@ -82,12 +30,12 @@ func TestDbH(t *testing.T) {
 		DelegationType: types.Delegate,
 		DelegationTime: time,
 	}
-	err := db.Transaction(func(db *LDB, batch *leveldb.Batch) error {
-		err := StoreRecord(db.DB, batch, vRecord)
+	err := db.Transaction(func(view View) error {
+		err := view.StoreRecord(vRecord)
 		if err != nil {
 			return err
 		}
-		err = StoreRecord(db.DB, batch, vRecord.ToDelegate())
+		err = view.StoreRecord(vRecord.ToDelegate())
 		if err != nil {
 			return err
 		}
@@ -99,7 +47,7 @@ func TestDbH(t *testing.T) {
 		}
 
 		outList.AddValidatorRecord(*vRecord, true)
-		err = StoreRecord(db.DB, batch, outList)
+		err = view.StoreRecord(outList)
 		if err != nil {
 			return err
 		}
@@ -109,7 +57,7 @@ func TestDbH(t *testing.T) {
 			Commission: 0.1,
 			Time:       time,
 		}
-		err = StoreRecord(db.DB, batch, commissionRecord)
+		err = view.StoreRecord(commissionRecord)
 		if err != nil {
 			return err
 		}
@@ -118,51 +66,229 @@ func TestDbH(t *testing.T) {
 			Name:    "mtt",
 			Rpc:     "https://cosmos-rpc.mtt.network:443",
 			ChainID: "mtt_6880-1",
-			//Height:  5054844,
-			Height: 5199872,
-		}
-		err = StoreRecord(db.DB, batch, chain)
-		if err != nil {
-			return err
+			Height:  5199872,
 		}
+		return view.StoreRecord(chain)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vRecord
+}
 
-		stake := &types.Stake{
-			Amount: "1000000000000000000000000",
-		}
-		err = StoreRecord(db.DB, batch, stake)
-		if err != nil {
+func TestDb(t *testing.T) {
+	db := newTestLdb(t)
+	vRecord := storeGenesis(t, db)
+
+	recordsIFace, total, err := db.GetAllRecordsWithAutoId(&types.DelegatorRecord{Delegator: vRecord.Delegator}, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(recordsIFace) != 1 {
+		t.Fatalf("got %d delegator records, total %d, want 1", len(recordsIFace), total)
+	}
+	delegatorRecord, ok := recordsIFace[0].(*types.DelegatorRecord)
+	if !ok || delegatorRecord.Amount != vRecord.Amount || !delegatorRecord.DelegationTime.Equal(vRecord.DelegationTime) {
+		t.Fatalf("got delegator record %+v", recordsIFace[0])
+	}
+
+	recordsIFace, total, err = db.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: vRecord.Validator}, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(recordsIFace) != 1 {
+		t.Fatalf("got %d validator records, total %d, want 1", len(recordsIFace), total)
+	}
+
+	record, err := db.GetRecordByType(&types.DelegatorOutList{Delegator: vRecord.Delegator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outList, ok := record.(*types.DelegatorOutList)
+	if !ok || len(outList.Validators) != 1 || outList.Amounts[0] != vRecord.Amount {
+		t.Fatalf("got out list %+v", record)
+	}
+
+	record, err = db.GetRecordByType(&types.Chain{Name: "mtt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain, ok := record.(*types.Chain); !ok || chain.Height != 5199872 {
+		t.Fatalf("got chain %+v", record)
+	}
+}
+
+func TestDbMissingRecord(t *testing.T) {
+	db := newTestLdb(t)
+
+	record, err := db.GetRecordByType(&types.DelegatorOutList{Delegator: "mtt12x07g3270742n42heupleuwvjuzn5j6x4dmysj"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := record.(*types.DelegatorOutList); ok {
+		t.Fatalf("got %+v for a missing record", record)
+	}
+}
+
+func TestDbTransactionRollsBackOnError(t *testing.T) {
+	db := newTestLdb(t)
+
+	outList := &types.DelegatorOutList{
+		Delegator:  "mtt12x07g3270742n42heupleuwvjuzn5j6x4dmysj",
+		Validators: []string{"mttvaloper12x07g3270742n42heupleuwvjuzn5j6x2ekcn0"},
+		Amounts:    []string{"1000000000000000000000000"},
+		Denom:      "amtt",
+	}
+	err := db.Transaction(func(view View) error {
+		if err := view.StoreRecord(outList); err != nil {
 			return err
 		}
+		return errTest
+	})
+	if err != errTest {
+		t.Fatalf("got error %v, want %v", err, errTest)
+	}
+
+	record, err := db.GetRecordByType(&types.DelegatorOutList{Delegator: outList.Delegator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := record.(*types.DelegatorOutList); ok {
+		t.Fatal("record of a failed transaction was stored")
+	}
+}
 
+// storeValidatorRecords stores count records of validator in one transaction.
+func storeValidatorRecords(t *testing.T, db *LDB, validator string, count int) {
+	t.Helper()
+	err := db.Transaction(func(view View) error {
+		for i := 0; i < count; i++ {
+			if err := view.StoreRecord(&types.ValidatorRecord{Validator: validator}); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 	if err != nil {
-		return
+		t.Fatal(err)
 	}
-
-	//2024-06-11T10:51:01.477179159Z
 }
 
-func TestDbH2(t *testing.T) {
-	db := NewLdb(tailFix)
+func TestDbCursorPaging(t *testing.T) {
+	db := newTestLdb(t)
+	storeValidatorRecords(t, db, testKeysValidator, 10)
+	storeValidatorRecords(t, db, testKeysValidator+"0", 3)
+	record := &types.ValidatorRecord{Validator: testKeysValidator}
 
-	err := db.Transaction(func(db *LDB, batch *leveldb.Batch) error {
-		chain := &types.DelegatorOutList{
-			Delegator:  "mtt12x07g3270742n42heupleuwvjuzn5j6x4dmysj",
-			Validators: []string{"mttvaloper12x07g3270742n42heupleuwvjuzn5j6x2ekcn0"},
-			Amounts:    []string{"1000000000000000000000000"},
-			Denom:      "amtt",
+	for _, test := range []struct {
+		ascending bool
+		offset    int
+		want      string
+	}{
+		{true, 0, "[1 2 3 4] [5 6 7 8] [9 10]"},
+		{false, 0, "[10 9 8 7] [6 5 4 3] [2 1]"},
+		// Offset skips records after the cursor on every page
+		{true, 1, "[2 3 4 5] [7 8 9 10]"},
+		{false, 3, "[7 6 5 4] []"},
+	} {
+		var pages []string
+		cursor := ""
+		for {
+			records, next, total, err := db.GetRecordsWithAutoIdCursor(record, 4, test.offset, cursor, test.ascending)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 10 {
+				t.Errorf("got total %d, want 10", total)
+			}
+			pages = append(pages, fmt.Sprint(validatorIds(records)))
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if got := strings.Join(pages, " "); got != test.want {
+			t.Errorf("ascending %v, offset %d: got pages %s, want %s", test.ascending, test.offset, got, test.want)
 		}
-		err := StoreRecord(db.DB, batch, chain)
+	}
+
+	// Offset paging agrees with the cursor
+	records, total, err := db.GetAllRecordsWithAutoId(record, 4, 8, true)
+	if err != nil || total != 10 || fmt.Sprint(validatorIds(records)) != "[9 10]" {
+		t.Errorf("got ids %v, total %d (%v) for the last offset page", validatorIds(records), total, err)
+	}
+	records, _, err = db.GetAllRecordsWithAutoId(record, 4, 10, false)
+	if err != nil || len(records) != 0 {
+		t.Errorf("got ids %v (%v) past the last record", validatorIds(records), err)
+	}
+
+	_, next, _, err := db.GetRecordsWithAutoIdCursor(&types.ValidatorRecord{Validator: testKeysValidator + "0"}, 1, 0, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cursor := range []string{next, "not a cursor", EncodeCursor([]byte(record.Prefix()))} {
+		if _, _, _, err := db.GetRecordsWithAutoIdCursor(record, 4, 0, cursor, true); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: got error %v, want %v", cursor, err, ErrInvalidCursor)
+		}
+	}
+}
+
+func TestDbRecordCount(t *testing.T) {
+	db := newTestLdb(t)
+	storeValidatorRecords(t, db, testKeysValidator, 3)
+	record := &types.ValidatorRecord{Validator: testKeysValidator}
+
+	total := func() int {
+		t.Helper()
+		_, _, total, err := db.GetRecordsWithAutoIdCursor(record, 1, 0, "", true)
 		if err != nil {
-			return err
+			t.Fatal(err)
 		}
+		return total
+	}
 
-		return nil
+	// Deleting a missing record leaves the count alone
+	err := db.Transaction(func(view View) error {
+		if err := view.DeleteRecord(&types.ValidatorRecord{ID: 2, Validator: testKeysValidator}); err != nil {
+			return err
+		}
+		return view.DeleteRecord(&types.ValidatorRecord{ID: 7, Validator: testKeysValidator})
 	})
 	if err != nil {
-		return
+		t.Fatal(err)
+	}
+	if got := total(); got != 2 {
+		t.Errorf("got count %d after deleting a record, want 2", got)
 	}
 
-	//2024-06-11T10:51:01.477179159Z
+	// Undoing a commit restores the count with the records
+	err = db.TransactionWithUndo(1, 0, func(view View) error {
+		if err := view.StoreRecord(&types.ValidatorRecord{Validator: testKeysValidator}); err != nil {
+			return err
+		}
+		return view.DeleteRecord(&types.ValidatorRecord{ID: 1, Validator: testKeysValidator})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := total(); got != 2 {
+		t.Errorf("got count %d after storing and deleting a record, want 2", got)
+	}
+	undoLogs, err := db.GetUndoLogs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Transaction(func(view View) error {
+		return view.Undo(undoLogs[0])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, count, err := db.GetAllRecordsWithAutoId(record, 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || fmt.Sprint(validatorIds(records)) != "[1 3]" {
+		t.Errorf("got ids %v, count %d after the undo, want [1 3] and 2", validatorIds(records), count)
+	}
 }
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"mtt-indexer/types"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// keySeparator ends the prefix of an auto-ID key. Prefixes are built from type names and
+// bech32 addresses, which never contain it, so one prefix can never match the keys of a
+// longer one (e.g. an address that extends another).
+const keySeparator = 0x00
+
+const autoIdLength = 8
+
+// AutoIdPrefix returns the key prefix under which all records of prefix are stored.
+func AutoIdPrefix(prefix string) []byte {
+	key := make([]byte, 0, len(prefix)+1)
+	key = append(key, prefix...)
+	return append(key, keySeparator)
+}
+
+// AutoIdKey encodes prefix and id so that LevelDB key order matches id order.
+func AutoIdKey(prefix string, id uint64) []byte {
+	key := make([]byte, 0, len(prefix)+1+autoIdLength)
+	key = append(key, AutoIdPrefix(prefix)...)
+	return binary.BigEndian.AppendUint64(key, id)
+}
+
+// RecordKey returns the key record is stored under.
+func RecordKey(record types.DbRecord) []byte {
+	if recordAuto, ok := record.(types.DbRecordAutoId); ok {
+		return AutoIdKey(recordAuto.Prefix(), recordAuto.GetId())
+	}
+	if recordHeight, ok := record.(types.DbRecordHeight); ok {
+		return AutoIdKey(recordHeight.Prefix(), uint64(recordHeight.GetHeight()))
+	}
+	return []byte(record.Key())
+}
+
+func isAutoIdKey(key []byte) bool {
+	return len(key) > autoIdLength && key[len(key)-autoIdLength-1] == keySeparator
+}
+
+// EncodeCursor turns the key of the last record of a page into an opaque cursor.
+func EncodeCursor(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeCursor returns the key held by cursor, which must belong to prefix.
+func DecodeCursor(prefix string, cursor string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !isAutoIdKey(key) || !bytes.HasPrefix(key, AutoIdPrefix(prefix)) || len(key) != len(prefix)+1+autoIdLength {
+		return nil, ErrInvalidCursor
+	}
+	return key, nil
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mtt-indexer/types"
+	"testing"
+)
+
+const testKeysValidator = "mttvaloper12x07g3270742n42heupleuwvjuzn5j6x2ekcn0"
+
+func TestAutoIdKeyOrder(t *testing.T) {
+	prefix := (&types.ValidatorRecord{Validator: testKeysValidator}).Prefix()
+	for _, ids := range [][2]uint64{{9, 10}, {99, 100}, {999, 1000}, {255, 256}, {1<<32 - 1, 1 << 32}} {
+		if bytes.Compare(AutoIdKey(prefix, ids[0]), AutoIdKey(prefix, ids[1])) >= 0 {
+			t.Errorf("key of %d does not sort before the key of %d", ids[0], ids[1])
+		}
+	}
+
+	// A validator whose address extends another one never lands in its range
+	longer := (&types.ValidatorRecord{Validator: testKeysValidator + "0"}).Prefix()
+	if bytes.HasPrefix(AutoIdKey(longer, 1), AutoIdPrefix(prefix)) {
+		t.Error("keys of a longer prefix fall under the shorter one")
+	}
+}
+
+// validatorIds returns the ids of a page of validator records.
+func validatorIds(records []interface{}) []uint64 {
+	ids := make([]uint64, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.(*types.ValidatorRecord).ID)
+	}
+	return ids
+}
+
+// checkIdOrder pages through the records of testKeysValidator with the cursor and checks
+// they come in id order across the digit-length boundaries of their old text keys.
+func checkIdOrder(t *testing.T, db *LDB, count int) {
+	t.Helper()
+	for _, ascending := range []bool{true, false} {
+		var ids []uint64
+		cursor := ""
+		for {
+			records, next, total, err := db.GetRecordsWithAutoIdCursor(&types.ValidatorRecord{Validator: testKeysValidator}, 7, 0, cursor, ascending)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != count {
+				t.Fatalf("got total %d, want %d", total, count)
+			}
+			ids = append(ids, validatorIds(records)...)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		if len(ids) != count {
+			t.Fatalf("got %d records, want %d", len(ids), count)
+		}
+		for i, id := range ids {
+			want := uint64(i + 1)
+			if !ascending {
+				want = uint64(count - i)
+			}
+			if id != want {
+				t.Fatalf("ascending %v: got id %d at position %d, want %d", ascending, id, i, want)
+			}
+		}
+	}
+}
+
+func TestAutoIdRecordsIterateInIdOrder(t *testing.T) {
+	db := newTestLdb(t)
+	err := db.Transaction(func(view View) error {
+		for i := 0; i < 105; i++ {
+			if err := view.StoreRecord(&types.ValidatorRecord{Validator: testKeysValidator, TxHash: fmt.Sprintf("tx%d", i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkIdOrder(t, db, 105)
+
+	records, _, err := db.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: testKeysValidator}, 3, 8, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(validatorIds(records)) != "[9 10 11]" {
+		t.Errorf("got ids %v across 9 and 10, want [9 10 11]", validatorIds(records))
+	}
+	records, _, err = db.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: testKeysValidator}, 3, 4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(validatorIds(records)) != "[101 100 99]" {
+		t.Errorf("got ids %v across 100 and 99, want [101 100 99]", validatorIds(records))
+	}
+}
+
+func TestMigratedAutoIdKeysIterateInIdOrder(t *testing.T) {
+	db := newTestLdb(t)
+
+	// Records under their legacy text keys, which sort 10 before 9 and 100 before 99
+	prefix := (&types.ValidatorRecord{Validator: testKeysValidator}).Prefix()
+	for id := uint64(1); id <= 105; id++ {
+		record := &types.ValidatorRecord{ID: id, Validator: testKeysValidator, TxHash: fmt.Sprintf("tx%d", id)}
+		data, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.DB.Put([]byte(record.Key()), data, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	undoLog, err := json.Marshal(&types.UndoLog{Height: 7, ID: 1, MaxHeight: 7, Entries: []types.UndoEntry{{Key: []byte(prefix + "_100")}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string][]byte{
+		"UndoLog__1":             undoLog,
+		autoIncrementKey(prefix): Uint64ToBytes(105),
+		schemaVersionKey:         Uint64ToBytes(0),
+	} {
+		if err := db.DB.Put([]byte(key), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reports, err := db.RunMigrations(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) == 0 || reports[0].Version != 1 || reports[0].Changes != 107 {
+		t.Fatalf("got reports %+v, want 105 records and an undo log moved, and the log rewritten", reports)
+	}
+
+	checkIdOrder(t, db, 105)
+
+	undoLogs, err := db.GetUndoLogs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undoLogs) != 1 || !bytes.Equal(undoLogs[0].Entries[0].Key, AutoIdKey(prefix, 100)) {
+		t.Errorf("got undo logs %+v, want the log moved and its entry rewritten to the new key", undoLogs)
+	}
+
+	// New records continue after the migrated ones
+	err = db.Transaction(func(view View) error {
+		return view.StoreRecord(&types.ValidatorRecord{Validator: testKeysValidator, TxHash: "tx106"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIdOrder(t, db, 106)
+}
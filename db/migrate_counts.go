@@ -0,0 +1,36 @@
+package db
+
+import (
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"strings"
+)
+
+// migrateRecordCounts initialises the record count of every auto-ID prefix. Every prefix
+// that ever stored a record has an auto_increment key, so those keys enumerate the
+// prefixes to count.
+func migrateRecordCounts(l *LDB, w *migrationWriter) (int, error) {
+	autoIncrementPrefix := autoIncrementKey("")
+	changes := 0
+
+	iter := w.NewIterator(util.BytesPrefix([]byte(autoIncrementPrefix)))
+	defer iter.Release()
+	for iter.Next() {
+		prefix := strings.TrimPrefix(string(iter.Key()), autoIncrementPrefix)
+
+		count := uint64(0)
+		records := w.NewIterator(util.BytesPrefix(AutoIdPrefix(prefix)))
+		for records.Next() {
+			count++
+		}
+		records.Release()
+		if err := records.Error(); err != nil {
+			return changes, err
+		}
+
+		if err := w.Put([]byte(recordCountKey(prefix)), Uint64ToBytes(count)); err != nil {
+			return changes, err
+		}
+		changes++
+	}
+	return changes, iter.Error()
+}
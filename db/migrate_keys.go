@@ -0,0 +1,127 @@
+package db
+
+import (
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"mtt-indexer/types"
+	"strconv"
+	"strings"
+)
+
+// legacyAutoIdTypes are the type prefixes of records that used to be stored under
+// "<Prefix>_<id>" text keys.
+var legacyAutoIdTypes = []string{
+	"ValidatorRecord_",
+	"DelegatorRecord_",
+	"CommissionRecord_",
+	"RewardRecord_",
+	"ValidatorIncident_",
+	"UndoLog_",
+}
+
+// legacyHeightTypes are the prefixes of types.DbRecordHeight records that used to be
+// stored under "<Prefix><height>" text keys.
+var legacyHeightTypes = []string{
+	"FailedBlock_",
+}
+
+// legacyAutoIdKey converts a legacy text key of an auto-ID or height record to its
+// AutoIdKey encoding.
+func legacyAutoIdKey(key []byte) ([]byte, bool) {
+	if isAutoIdKey(key) {
+		return nil, false
+	}
+
+	text := string(key)
+	for _, typePrefix := range legacyHeightTypes {
+		if strings.HasPrefix(text, typePrefix) {
+			height, err := strconv.ParseUint(text[len(typePrefix):], 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			return AutoIdKey(typePrefix, height), true
+		}
+	}
+
+	legacy := false
+	for _, typePrefix := range legacyAutoIdTypes {
+		if strings.HasPrefix(text, typePrefix) {
+			legacy = true
+			break
+		}
+	}
+	if !legacy {
+		return nil, false
+	}
+
+	separator := strings.LastIndex(text, "_")
+	id, err := strconv.ParseUint(text[separator+1:], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return AutoIdKey(text[:separator], id), true
+}
+
+// migrateAutoIdKeys moves auto-ID and height records from their legacy text keys to
+// AutoIdKey keys and rewrites the keys held in undo logs.
+func migrateAutoIdKeys(l *LDB, w *migrationWriter) (int, error) {
+	changes := 0
+
+	typePrefixes := append(append([]string{}, legacyAutoIdTypes...), legacyHeightTypes...)
+	for _, typePrefix := range typePrefixes {
+		iter := w.NewIterator(util.BytesPrefix([]byte(typePrefix)))
+		for iter.Next() {
+			newKey, ok := legacyAutoIdKey(iter.Key())
+			if !ok {
+				continue
+			}
+			if err := w.Put(newKey, iter.Value()); err != nil {
+				iter.Release()
+				return changes, err
+			}
+			if err := w.Delete(iter.Key()); err != nil {
+				iter.Release()
+				return changes, err
+			}
+			changes++
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return changes, err
+		}
+	}
+
+	// The undo logs moved above are read back
+	if err := w.Flush(); err != nil {
+		return changes, err
+	}
+
+	iter := w.NewIterator(util.BytesPrefix([]byte("UndoLog_")))
+	defer iter.Release()
+	for iter.Next() {
+		undoLog := &types.UndoLog{}
+		if err := decodeRecord(iter.Value(), undoLog); err != nil {
+			return changes, err
+		}
+
+		changed := false
+		for i, entry := range undoLog.Entries {
+			if newKey, ok := legacyAutoIdKey(entry.Key); ok {
+				undoLog.Entries[i].Key = newKey
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		data, err := l.encodeRecord(undoLog)
+		if err != nil {
+			return changes, err
+		}
+		if err := w.Put(iter.Key(), data); err != nil {
+			return changes, err
+		}
+		changes++
+	}
+	return changes, iter.Error()
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"mtt-indexer/logger"
+)
+
+const schemaVersionKey = "schema_version"
+
+const migrationBatchSize = 1000
+
+// Migration converts a LevelDB database from schema version Version-1 to Version.
+// Run reads and applies it through w and returns the number of keys it changed. Writes
+// are batched, so Run must not rely on reading its own writes back, but it does see
+// everything the migrations before it wrote, also in a dry run.
+type Migration struct {
+	Version     uint64
+	Description string
+	Run         func(l *LDB, w *migrationWriter) (int, error)
+}
+
+// migrations are applied in order. Append new migrations with the next version whenever
+// the stored layout of a record changes.
+var migrations = []Migration{
+	{Version: 1, Description: "move auto-ID and height records to order-preserving keys", Run: migrateAutoIdKeys},
+	{Version: 2, Description: "initialise per-prefix record counts", Run: migrateRecordCounts},
+}
+
+// LatestSchemaVersion is the schema version written by this build.
+func LatestSchemaVersion() uint64 {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationReport describes a migration that was (or in a dry run would be) applied.
+type MigrationReport struct {
+	Version     uint64
+	Description string
+	Changes     int
+}
+
+// migrationDB is what migrations run against: the database, or in a dry run a
+// transaction that is discarded once every migration ran.
+type migrationDB interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+	Write(batch *leveldb.Batch, wo *opt.WriteOptions) error
+}
+
+// migrationWriter batches the writes of a migration and flushes them every
+// migrationBatchSize operations. Migrations must be safe to re-run after an interrupted
+// flush, since the schema version is only raised once the migration completed.
+type migrationWriter struct {
+	db    migrationDB
+	batch *leveldb.Batch
+}
+
+func (w *migrationWriter) Get(key []byte) ([]byte, error) {
+	return w.db.Get(key, nil)
+}
+
+func (w *migrationWriter) NewIterator(slice *util.Range) iterator.Iterator {
+	return w.db.NewIterator(slice, nil)
+}
+
+func (w *migrationWriter) Put(key, value []byte) error {
+	w.batch.Put(append([]byte{}, key...), append([]byte{}, value...))
+	return w.flushIfFull()
+}
+
+func (w *migrationWriter) Delete(key []byte) error {
+	w.batch.Delete(append([]byte{}, key...))
+	return w.flushIfFull()
+}
+
+func (w *migrationWriter) flushIfFull() error {
+	if w.batch.Len() < migrationBatchSize {
+		return nil
+	}
+	return w.Flush()
+}
+
+func (w *migrationWriter) Flush() error {
+	if w.batch.Len() == 0 {
+		return nil
+	}
+	err := w.db.Write(w.batch, nil)
+	w.batch.Reset()
+	return err
+}
+
+// SchemaVersion returns the schema version of the database, 0 for databases written
+// before versions were stored.
+func (l *LDB) SchemaVersion() (uint64, error) {
+	version, err := l.getU64(schemaVersionKey)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// RunMigrations applies every migration above the stored schema version in order,
+// raising the version after each one. With dryRun the migrations run in a transaction
+// that is discarded, so nothing is written and the reports tell what would change.
+// goleveldb spills large transactions to table files, which are removed again.
+func (l *LDB) RunMigrations(dryRun bool) ([]MigrationReport, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	version, err := l.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > LatestSchemaVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than %d supported by this build", version, LatestSchemaVersion())
+	}
+
+	var db migrationDB = l.DB
+	if dryRun {
+		tr, err := l.DB.OpenTransaction()
+		if err != nil {
+			return nil, err
+		}
+		defer tr.Discard()
+		db = tr
+	}
+
+	var reports []MigrationReport
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+
+		w := &migrationWriter{db: db, batch: new(leveldb.Batch)}
+		changes, err := migration.Run(l, w)
+		if err != nil {
+			return reports, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		reports = append(reports, MigrationReport{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Changes:     changes,
+		})
+
+		if err := w.Put([]byte(schemaVersionKey), Uint64ToBytes(migration.Version)); err != nil {
+			return reports, err
+		}
+		if err := w.Flush(); err != nil {
+			return reports, err
+		}
+		if dryRun {
+			continue
+		}
+		logger.Logger.Infof("Applied migration %d (%s), %d keys changed", migration.Version, migration.Description, changes)
+	}
+	return reports, nil
+}
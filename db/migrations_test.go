@@ -0,0 +1,247 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"mtt-indexer/types"
+	"testing"
+)
+
+// useMigrations replaces the migrations of this build for the duration of the test.
+func useMigrations(t *testing.T, list []Migration) {
+	t.Helper()
+	saved := migrations
+	migrations = list
+	t.Cleanup(func() { migrations = saved })
+}
+
+// dump returns every key and value of the database.
+func dump(t *testing.T, db *LDB) map[string]string {
+	t.Helper()
+	values := map[string]string{}
+	iter := db.DB.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		values[string(iter.Key())] = string(iter.Value())
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func setSchemaVersion(t *testing.T, db *LDB, version uint64) {
+	t.Helper()
+	if err := db.DB.Put([]byte(schemaVersionKey), Uint64ToBytes(version), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// countMigration counts the keys under prefix, which an earlier migration writes.
+func countMigration(prefix string) func(l *LDB, w *migrationWriter) (int, error) {
+	return func(l *LDB, w *migrationWriter) (int, error) {
+		iter := w.NewIterator(util.BytesPrefix([]byte(prefix)))
+		defer iter.Release()
+		count := 0
+		for iter.Next() {
+			count++
+		}
+		return count, iter.Error()
+	}
+}
+
+func TestRunMigrations(t *testing.T) {
+	db := newTestLdb(t)
+	useMigrations(t, []Migration{
+		{Version: 1, Description: "write", Run: func(l *LDB, w *migrationWriter) (int, error) {
+			for i := 0; i < 3; i++ {
+				if err := w.Put([]byte(fmt.Sprintf("migrated_%d", i)), []byte{1}); err != nil {
+					return 0, err
+				}
+			}
+			return 3, nil
+		}},
+		{Version: 2, Description: "count", Run: countMigration("migrated_")},
+	})
+	setSchemaVersion(t, db, 0)
+	before := dump(t, db)
+
+	// A dry run of 2 sees what 1 would have written, and nothing is kept
+	reports, err := db.RunMigrations(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(reports) != "[{1 write 3} {2 count 3}]" {
+		t.Errorf("got dry run reports %v", reports)
+	}
+	if after := dump(t, db); fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Errorf("dry run changed the database to %v", after)
+	}
+
+	reports, err = db.RunMigrations(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(reports) != "[{1 write 3} {2 count 3}]" {
+		t.Errorf("got reports %v", reports)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version != 2 {
+		t.Errorf("got schema version %d (%v), want 2", version, err)
+	}
+
+	// Nothing is pending any more
+	reports, err = db.RunMigrations(false)
+	if err != nil || len(reports) != 0 {
+		t.Errorf("got reports %v (%v) for an up to date database", reports, err)
+	}
+}
+
+func TestRunMigrationsStopsAtFailure(t *testing.T) {
+	db := newTestLdb(t)
+	runs := 0
+	useMigrations(t, []Migration{
+		{Version: 1, Description: "ok", Run: func(l *LDB, w *migrationWriter) (int, error) {
+			runs++
+			return 0, nil
+		}},
+		{Version: 2, Description: "failing", Run: func(l *LDB, w *migrationWriter) (int, error) {
+			return 0, errTest
+		}},
+	})
+	setSchemaVersion(t, db, 0)
+
+	reports, err := db.RunMigrations(false)
+	if err == nil || len(reports) != 1 {
+		t.Fatalf("got reports %v and error %v, want migration 2 to fail", reports, err)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version != 1 {
+		t.Errorf("got schema version %d (%v), want 1 below the failed migration", version, err)
+	}
+
+	// A re-run resumes at the failed migration
+	if _, err := db.RunMigrations(false); err == nil {
+		t.Fatal("failing migration passed")
+	}
+	if runs != 1 {
+		t.Errorf("migration 1 ran %d times", runs)
+	}
+
+	setSchemaVersion(t, db, 3)
+	if _, err := db.RunMigrations(false); err == nil {
+		t.Error("migrated a database newer than this build")
+	}
+}
+
+// storeLegacyDatabase writes a database as it was stored before schema versions, with
+// auto-ID records, undo logs and failed blocks under text keys.
+func storeLegacyDatabase(t *testing.T, db *LDB) {
+	t.Helper()
+	values := map[string][]byte{}
+	put := func(key string, record interface{}) {
+		data, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values[key] = data
+	}
+
+	prefix := (&types.ValidatorRecord{Validator: testKeysValidator}).Prefix()
+	for id := uint64(1); id <= 12; id++ {
+		record := &types.ValidatorRecord{ID: id, Validator: testKeysValidator, TxHash: fmt.Sprintf("tx%d", id)}
+		put(record.Key(), record)
+	}
+	values[autoIncrementKey(prefix)] = Uint64ToBytes(12)
+	for id, height := range []int64{9, 10} {
+		undoLog := &types.UndoLog{ID: uint64(id + 1), Height: height, MaxHeight: height, Entries: []types.UndoEntry{{Key: []byte(fmt.Sprintf("%s_%d", prefix, height))}}}
+		put(undoLog.Key(), undoLog)
+	}
+	values[autoIncrementKey((&types.UndoLog{}).Prefix())] = Uint64ToBytes(2)
+	for _, height := range []int64{100, 9} {
+		failedBlock := &types.FailedBlock{Height: height, Attempts: 1}
+		put(failedBlock.Key(), failedBlock)
+	}
+
+	for key, value := range values {
+		if err := db.DB.Put([]byte(key), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setSchemaVersion(t, db, 0)
+}
+
+func TestMigrationsDryRunMatchesRun(t *testing.T) {
+	db := newTestLdb(t)
+	storeLegacyDatabase(t, db)
+	before := dump(t, db)
+
+	dryReports, err := db.RunMigrations(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := dump(t, db); fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Error("dry run changed the database")
+	}
+
+	reports, err := db.RunMigrations(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != len(migrations) || fmt.Sprint(dryReports) != fmt.Sprint(reports) {
+		t.Errorf("dry run reported %v, the migrations %v", dryReports, reports)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version != LatestSchemaVersion() {
+		t.Errorf("got schema version %d (%v), want %d", version, err, LatestSchemaVersion())
+	}
+}
+
+func TestMigrationsAreIdempotent(t *testing.T) {
+	db := newTestLdb(t)
+	storeLegacyDatabase(t, db)
+	if _, err := db.RunMigrations(false); err != nil {
+		t.Fatal(err)
+	}
+	migrated := dump(t, db)
+
+	// Re-running every migration, as after a lost schema version, changes nothing
+	setSchemaVersion(t, db, 0)
+	if _, err := db.RunMigrations(false); err != nil {
+		t.Fatal(err)
+	}
+	again := dump(t, db)
+	if len(again) != len(migrated) {
+		t.Fatalf("got %d keys after re-running, want %d", len(again), len(migrated))
+	}
+	for key, value := range migrated {
+		if !bytes.Equal([]byte(again[key]), []byte(value)) {
+			t.Errorf("key %q changed when re-running the migrations", key)
+		}
+	}
+
+	records, total, err := db.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: testKeysValidator}, 20, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 12 || len(records) != 12 || fmt.Sprint(validatorIds(records)) != "[1 2 3 4 5 6 7 8 9 10 11 12]" {
+		t.Errorf("got ids %v, total %d", validatorIds(records), total)
+	}
+	undoLogs, err := db.GetUndoLogs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undoLogs) != 2 || undoLogs[0].Height != 10 || undoLogs[1].Height != 9 ||
+		!bytes.Equal(undoLogs[0].Entries[0].Key, AutoIdKey((&types.ValidatorRecord{Validator: testKeysValidator}).Prefix(), 10)) {
+		t.Errorf("got undo logs %+v", undoLogs)
+	}
+	failedBlocks, err := db.GetAllRecordsWithPrefix(&types.FailedBlock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failedBlocks) != 2 || failedBlocks[0].(*types.FailedBlock).Height != 9 || failedBlocks[1].(*types.FailedBlock).Height != 100 {
+		t.Errorf("got failed blocks %+v, want them moved to height order", failedBlocks)
+	}
+	if record, err := db.GetRecordByType(&types.FailedBlock{Height: 100}); err != nil || record.(*types.FailedBlock).Attempts != 1 {
+		t.Errorf("got failed block %+v (%v) by height", record, err)
+	}
+}
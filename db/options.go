@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"time"
+)
+
+const (
+	CompressionSnappy = "snappy"
+	CompressionNone   = "none"
+)
+
+const defaultRefreshInterval = 10 * time.Second
+
+// LdbConfig locates the LevelDB database and tunes the engine. Zero values keep the
+// goleveldb defaults.
+type LdbConfig struct {
+	// Path is the database directory, $HOME/.mtt_index_<TailFix> when empty
+	Path    string
+	TailFix string
+
+	// BlockCacheSize and WriteBuffer are in MiB
+	BlockCacheSize  int
+	WriteBuffer     int
+	Compression     string
+	BloomFilterBits int
+	OpenFilesLimit  int
+
+	// Encoding is how records are written, json (default) or binary. Values in either
+	// encoding are read, so it can be switched on an existing database.
+	Encoding string
+
+	// ReadOnly opens the database without taking its lock, so it can be served while
+	// another process indexes into it. The view is reopened every RefreshInterval.
+	ReadOnly        bool
+	RefreshInterval time.Duration
+}
+
+// Dir returns the database directory.
+func (c LdbConfig) Dir() string {
+	if c.Path != "" {
+		return c.Path
+	}
+	return LdbPath(c.TailFix)
+}
+
+func (c LdbConfig) options() (*opt.Options, error) {
+	o := &opt.Options{
+		BlockCacheCapacity:     c.BlockCacheSize * opt.MiB,
+		WriteBuffer:            c.WriteBuffer * opt.MiB,
+		OpenFilesCacheCapacity: c.OpenFilesLimit,
+		ReadOnly:               c.ReadOnly,
+	}
+	if c.BloomFilterBits > 0 {
+		o.Filter = filter.NewBloomFilter(c.BloomFilterBits)
+	}
+	switch c.Compression {
+	case "", CompressionSnappy:
+	case CompressionNone:
+		o.Compression = opt.NoCompression
+	default:
+		return nil, fmt.Errorf("unknown leveldb compression %q, expected %s or %s", c.Compression, CompressionSnappy, CompressionNone)
+	}
+	return o, nil
+}
+
+func (c LdbConfig) encoding() (string, error) {
+	switch c.Encoding {
+	case "", EncodingJSON:
+		return EncodingJSON, nil
+	case EncodingBinary:
+		return EncodingBinary, nil
+	default:
+		return "", fmt.Errorf("unknown record encoding %q, expected %s or %s", c.Encoding, EncodingJSON, EncodingBinary)
+	}
+}
+
+func (c LdbConfig) refreshInterval() time.Duration {
+	if c.RefreshInterval > 0 {
+		return c.RefreshInterval
+	}
+	return defaultRefreshInterval
+}
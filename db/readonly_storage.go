@@ -0,0 +1,159 @@
+package db
+
+import (
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readOnlyStorage serves the files of a LevelDB directory without taking its LOCK file.
+// goleveldb's own read-only mode still takes a shared lock, which fails while the
+// indexer holds the exclusive one. Only the writer ever changes the directory, so
+// skipping the lock is safe as long as nothing is written through this storage.
+//
+// The writer does not know about readers and deletes table files once it compacted them.
+// A read that needs such a file fails, and the database has to be reopened to see the
+// tables that replaced it. Tables are opened lazily, so this is only noticed on read.
+type readOnlyStorage struct {
+	path string
+	// stale is signalled when a file is missing, nil when nobody reopens the database
+	stale chan<- struct{}
+}
+
+func newReadOnlyStorage(path string, stale chan<- struct{}) (*readOnlyStorage, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	return &readOnlyStorage{path: path, stale: stale}, nil
+}
+
+type noopLocker struct{}
+
+func (noopLocker) Unlock() {}
+
+func (s *readOnlyStorage) Lock() (storage.Locker, error) {
+	return noopLocker{}, nil
+}
+
+func (s *readOnlyStorage) Log(string) {}
+
+// GetMeta reads CURRENT. Unlike the file storage it does not recover from a crash
+// during a manifest switch; the writer does that the next time it opens the database.
+func (s *readOnlyStorage) GetMeta() (storage.FileDesc, error) {
+	data, err := os.ReadFile(filepath.Join(s.path, "CURRENT"))
+	if err != nil {
+		return storage.FileDesc{}, err
+	}
+	name := strings.TrimSuffix(string(data), "\n")
+	fd, ok := parseFileName(name)
+	if !ok || fd.Type != storage.TypeManifest {
+		return storage.FileDesc{}, &storage.ErrCorrupted{Err: fmt.Errorf("CURRENT points to %q", name)}
+	}
+	return fd, nil
+}
+
+func (s *readOnlyStorage) List(ft storage.FileType) ([]storage.FileDesc, error) {
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return nil, err
+	}
+	var fds []storage.FileDesc
+	for _, entry := range entries {
+		if fd, ok := parseFileName(entry.Name()); ok && fd.Type&ft != 0 {
+			fds = append(fds, fd)
+		}
+	}
+	return fds, nil
+}
+
+func (s *readOnlyStorage) Open(fd storage.FileDesc) (storage.Reader, error) {
+	file, err := os.Open(filepath.Join(s.path, fileName(fd)))
+	if err != nil && fd.Type == storage.TypeTable && os.IsNotExist(err) {
+		// Tables written by older LevelDB versions use the .sst extension
+		if file, oldErr := os.Open(filepath.Join(s.path, fmt.Sprintf("%06d.sst", fd.Num))); oldErr == nil {
+			return file, nil
+		}
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			signalStale(s.stale)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// signalStale asks for a reopen without blocking, one pending request is enough.
+func signalStale(stale chan<- struct{}) {
+	if stale == nil {
+		return
+	}
+	select {
+	case stale <- struct{}{}:
+	default:
+	}
+}
+
+func (s *readOnlyStorage) SetMeta(storage.FileDesc) error {
+	return leveldb.ErrReadOnly
+}
+
+func (s *readOnlyStorage) Create(storage.FileDesc) (storage.Writer, error) {
+	return nil, leveldb.ErrReadOnly
+}
+
+func (s *readOnlyStorage) Remove(storage.FileDesc) error {
+	return leveldb.ErrReadOnly
+}
+
+func (s *readOnlyStorage) Rename(storage.FileDesc, storage.FileDesc) error {
+	return leveldb.ErrReadOnly
+}
+
+func (s *readOnlyStorage) Close() error {
+	return nil
+}
+
+// fileName and parseFileName follow goleveldb's file storage naming.
+func fileName(fd storage.FileDesc) string {
+	switch fd.Type {
+	case storage.TypeManifest:
+		return fmt.Sprintf("MANIFEST-%06d", fd.Num)
+	case storage.TypeJournal:
+		return fmt.Sprintf("%06d.log", fd.Num)
+	case storage.TypeTable:
+		return fmt.Sprintf("%06d.ldb", fd.Num)
+	default:
+		return fmt.Sprintf("%06d.tmp", fd.Num)
+	}
+}
+
+func parseFileName(name string) (storage.FileDesc, bool) {
+	var fd storage.FileDesc
+	var tail string
+	if _, err := fmt.Sscanf(name, "%d.%s", &fd.Num, &tail); err == nil {
+		switch tail {
+		case "log":
+			fd.Type = storage.TypeJournal
+		case "ldb", "sst":
+			fd.Type = storage.TypeTable
+		case "tmp":
+			fd.Type = storage.TypeTemp
+		default:
+			return fd, false
+		}
+		return fd, true
+	}
+	if n, _ := fmt.Sscanf(name, "MANIFEST-%d%s", &fd.Num, &tail); n == 1 {
+		fd.Type = storage.TypeManifest
+		return fd, true
+	}
+	return fd, false
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"mtt-indexer/types"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyStorageReportsMissingFiles(t *testing.T) {
+	stale := make(chan struct{}, 1)
+	stor, err := newReadOnlyStorage(t.TempDir(), stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := stor.Open(storage.FileDesc{Type: storage.TypeTable, Num: 7}); err == nil {
+			t.Fatal("opened a table that does not exist")
+		}
+	}
+	select {
+	case <-stale:
+	default:
+		t.Fatal("a missing table did not ask for a reopen")
+	}
+	select {
+	case <-stale:
+		t.Error("got a second reopen request pending")
+	default:
+	}
+}
+
+func TestReadOnlyReopensWhenStale(t *testing.T) {
+	dir := t.TempDir()
+	writer := NewLdb(LdbConfig{Path: dir})
+	t.Cleanup(func() { writer.Close() })
+	err := writer.Transaction(func(view View) error {
+		return view.StoreRecord(&types.BlockHash{Height: 1, Hash: "hash1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := OpenLdb(LdbConfig{Path: dir, ReadOnly: true, RefreshInterval: time.Hour})
+	t.Cleanup(func() { reader.Close() })
+	err = writer.Transaction(func(view View) error {
+		return view.StoreRecord(&types.BlockHash{Height: 2, Hash: "hash2"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only a stale signal reopens the reader before the hour is up
+	signalStale(reader.stale)
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		record, err := reader.GetRecordByType(&types.BlockHash{Height: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := record.(*types.BlockHash); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the reader did not reopen")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"mtt-indexer/logger"
+	"mtt-indexer/types"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	recordTypesOnce   sync.Once
+	recordTypesByName map[string]reflect.Type
+)
+
+// recordTypeOf returns the record type stored under key. Record keys start with the type
+// name and "_", the counters and the schema version are not records.
+func recordTypeOf(key []byte) (reflect.Type, bool) {
+	recordTypesOnce.Do(func() {
+		recordTypesByName = map[string]reflect.Type{}
+		for _, record := range recordTypes {
+			recordTypesByName[getRecordType(record)] = reflect.TypeOf(record).Elem()
+		}
+	})
+	name, _, ok := strings.Cut(string(key), "_")
+	if !ok {
+		return nil, false
+	}
+	recordType, ok := recordTypesByName[name]
+	return recordType, ok
+}
+
+// Recode rewrites every record stored in another encoding than the one the database was
+// opened with and returns the number of values rewritten. The record values held in undo
+// logs are rewritten too, so rolling back does not bring the old encoding back.
+func (l *LDB) Recode() (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	w := &migrationWriter{db: l.DB, batch: new(leveldb.Batch)}
+	changes := 0
+	iter := l.DB.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		value, changed, err := l.recodeValue(iter.Key(), iter.Value())
+		if err != nil {
+			return changes, fmt.Errorf("failed to recode %q: %w", iter.Key(), err)
+		}
+		if !changed {
+			continue
+		}
+		if err := w.Put(iter.Key(), value); err != nil {
+			return changes, err
+		}
+		changes++
+		if changes%100000 == 0 {
+			logger.Logger.Infof("Recoded %d records", changes)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return changes, err
+	}
+	return changes, w.Flush()
+}
+
+func (l *LDB) recodeValue(key, value []byte) ([]byte, bool, error) {
+	recordType, ok := recordTypeOf(key)
+	if !ok || value == nil {
+		return value, false, nil
+	}
+
+	record := reflect.New(recordType).Interface()
+	if err := decodeRecord(value, record); err != nil {
+		return nil, false, err
+	}
+	changed := !isEncodedAs(value, l.encoding)
+
+	if undoLog, ok := record.(*types.UndoLog); ok {
+		for i, entry := range undoLog.Entries {
+			entryValue, entryChanged, err := l.recodeValue(entry.Key, entry.Value)
+			if err != nil {
+				return nil, false, err
+			}
+			if entryChanged {
+				undoLog.Entries[i].Value = entryValue
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return value, false, nil
+	}
+
+	data, err := l.encodeRecord(record)
+	return data, true, err
+}
@@ -0,0 +1,758 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	_ "github.com/lib/pq"
+	"mtt-indexer/types"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// recordTypes are every record type passed to a Store. The SQL backend keeps one table per
+// type and LevelDB recodes them by the type name their keys start with.
+var recordTypes = []types.DbRecord{
+	&types.Chain{},
+	&types.BlockHash{},
+	&types.BlockEventRecord{},
+	&types.FailedBlock{},
+	&types.UndoLog{},
+	&types.ValidatorRecord{},
+	&types.DelegatorRecord{},
+	&types.CommissionRecord{},
+	&types.ValidatorCommission{},
+	&types.DelegatorOutList{},
+	&types.RewardRecord{},
+	&types.Claimed24H{},
+	&types.CommissionWithdrawn{},
+	&types.CommissionWithdrawal{},
+	&types.TransferRecord{},
+	&types.Proposal{},
+	&types.ProposalEvent{},
+	&types.ProposalVote{},
+	&types.VoterVote{},
+	&types.ValidatorVote{},
+	&types.CurrentVote{},
+	&types.AuthorizationRecord{},
+	&types.UnbondingEntry{},
+	&types.ValidatorUnbondingEntry{},
+	&types.ValidatorIncident{},
+	&types.ValidatorLiveness{},
+	&types.ValidatorConsAddress{},
+	&types.ValidatorOperator{},
+	&types.RawBlock{},
+}
+
+// Every table has these columns besides one column per exported field of the record.
+const (
+	sqlKeyColumn    = "record_key"
+	sqlPrefixColumn = "record_prefix"
+)
+
+const sqlAutoIncrementTable = "auto_increment"
+
+// sqlUndoSeparator separates the table from the record key in undo log entries.
+const sqlUndoSeparator = "\x00"
+
+var timeType = reflect.TypeOf(time.Time{})
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// sqlTable describes how a record type maps to its table.
+type sqlTable struct {
+	name       string
+	recordType reflect.Type
+	fields     []int
+	columns    []string
+}
+
+func newSQLTable(record types.DbRecord) *sqlTable {
+	recordType := reflect.TypeOf(record).Elem()
+	table := &sqlTable{
+		name:       snakeCase(recordType.Name()),
+		recordType: recordType,
+	}
+	for i := 0; i < recordType.NumField(); i++ {
+		if !recordType.Field(i).IsExported() {
+			continue
+		}
+		table.fields = append(table.fields, i)
+		table.columns = append(table.columns, snakeCase(recordType.Field(i).Name))
+	}
+	return table
+}
+
+func (t *sqlTable) isAutoId() bool {
+	_, ok := reflect.New(t.recordType).Interface().(types.DbRecordAutoId)
+	return ok
+}
+
+func (t *sqlTable) createStatements() []string {
+	columns := []string{
+		fmt.Sprintf("%q TEXT PRIMARY KEY", sqlKeyColumn),
+		fmt.Sprintf("%q TEXT NOT NULL DEFAULT ''", sqlPrefixColumn),
+	}
+	for i, field := range t.fields {
+		columns = append(columns, fmt.Sprintf("%q %s NOT NULL", t.columns[i], sqlColumnType(t.recordType.Field(field).Type)))
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %q (%s)", t.name, strings.Join(columns, ", ")),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %q ON %q (%q)", t.name+"_prefix", t.name, sqlPrefixColumn),
+	}
+	// Fields appended to a record type after its table was created get their column here,
+	// with the zero value for the rows stored before
+	for i, field := range t.fields {
+		columnType := sqlColumnType(t.recordType.Field(field).Type)
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %q ADD COLUMN IF NOT EXISTS %q %s NOT NULL DEFAULT %s", t.name, t.columns[i], columnType, sqlColumnDefault(columnType)))
+	}
+	if t.isAutoId() {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %q ON %q (%q, %q)", t.name+"_prefix_id", t.name, sqlPrefixColumn, "id"))
+	}
+	return statements
+}
+
+func sqlColumnType(fieldType reflect.Type) string {
+	if fieldType == timeType {
+		return "TIMESTAMPTZ"
+	}
+	if fieldType == bytesType {
+		return "BYTEA"
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		return "TEXT"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "BIGINT"
+	case reflect.Float32, reflect.Float64:
+		return "DOUBLE PRECISION"
+	default:
+		return "JSONB"
+	}
+}
+
+func sqlColumnDefault(columnType string) string {
+	switch columnType {
+	case "TIMESTAMPTZ":
+		return "'0001-01-01 00:00:00+00'"
+	case "TEXT", "BYTEA":
+		return "''"
+	case "BOOLEAN":
+		return "FALSE"
+	case "BIGINT", "DOUBLE PRECISION":
+		return "0"
+	default:
+		return "'null'"
+	}
+}
+
+// values returns the column values of record in the order of t.columns.
+func (t *sqlTable) values(record types.DbRecord) ([]interface{}, error) {
+	value := reflect.ValueOf(record).Elem()
+	values := make([]interface{}, 0, len(t.fields))
+	for _, field := range t.fields {
+		fieldValue := value.Field(field)
+		switch sqlColumnType(fieldValue.Type()) {
+		case "TIMESTAMPTZ":
+			values = append(values, fieldValue.Interface().(time.Time))
+		case "TEXT":
+			values = append(values, fieldValue.String())
+		case "BOOLEAN":
+			values = append(values, fieldValue.Bool())
+		case "BIGINT":
+			if fieldValue.CanInt() {
+				values = append(values, fieldValue.Int())
+			} else {
+				values = append(values, int64(fieldValue.Uint()))
+			}
+		case "DOUBLE PRECISION":
+			values = append(values, fieldValue.Float())
+		case "BYTEA":
+			values = append(values, fieldValue.Bytes())
+		default:
+			data, err := json.Marshal(fieldValue.Interface())
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, string(data))
+		}
+	}
+	return values, nil
+}
+
+// scan reads one row selected with t.selectColumns() into a new record.
+func (t *sqlTable) scan(rows interface{ Scan(...interface{}) error }) (types.DbRecord, error) {
+	record := reflect.New(t.recordType)
+	targets := make([]interface{}, len(t.fields))
+	for i, field := range t.fields {
+		switch sqlColumnType(t.recordType.Field(field).Type) {
+		case "TIMESTAMPTZ":
+			targets[i] = new(time.Time)
+		case "TEXT":
+			targets[i] = new(string)
+		case "BOOLEAN":
+			targets[i] = new(bool)
+		case "BIGINT":
+			targets[i] = new(int64)
+		case "DOUBLE PRECISION":
+			targets[i] = new(float64)
+		default:
+			targets[i] = new([]byte)
+		}
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return nil, err
+	}
+
+	for i, field := range t.fields {
+		fieldValue := record.Elem().Field(field)
+		switch target := targets[i].(type) {
+		case *time.Time:
+			fieldValue.Set(reflect.ValueOf(*target))
+		case *string:
+			fieldValue.SetString(*target)
+		case *bool:
+			fieldValue.SetBool(*target)
+		case *int64:
+			if fieldValue.CanInt() {
+				fieldValue.SetInt(*target)
+			} else {
+				fieldValue.SetUint(uint64(*target))
+			}
+		case *float64:
+			fieldValue.SetFloat(*target)
+		case *[]byte:
+			if fieldValue.Type() == bytesType {
+				fieldValue.SetBytes(*target)
+				continue
+			}
+			if err := json.Unmarshal(*target, fieldValue.Addr().Interface()); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %s.%s: %v", t.name, t.columns[i], err)
+			}
+		}
+	}
+	return record.Interface().(types.DbRecord), nil
+}
+
+func (t *sqlTable) selectColumns() string {
+	quoted := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		quoted[i] = fmt.Sprintf("%q", column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (t *sqlTable) upsertStatement() string {
+	columns := []string{fmt.Sprintf("%q", sqlKeyColumn), fmt.Sprintf("%q", sqlPrefixColumn)}
+	placeholders := []string{"$1", "$2"}
+	updates := []string{fmt.Sprintf("%q = EXCLUDED.%q", sqlPrefixColumn, sqlPrefixColumn)}
+	for i, column := range t.columns {
+		columns = append(columns, fmt.Sprintf("%q", column))
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+3))
+		updates = append(updates, fmt.Sprintf("%q = EXCLUDED.%q", column, column))
+	}
+	return fmt.Sprintf("INSERT INTO %q (%s) VALUES (%s) ON CONFLICT (%q) DO UPDATE SET %s",
+		t.name, strings.Join(columns, ", "), strings.Join(placeholders, ", "), sqlKeyColumn, strings.Join(updates, ", "))
+}
+
+// snakeCase turns a Go identifier into a table or column name: ValidatorRecord -> validator_record, ID -> id.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// SQLStore keeps records in PostgreSQL, one table per record type with one column per
+// field, so they can be queried directly with SQL.
+type SQLStore struct {
+	db     *sql.DB
+	tables map[reflect.Type]*sqlTable
+	byName map[string]*sqlTable
+}
+
+func NewSQLStore(dsn string) (*SQLStore, error) {
+	if dsn == "" {
+		return nil, errors.New("postgres_dsn must be set for the postgres storage backend")
+	}
+	sqlDB, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	s := &SQLStore{
+		db:     sqlDB,
+		tables: map[reflect.Type]*sqlTable{},
+		byName: map[string]*sqlTable{},
+	}
+	for _, record := range recordTypes {
+		table := newSQLTable(record)
+		s.tables[table.recordType] = table
+		s.byName[table.name] = table
+	}
+	return s, nil
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Migrate creates the missing tables and indexes.
+func (s *SQLStore) Migrate() error {
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %q (%q TEXT PRIMARY KEY, %q BIGINT NOT NULL)", sqlAutoIncrementTable, sqlPrefixColumn, "value"),
+	}
+	for _, record := range recordTypes {
+		statements = append(statements, s.tables[reflect.TypeOf(record).Elem()].createStatements()...)
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil {
+			return fmt.Errorf("%s: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) table(record interface{}) (*sqlTable, error) {
+	table, ok := s.tables[reflect.TypeOf(record).Elem()]
+	if !ok {
+		return nil, fmt.Errorf("record type %T is not registered with the SQL store", record)
+	}
+	return table, nil
+}
+
+// sqlQuerier is implemented by *sql.DB and *sql.Tx.
+type sqlQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *SQLStore) getRecord(q sqlQuerier, record types.DbRecord) (types.DbRecord, error) {
+	table, err := s.table(record)
+	if err != nil {
+		return nil, err
+	}
+	row := q.QueryRow(fmt.Sprintf("SELECT %s FROM %q WHERE %q = $1", table.selectColumns(), table.name, sqlKeyColumn), sqlRecordKey(record))
+	stored, err := table.scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return stored, err
+}
+
+// getRecordByType mirrors LDB.GetRecordByType, including returning 1 when nothing is stored.
+func (s *SQLStore) getRecordByType(q sqlQuerier, record types.DbRecord) (interface{}, error) {
+	stored, err := s.getRecord(q, record)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return 1, nil
+	}
+	return stored, nil
+}
+
+// getAllRecordsWithPrefix returns the records stored with the same Prefix() as record.
+func (s *SQLStore) getAllRecordsWithPrefix(q sqlQuerier, record types.DbRecordPrefix) ([]interface{}, error) {
+	table, err := s.table(record)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.Query(fmt.Sprintf("SELECT %s FROM %q WHERE %q = $1 ORDER BY %q COLLATE \"C\"", table.selectColumns(), table.name, sqlPrefixColumn, sqlKeyColumn), record.Prefix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []interface{}
+	for rows.Next() {
+		stored, err := table.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, stored)
+	}
+	return records, rows.Err()
+}
+
+func (s *SQLStore) GetRecordByType(record types.DbRecord) (interface{}, error) {
+	return s.getRecordByType(s.db, record)
+}
+
+func (s *SQLStore) GetAllRecordsWithPrefix(record types.DbRecordPrefix) ([]interface{}, error) {
+	return s.getAllRecordsWithPrefix(s.db, record)
+}
+
+func (s *SQLStore) ForEachRecord(record types.DbRecord, fn func(record interface{}) error) error {
+	table, err := s.table(record)
+	if err != nil {
+		return err
+	}
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %s FROM %q ORDER BY %q COLLATE \"C\"", table.selectColumns(), table.name, sqlKeyColumn))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		stored, err := table.scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(stored); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLStore) GetAllRecordsWithAutoId(record types.DbRecordAutoId, limit, offset int, ascending bool) ([]interface{}, int, error) {
+	records, _, total, err := s.GetRecordsWithAutoIdCursor(record, limit, offset, "", ascending)
+	return records, total, err
+}
+
+func (s *SQLStore) GetRecordsWithAutoIdCursor(record types.DbRecordAutoId, limit, offset int, cursor string, ascending bool) ([]interface{}, string, int, error) {
+	if limit <= 0 {
+		return nil, "", 0, fmt.Errorf("limit must be greater than 0")
+	}
+	if offset < 0 {
+		return nil, "", 0, fmt.Errorf("offset cannot be negative")
+	}
+	table, err := s.table(record)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var total int
+	err = s.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %q WHERE %q = $1", table.name, sqlPrefixColumn), record.Prefix()).Scan(&total)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	order, comparison := "ASC", ">"
+	if !ascending {
+		order, comparison = "DESC", "<"
+	}
+	query := fmt.Sprintf("SELECT %s FROM %q WHERE %q = $1", table.selectColumns(), table.name, sqlPrefixColumn)
+	args := []interface{}{record.Prefix()}
+	if cursor != "" {
+		after, err := DecodeCursor(record.Prefix(), cursor)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		args = append(args, int64(BytesToUint64(after[len(after)-autoIdLength:])))
+		query += fmt.Sprintf(" AND %q %s $2", "id", comparison)
+	}
+	// One extra row tells whether there is a next page
+	query += fmt.Sprintf(" ORDER BY %q %s LIMIT %d OFFSET %d", "id", order, limit+1, offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer rows.Close()
+
+	var records []interface{}
+	next := ""
+	for rows.Next() {
+		if len(records) == limit {
+			last := records[len(records)-1].(types.DbRecordAutoId)
+			next = EncodeCursor(AutoIdKey(last.Prefix(), last.GetId()))
+			break
+		}
+		stored, err := table.scan(rows)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		records = append(records, stored)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", 0, err
+	}
+	return records, next, total, nil
+}
+
+func (s *SQLStore) GetUndoLogs(aboveHeight int64) ([]*types.UndoLog, error) {
+	table, err := s.table(&types.UndoLog{})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %s FROM %q WHERE %q = $1 AND %q > $2 ORDER BY %q DESC", table.selectColumns(), table.name, sqlPrefixColumn, "max_height", "id"),
+		(&types.UndoLog{}).Prefix(), aboveHeight)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var undoLogs []*types.UndoLog
+	for rows.Next() {
+		stored, err := table.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		undoLogs = append(undoLogs, stored.(*types.UndoLog))
+	}
+	return undoLogs, rows.Err()
+}
+
+func (s *SQLStore) Transaction(fc func(view View) error) error {
+	return s.transaction(nil, 0, fc)
+}
+
+// TransactionWithUndo records the previous state of every row the transaction changes in
+// a new undo log.
+func (s *SQLStore) TransactionWithUndo(height, retention int64, fc func(view View) error) error {
+	return s.transaction(&types.UndoLog{Height: height, MaxHeight: height}, retention, fc)
+}
+
+func (s *SQLStore) transaction(undoLog *types.UndoLog, retention int64, fc func(view View) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	view := &sqlView{store: s, tx: tx}
+	if undoLog != nil {
+		view.undoLog = undoLog
+		view.undone = map[string]bool{}
+	}
+
+	if err := fc(view); err != nil {
+		return err
+	}
+
+	if undoLog != nil {
+		table, err := s.table(undoLog)
+		if err != nil {
+			return err
+		}
+		var maxHeight int64
+		err = tx.QueryRow(fmt.Sprintf("SELECT %q FROM %q WHERE %q = $1 ORDER BY %q DESC LIMIT 1", "max_height", table.name, sqlPrefixColumn, "id"), undoLog.Prefix()).Scan(&maxHeight)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if maxHeight > undoLog.MaxHeight {
+			undoLog.MaxHeight = maxHeight
+		}
+
+		// The log's own id counter is not part of its entries, so ids keep growing
+		// across rollbacks
+		view.undoLog = nil
+		if err := view.StoreRecord(undoLog); err != nil {
+			return err
+		}
+
+		if retention > 0 {
+			_, err := tx.Exec(fmt.Sprintf("DELETE FROM %q WHERE %q = $1 AND %q <= $2", table.name, sqlPrefixColumn, "max_height"), undoLog.Prefix(), undoLog.MaxHeight-retention)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// sqlView is the SQL View. It runs inside a database transaction, which already makes
+// reads see the transaction's own writes.
+type sqlView struct {
+	store   *SQLStore
+	tx      *sql.Tx
+	undoLog *types.UndoLog
+	undone  map[string]bool
+}
+
+func sqlRecordKey(record types.DbRecord) string {
+	if recordAuto, ok := record.(types.DbRecordAutoId); ok {
+		return fmt.Sprintf("%s_%d", recordAuto.Prefix(), recordAuto.GetId())
+	}
+	return record.Key()
+}
+
+func sqlRecordPrefix(record types.DbRecord) string {
+	if recordPrefix, ok := record.(types.DbRecordPrefix); ok {
+		return recordPrefix.Prefix()
+	}
+	return ""
+}
+
+func (v *sqlView) GetRecordByType(record types.DbRecord) (interface{}, error) {
+	return v.store.getRecordByType(v.tx, record)
+}
+
+func (v *sqlView) GetAllRecordsWithPrefix(record types.DbRecordPrefix) ([]interface{}, error) {
+	return v.store.getAllRecordsWithPrefix(v.tx, record)
+}
+
+func (v *sqlView) StoreRecord(record types.DbRecord) error {
+	if recordAuto, ok := record.(types.DbRecordAutoId); ok {
+		nextID, err := v.nextID(recordAuto.Prefix())
+		if err != nil {
+			return err
+		}
+		recordAuto.SetId(nextID)
+	}
+	return v.save(record)
+}
+
+func (v *sqlView) SaveIdRecord(record types.DbRecordAutoId) error {
+	return v.save(record)
+}
+
+// save stores record under its current key.
+func (v *sqlView) save(record types.DbRecord) error {
+	if err := v.recordUndo(record); err != nil {
+		return err
+	}
+	return v.upsert(record)
+}
+
+func (v *sqlView) upsert(record types.DbRecord) error {
+	table, err := v.store.table(record)
+	if err != nil {
+		return err
+	}
+	values, err := table.values(record)
+	if err != nil {
+		return err
+	}
+	args := append([]interface{}{sqlRecordKey(record), sqlRecordPrefix(record)}, values...)
+	_, err = v.tx.Exec(table.upsertStatement(), args...)
+	return err
+}
+
+func (v *sqlView) DeleteRecord(record types.DbRecord) error {
+	if err := v.recordUndo(record); err != nil {
+		return err
+	}
+	return v.delete(record)
+}
+
+func (v *sqlView) delete(record types.DbRecord) error {
+	table, err := v.store.table(record)
+	if err != nil {
+		return err
+	}
+	_, err = v.tx.Exec(fmt.Sprintf("DELETE FROM %q WHERE %q = $1", table.name, sqlKeyColumn), sqlRecordKey(record))
+	return err
+}
+
+func (v *sqlView) nextID(prefix string) (uint64, error) {
+	var current int64
+	err := v.tx.QueryRow(fmt.Sprintf("SELECT %q FROM %q WHERE %q = $1", "value", sqlAutoIncrementTable, sqlPrefixColumn), prefix).Scan(&current)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	undoKey := sqlAutoIncrementTable + sqlUndoSeparator + prefix
+	if v.undoLog != nil && !v.undone[undoKey] {
+		v.undone[undoKey] = true
+		entry := types.UndoEntry{Key: []byte(undoKey)}
+		if exists {
+			entry.Value = Uint64ToBytes(uint64(current))
+		}
+		v.undoLog.Entries = append(v.undoLog.Entries, entry)
+	}
+
+	next := current + 1
+	_, err = v.tx.Exec(fmt.Sprintf("INSERT INTO %q (%q, %q) VALUES ($1, $2) ON CONFLICT (%q) DO UPDATE SET %q = EXCLUDED.%q",
+		sqlAutoIncrementTable, sqlPrefixColumn, "value", sqlPrefixColumn, "value", "value"), prefix, next)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(next), nil
+}
+
+// recordUndo adds the state of record's row before this block to the undo log, the
+// first time the row is written.
+func (v *sqlView) recordUndo(record types.DbRecord) error {
+	if v.undoLog == nil {
+		return nil
+	}
+	table, err := v.store.table(record)
+	if err != nil {
+		return err
+	}
+	undoKey := table.name + sqlUndoSeparator + sqlRecordKey(record)
+	if v.undone[undoKey] {
+		return nil
+	}
+	v.undone[undoKey] = true
+
+	entry := types.UndoEntry{Key: []byte(undoKey)}
+	stored, err := v.store.getRecord(v.tx, record)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		entry.Value, err = json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+	}
+	v.undoLog.Entries = append(v.undoLog.Entries, entry)
+	return nil
+}
+
+func (v *sqlView) Undo(undoLog *types.UndoLog) error {
+	for i := len(undoLog.Entries) - 1; i >= 0; i-- {
+		entry := undoLog.Entries[i]
+		tableName, key, ok := strings.Cut(string(entry.Key), sqlUndoSeparator)
+		if !ok {
+			return fmt.Errorf("invalid undo log entry %q", entry.Key)
+		}
+
+		if tableName == sqlAutoIncrementTable {
+			var err error
+			if entry.Value == nil {
+				_, err = v.tx.Exec(fmt.Sprintf("DELETE FROM %q WHERE %q = $1", sqlAutoIncrementTable, sqlPrefixColumn), key)
+			} else {
+				_, err = v.tx.Exec(fmt.Sprintf("UPDATE %q SET %q = $2 WHERE %q = $1", sqlAutoIncrementTable, "value", sqlPrefixColumn), key, int64(BytesToUint64(entry.Value)))
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		table, ok := v.store.byName[tableName]
+		if !ok {
+			return fmt.Errorf("undo log entry for unknown table %q", tableName)
+		}
+		if entry.Value == nil {
+			_, err := v.tx.Exec(fmt.Sprintf("DELETE FROM %q WHERE %q = $1", table.name, sqlKeyColumn), key)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		record := reflect.New(table.recordType).Interface().(types.DbRecord)
+		if err := json.Unmarshal(entry.Value, record); err != nil {
+			return err
+		}
+		if err := v.upsert(record); err != nil {
+			return err
+		}
+	}
+	return v.delete(undoLog)
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"fmt"
+	"mtt-indexer/types"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sqlRow hands values returned by sqlTable.values back to sqlTable.scan as the driver would.
+type sqlRow []interface{}
+
+func (r sqlRow) Scan(targets ...interface{}) error {
+	if len(targets) != len(r) {
+		return fmt.Errorf("got %d targets for %d columns", len(targets), len(r))
+	}
+	for i, target := range targets {
+		value := r[i]
+		if text, ok := value.(string); ok {
+			if bytes, ok := target.(*[]byte); ok {
+				*bytes = []byte(text)
+				continue
+			}
+		}
+		reflect.ValueOf(target).Elem().Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"ValidatorRecord":      "validator_record",
+		"ID":                   "id",
+		"TxHash":               "tx_hash",
+		"Claimed24H":           "claimed24h",
+		"RawBlock":             "raw_block",
+		"ValidatorConsAddress": "validator_cons_address",
+	} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSQLTableRoundTripsRecords(t *testing.T) {
+	for _, record := range []types.DbRecord{
+		&types.ValidatorRecord{ID: 7, Delegator: "d", Validator: "v", Amount: "10", DelegationType: types.Undelegate, DelegationTime: time.Unix(1700000000, 0).UTC(), Grantee: "g"},
+		&types.UndoLog{Height: 5, ID: 3, MaxHeight: 5, Entries: []types.UndoEntry{{Key: []byte("k"), Value: []byte("v")}, {Key: []byte("deleted")}}},
+		&types.RawBlock{Height: 9, Block: []byte{1, 2, 3}, Results: []byte{}},
+	} {
+		table := newSQLTable(record)
+		values, err := table.values(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != len(table.columns) {
+			t.Fatalf("%s: got %d values for %d columns", table.name, len(values), len(table.columns))
+		}
+		scanned, err := table.scan(sqlRow(values))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(scanned, record) {
+			t.Errorf("%s: got %+v back, want %+v", table.name, scanned, record)
+		}
+	}
+}
+
+func TestSQLTableStatements(t *testing.T) {
+	table := newSQLTable(&types.ValidatorRecord{})
+	statements := strings.Join(table.createStatements(), ";\n")
+	for _, want := range []string{
+		`CREATE TABLE IF NOT EXISTS "validator_record" ("record_key" TEXT PRIMARY KEY, "record_prefix" TEXT NOT NULL DEFAULT '', "id" BIGINT NOT NULL`,
+		`"delegation_time" TIMESTAMPTZ NOT NULL`,
+		`ADD COLUMN IF NOT EXISTS "grantee" TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS "validator_record_prefix_id"`,
+	} {
+		if !strings.Contains(statements, want) {
+			t.Errorf("create statements miss %s:\n%s", want, statements)
+		}
+	}
+	if strings.Contains(strings.Join(newSQLTable(&types.Chain{}).createStatements(), ";"), "_prefix_id") {
+		t.Error("got an id index for a record type without auto ids")
+	}
+
+	upsert := table.upsertStatement()
+	if !strings.HasPrefix(upsert, `INSERT INTO "validator_record" ("record_key", "record_prefix", "id", `) ||
+		!strings.Contains(upsert, fmt.Sprintf("$%d)", len(table.columns)+2)) ||
+		!strings.Contains(upsert, `ON CONFLICT ("record_key") DO UPDATE SET`) {
+		t.Errorf("got upsert %s", upsert)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	store, err := NewStore("", LdbConfig{Path: t.TempDir()}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*LDB); !ok {
+		t.Errorf("got %T, want LevelDB by default", store)
+	}
+	store.Close()
+
+	if _, err := NewStore(BackendPostgres, LdbConfig{}, ""); err == nil {
+		t.Error("opened postgres without a dsn")
+	}
+	if _, err := NewStore("sqlite", LdbConfig{}, ""); err == nil {
+		t.Error("opened an unknown backend")
+	}
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"fmt"
+	"mtt-indexer/types"
+)
+
+const (
+	BackendLevelDB  = "leveldb"
+	BackendPostgres = "postgres"
+)
+
+// Store is a storage backend for indexed records. Parsers and services only use this
+// interface. LDB (LevelDB) is the default backend, SQLStore keeps records in PostgreSQL.
+type Store interface {
+	// Transaction runs fc against a View and stores everything it wrote atomically, or
+	// nothing when fc returns an error.
+	Transaction(fc func(view View) error) error
+	// TransactionWithUndo runs fc like Transaction and also stores an undo log for the
+	// commit of the block at height. Older undo logs that a rollback by retention blocks
+	// from the top does not need are pruned in the same commit, retention <= 0 keeps them all.
+	TransactionWithUndo(height, retention int64, fc func(view View) error) error
+	GetRecordByType(record types.DbRecord) (interface{}, error)
+	GetAllRecordsWithPrefix(record types.DbRecordPrefix) ([]interface{}, error)
+	GetAllRecordsWithAutoId(record types.DbRecordAutoId, limit, offset int, ascending bool) ([]interface{}, int, error)
+	GetRecordsWithAutoIdCursor(record types.DbRecordAutoId, limit, offset int, cursor string, ascending bool) ([]interface{}, string, int, error)
+	// GetUndoLogs returns, newest first, the undo logs to undo to get back to aboveHeight.
+	GetUndoLogs(aboveHeight int64) ([]*types.UndoLog, error)
+	// ForEachRecord calls fn with every stored record of the type of record, stopping at
+	// the first error. fn must not write to the store.
+	ForEachRecord(record types.DbRecord, fn func(record interface{}) error) error
+	// Migrate brings the storage layout up to date. It is called once at startup.
+	Migrate() error
+	Close() error
+}
+
+// View is the database as seen from inside a transaction. Reads see the writes made
+// earlier in the same transaction, so records stored earlier in the same block (auto IDs,
+// balances) are not read back stale.
+type View interface {
+	GetRecordByType(record types.DbRecord) (interface{}, error)
+	GetAllRecordsWithPrefix(record types.DbRecordPrefix) ([]interface{}, error)
+	// StoreRecord stores record, assigning the next ID to auto-ID records.
+	StoreRecord(record types.DbRecord) error
+	// SaveIdRecord stores an auto-ID record under its current ID without assigning a new one.
+	SaveIdRecord(record types.DbRecordAutoId) error
+	DeleteRecord(record types.DbRecord) error
+	// Undo restores every record in undoLog to its state before the block and removes the log.
+	Undo(undoLog *types.UndoLog) error
+}
+
+// NewStore opens the storage backend selected in the config.
+func NewStore(backend string, ldbCfg LdbConfig, postgresDsn string) (Store, error) {
+	switch backend {
+	case "", BackendLevelDB:
+		return NewLdb(ldbCfg), nil
+	case BackendPostgres:
+		return NewSQLStore(postgresDsn)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"mtt-indexer/types"
+)
+
+// batchKeys collects the distinct keys written or deleted by a batch, in first-write order.
+type batchKeys struct {
+	seen map[string]bool
+	keys []string
+}
+
+func (b *batchKeys) add(key []byte) {
+	if b.seen[string(key)] {
+		return
+	}
+	b.seen[string(key)] = true
+	b.keys = append(b.keys, string(key))
+}
+
+func (b *batchKeys) Put(key, value []byte) {
+	b.add(key)
+}
+
+func (b *batchKeys) Delete(key []byte) {
+	b.add(key)
+}
+
+// TransactionWithUndo runs fc like Transaction and stores, in the same batch, a new undo
+// log for the commit. Nothing is written before the batch commits, so previous values are
+// read from the database as it is.
+func (l *LDB) TransactionWithUndo(height, retention int64, fc func(view View) error) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	view := newView(l)
+	err := fc(view)
+	if err != nil {
+		return err
+	}
+
+	undoLog := &types.UndoLog{Height: height, MaxHeight: height}
+	last, err := l.lastUndoLog()
+	if err != nil {
+		return err
+	}
+	if last != nil && last.MaxHeight > height {
+		undoLog.MaxHeight = last.MaxHeight
+	}
+
+	keys := &batchKeys{seen: map[string]bool{}}
+	if err := view.batch.Replay(keys); err != nil {
+		return err
+	}
+
+	for _, key := range keys.keys {
+		value, err := l.DB.Get([]byte(key), nil)
+		if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+			return err
+		}
+		undoLog.Entries = append(undoLog.Entries, types.UndoEntry{Key: []byte(key), Value: value})
+	}
+
+	// Stored after the replay so the log's own id counter is not part of its entries and
+	// ids keep growing across rollbacks
+	if err := view.StoreRecord(undoLog); err != nil {
+		return err
+	}
+	if retention > 0 {
+		if err := l.pruneUndoLogs(view, undoLog.MaxHeight-retention); err != nil {
+			return err
+		}
+	}
+
+	return l.DB.Write(view.batch, nil)
+}
+
+// pruneUndoLogs deletes the undo logs with a MaxHeight of at most height, which are only
+// needed to roll back below it. MaxHeight never decreases with ID, so they are the oldest
+// logs. The caller holds the lock.
+func (l *LDB) pruneUndoLogs(view *ldbView, height int64) error {
+	iter := l.DB.NewIterator(util.BytesPrefix(AutoIdPrefix((&types.UndoLog{}).Prefix())), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		undoLog := &types.UndoLog{}
+		if err := decodeRecord(iter.Value(), undoLog); err != nil {
+			return err
+		}
+		if undoLog.MaxHeight > height {
+			break
+		}
+		if err := view.DeleteRecord(undoLog); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// lastUndoLog returns the newest undo log, the caller holds the lock.
+func (l *LDB) lastUndoLog() (*types.UndoLog, error) {
+	iter := l.DB.NewIterator(util.BytesPrefix(AutoIdPrefix((&types.UndoLog{}).Prefix())), nil)
+	defer iter.Release()
+
+	if !iter.Last() {
+		return nil, iter.Error()
+	}
+	undoLog := &types.UndoLog{}
+	if err := decodeRecord(iter.Value(), undoLog); err != nil {
+		return nil, err
+	}
+	return undoLog, nil
+}
+
+// GetUndoLogs returns, newest first, the undo logs that have to be undone to bring the
+// database back to aboveHeight: every log from the oldest one with a MaxHeight above it.
+func (l *LDB) GetUndoLogs(aboveHeight int64) ([]*types.UndoLog, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	iter := l.DB.NewIterator(util.BytesPrefix(AutoIdPrefix((&types.UndoLog{}).Prefix())), nil)
+	defer iter.Release()
+
+	var undoLogs []*types.UndoLog
+	for valid := iter.Last(); valid; valid = iter.Prev() {
+		undoLog := &types.UndoLog{}
+		if err := decodeRecord(iter.Value(), undoLog); err != nil {
+			return nil, err
+		}
+		if undoLog.MaxHeight <= aboveHeight {
+			break
+		}
+		undoLogs = append(undoLogs, undoLog)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return undoLogs, nil
+}
+
+func (v *ldbView) Undo(undoLog *types.UndoLog) error {
+	for i := len(undoLog.Entries) - 1; i >= 0; i-- {
+		entry := undoLog.Entries[i]
+		if entry.Value == nil {
+			v.Delete(entry.Key)
+		} else {
+			v.Put(entry.Key, entry.Value)
+		}
+	}
+	return v.DeleteRecord(undoLog)
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"mtt-indexer/types"
+	"reflect"
+	"sort"
+)
+
+// ldbView is the LevelDB View. Writes are collected in the transaction batch and reads
+// see them before the batch is committed.
+type ldbView struct {
+	ldb   *LDB
+	batch *leveldb.Batch
+	// pending holds the latest uncommitted value of every written key, nil for deletes
+	pending map[string][]byte
+}
+
+func newView(l *LDB) *ldbView {
+	return &ldbView{
+		ldb:     l,
+		batch:   new(leveldb.Batch),
+		pending: map[string][]byte{},
+	}
+}
+
+// Get returns the value of key including uncommitted writes, or leveldb.ErrNotFound.
+func (v *ldbView) Get(key []byte) ([]byte, error) {
+	if value, ok := v.pending[string(key)]; ok {
+		if value == nil {
+			return nil, leveldb.ErrNotFound
+		}
+		return value, nil
+	}
+	return v.ldb.DB.Get(key, nil)
+}
+
+func (v *ldbView) Put(key, value []byte) {
+	v.pending[string(key)] = value
+	v.batch.Put(key, value)
+}
+
+func (v *ldbView) Delete(key []byte) {
+	v.pending[string(key)] = nil
+	v.batch.Delete(key)
+}
+
+func (v *ldbView) GetRecordByType(record types.DbRecord) (interface{}, error) {
+	data, err := v.Get(RecordKey(record))
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return 1, nil
+		}
+		return nil, err
+	}
+
+	recordPtr := reflect.New(reflect.TypeOf(record).Elem()).Interface()
+	err = decodeRecord(data, recordPtr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal record: %v", err)
+	}
+	return recordPtr, nil
+}
+
+func (v *ldbView) GetAllRecordsWithPrefix(record types.DbRecordPrefix) ([]interface{}, error) {
+	prefix := []byte(record.Prefix())
+	values := map[string][]byte{}
+
+	iter := v.ldb.DB.NewIterator(util.BytesPrefix(prefix), nil)
+	for iter.Next() {
+		values[string(iter.Key())] = append([]byte{}, iter.Value()...)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	for key, value := range v.pending {
+		if len(key) < len(prefix) || key[:len(prefix)] != string(prefix) {
+			continue
+		}
+		if value == nil {
+			delete(values, key)
+		} else {
+			values[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var records []interface{}
+	recordType := reflect.TypeOf(record).Elem()
+	for _, key := range keys {
+		newRecord := reflect.New(recordType).Interface()
+		err := decodeRecord(values[key], newRecord)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal record: %v", err)
+		}
+		records = append(records, newRecord)
+	}
+	return records, nil
+}
+
+func (v *ldbView) StoreRecord(record types.DbRecord) error {
+	if recordAuto, ok := record.(types.DbRecordAutoId); ok {
+		nextID, err := v.nextID(recordAuto.Prefix())
+		if err != nil {
+			return err
+		}
+		recordAuto.SetId(nextID)
+
+		v.Put([]byte(autoIncrementKey(recordAuto.Prefix())), Uint64ToBytes(nextID))
+
+		if err := v.addRecordCount(recordAuto.Prefix(), 1); err != nil {
+			return err
+		}
+	}
+
+	data, err := v.ldb.encodeRecord(record)
+	if err != nil {
+		return err
+	}
+	v.Put(RecordKey(record), data)
+	return nil
+}
+
+func (v *ldbView) SaveIdRecord(record types.DbRecordAutoId) error {
+	data, err := v.ldb.encodeRecord(record)
+	if err != nil {
+		return err
+	}
+	v.Put(RecordKey(record), data)
+	return nil
+}
+
+// DeleteRecord keeps the record count of auto-ID prefixes up to date.
+func (v *ldbView) DeleteRecord(record types.DbRecord) error {
+	key := RecordKey(record)
+	if recordAuto, ok := record.(types.DbRecordAutoId); ok {
+		_, err := v.Get(key)
+		if err == nil {
+			if err := v.addRecordCount(recordAuto.Prefix(), -1); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, leveldb.ErrNotFound) {
+			return err
+		}
+	}
+	v.Delete(key)
+	return nil
+}
+
+func (v *ldbView) addRecordCount(prefix string, delta int64) error {
+	key := []byte(recordCountKey(prefix))
+	count := uint64(0)
+	data, err := v.Get(key)
+	if err == nil {
+		count = BytesToUint64(data)
+	} else if !errors.Is(err, leveldb.ErrNotFound) {
+		return err
+	}
+	v.Put(key, Uint64ToBytes(uint64(int64(count)+delta)))
+	return nil
+}
+
+func (v *ldbView) nextID(prefix string) (uint64, error) {
+	data, err := v.Get([]byte(autoIncrementKey(prefix)))
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return 1, nil
+		}
+		return 0, err
+	}
+	return BytesToUint64(data) + 1, nil
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"fmt"
+	"mtt-indexer/types"
+	"testing"
+)
+
+func TestViewReadsItsOwnWrites(t *testing.T) {
+	db := newTestLdb(t)
+	err := db.Transaction(func(view View) error {
+		return view.StoreRecord(&types.ValidatorRecord{Validator: testKeysValidator, TxHash: "committed"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Transaction(func(view View) error {
+		// Auto-ID records stored in one transaction get consecutive ids
+		for _, txHash := range []string{"tx2", "tx3"} {
+			if err := view.StoreRecord(&types.ValidatorRecord{Validator: testKeysValidator, TxHash: txHash}); err != nil {
+				return err
+			}
+		}
+		record, err := view.GetRecordByType(&types.ValidatorRecord{ID: 3, Validator: testKeysValidator})
+		if err != nil {
+			return err
+		}
+		if stored, ok := record.(*types.ValidatorRecord); !ok || stored.TxHash != "tx3" {
+			t.Errorf("got %+v for a record stored in the transaction", record)
+		}
+
+		// Deleting a committed record hides it from prefix reads, pending records show up
+		if err := view.DeleteRecord(&types.ValidatorRecord{ID: 1, Validator: testKeysValidator}); err != nil {
+			return err
+		}
+		records, err := view.GetAllRecordsWithPrefix(&types.ValidatorRecord{Validator: testKeysValidator})
+		if err != nil {
+			return err
+		}
+		if len(records) != 2 || fmt.Sprint(validatorIds(records)) != "[2 3]" {
+			t.Errorf("got ids %v in the transaction, want [2 3]", validatorIds(records))
+		}
+
+		// Nothing reaches the database before the commit
+		if _, err := db.DB.Get(RecordKey(&types.ValidatorRecord{ID: 2, Validator: testKeysValidator}), nil); err == nil {
+			t.Error("a pending record was written before the commit")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, total, err := db.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: testKeysValidator}, 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || fmt.Sprint(validatorIds(records)) != "[2 3]" {
+		t.Errorf("got ids %v and total %d after the commit, want [2 3] and 2", validatorIds(records), total)
+	}
+}
@@ -11,18 +11,22 @@ import (
 	"mtt-indexer/logger"
 	"mtt-indexer/parsers"
 	"mtt-indexer/router"
+	"mtt-indexer/rpc"
 	"mtt-indexer/service"
 	"mtt-indexer/types"
 	"mtt-indexer/util"
 	"net/http"
 	"os"
 	"sync"
+	"time"
 )
 
 var (
 	configFlag = flag.String("config", "config.yaml", "Config file")
 )
 
+const chainName = "mtt"
+
 func init() {
 	prefix := os.Getenv("ACCOUNT_PREFIX")
 	// Set prefixes
@@ -44,28 +48,48 @@ func init() {
 }
 
 func main() {
+	flag.Parse()
 	util.LoadConfig(*configFlag, &config.Cfg)
 	cfg := &config.Cfg
-	db := db.NewLdb(cfg.DbTailFix)
 
-	newService := service.NewService(db)
-	engine := router.Init(newService)
-	addr := fmt.Sprintf("0.0.0.0:%d", cfg.Port)
-	srv := &http.Server{
-		Addr:    addr,
-		Handler: engine,
+	// These commands run before the store is opened: opening LevelDB applies pending
+	// migrations and takes the database lock
+	switch flag.Arg(0) {
+	case "migrate":
+		runMigrate(cfg, flag.Args()[1:])
+		return
+	case "backup":
+		runBackup(cfg, flag.Args()[1:])
+		return
+	case "restore":
+		runRestore(cfg, flag.Args()[1:])
+		return
+	case "recode":
+		runRecode(cfg)
+		return
 	}
 
-	go func() {
-		if err := srv.ListenAndServe(); err != nil {
-			logger.Logger.Fatal("listen addr:%s,err:%v", addr, err)
+	store, err := db.NewStore(cfg.Storage, ldbConfig(cfg), cfg.PostgresDsn)
+	if err != nil {
+		logger.Logger.Fatalf("Failed to open storage. Err: %v", err)
+	}
+	if err := store.Migrate(); err != nil {
+		logger.Logger.Fatalf("Failed to migrate storage. Err: %v", err)
+	}
+
+	if cfg.ReadOnly {
+		if flag.Arg(0) != "" {
+			logger.Logger.Fatalf("Command %q cannot run with read_only", flag.Arg(0))
 		}
-	}()
+		logger.Logger.Infof("Serving the API read-only, indexing is left to another process")
+		serveApi(cfg, store)
+		select {}
+	}
 
 	chain := &types.Chain{
-		Name: "mtt",
+		Name: chainName,
 	}
-	record, err := db.GetRecordByType(chain)
+	record, err := store.GetRecordByType(chain)
 	if err != nil {
 		return
 	}
@@ -80,13 +104,91 @@ func main() {
 		logger.Logger.Fatal(err)
 	}
 
-	chainService, err := service.NewChainService(db, chain, cl)
+	chainService, err := service.NewChainService(store, chain, cl, cfg.FetchWorkers, cfg.FetchWindow, cfg.StoreRawBlocks, cfg.FailedBlockMaxAttempts, cfg.UndoRetention)
 	if err != nil {
 		logger.Logger.Fatal(err)
 	}
 
-	go cornjob.CronJobLedgerInit(db, cl)
+	validatorIncidentParser := registerParsers(chainService)
+
+	switch flag.Arg(0) {
+	case "backfill":
+		runBackfill(chainService, flag.Args()[1:])
+		return
+	case "rollback":
+		runRollback(chainService, flag.Args()[1:])
+		return
+	case "verify":
+		runVerify(chainService, flag.Args()[1:])
+		return
+	case "reindex":
+		runReindex(chainService, flag.Args()[1:])
+		return
+	case "":
+	default:
+		logger.Logger.Fatalf("Unknown command %q", flag.Arg(0))
+	}
 
+	slashingParams, err := rpc.GetSlashingParams(cl)
+	if err != nil {
+		logger.Logger.Fatalf("Failed to query slashing params. Err: %v", err)
+	}
+	validatorIncidentParser.DowntimeJailDuration = slashingParams.DowntimeJailDuration
+
+	if err := chainService.PrepareStartHeight(cfg.StartHeight, cfg.SkipPruned); err != nil {
+		logger.Logger.Fatal(err)
+	}
+
+	if err := chainService.SyncValidatorConsAddresses(); err != nil {
+		logger.Logger.Errorf("Failed to sync validator consensus addresses. Err: %v", err)
+	}
+
+	serveApi(cfg, store)
+
+	go cornjob.CronJobLedgerInit(store, cl)
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	chainService.Start(&wg)
+
+	wg.Wait()
+}
+
+func serveApi(cfg *config.Conf, store db.Store) {
+	newService := service.NewService(store)
+	engine := router.Init(newService, cfg.AdminToken, cfg.BackupDir)
+	addr := fmt.Sprintf("0.0.0.0:%d", cfg.Port)
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: engine,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			logger.Logger.Fatal("listen addr:%s,err:%v", addr, err)
+		}
+	}()
+}
+
+func ldbConfig(cfg *config.Conf) db.LdbConfig {
+	return db.LdbConfig{
+		Path:            cfg.DbPath,
+		TailFix:         cfg.DbTailFix,
+		BlockCacheSize:  cfg.Leveldb.BlockCacheMB,
+		WriteBuffer:     cfg.Leveldb.WriteBufferMB,
+		Compression:     cfg.Leveldb.Compression,
+		BloomFilterBits: cfg.Leveldb.BloomFilterBits,
+		OpenFilesLimit:  cfg.Leveldb.OpenFilesLimit,
+		Encoding:        cfg.Leveldb.Encoding,
+		ReadOnly:        cfg.ReadOnly,
+		RefreshInterval: time.Duration(cfg.Leveldb.RefreshSeconds) * time.Second,
+	}
+}
+
+// registerParsers returns the validator incident parser, whose downtime jail duration is
+// only queried when following the chain.
+func registerParsers(chainService *service.ChainService) *parsers.ValidatorIncidentParser {
 	stakingDelegateRegexMessageTypeFilter, err := filter.NewRegexMessageTypeFilter("^/cosmos\\.staking.*MsgDelegate$", false)
 	if err != nil {
 		logger.Logger.Fatalf("Failed to create regex message type filter. Err: %v", err)
@@ -97,7 +199,12 @@ func main() {
 		logger.Logger.Fatalf("Failed to create regex message type filter. Err: %v", err)
 	}
 
-	stakingCreateValidatorTypeFilter, err := filter.NewRegexMessageTypeFilter("^/cosmos\\.staking.*MsgMsgCreateValidator$", false)
+	stakingCreateValidatorTypeFilter, err := filter.NewRegexMessageTypeFilter("^/cosmos\\.staking.*MsgCreateValidator$", false)
+	if err != nil {
+		logger.Logger.Fatalf("Failed to create regex message type filter. Err: %v", err)
+	}
+
+	stakingEditValidatorTypeFilter, err := filter.NewRegexMessageTypeFilter("^/cosmos\\.staking.*MsgEditValidator$", false)
 	if err != nil {
 		logger.Logger.Fatalf("Failed to create regex message type filter. Err: %v", err)
 	}
@@ -122,33 +229,108 @@ func main() {
 		logger.Logger.Fatalf("Failed to create regex message type filter. Err: %v", err)
 	}
 
+	slashingUnjailFilter, err := filter.NewRegexMessageTypeFilter("^/cosmos\\.slashing.*MsgUnjail$", false)
+	if err != nil {
+		logger.Logger.Fatalf("Failed to create regex message type filter. Err: %v", err)
+	}
+
+	bankSendFilter, err := filter.NewRegexMessageTypeFilter("^/cosmos\\.bank.*Msg(Multi)?Send$", false)
+	if err != nil {
+		logger.Logger.Fatalf("Failed to create regex message type filter. Err: %v", err)
+	}
+
 	chainService.RegisterMessageTypeFilter(stakingDelegateRegexMessageTypeFilter)
 	chainService.RegisterMessageTypeFilter(stakingUndelegateRegexMessageTypeFilter)
 	chainService.RegisterMessageTypeFilter(stakingCreateValidatorTypeFilter)
+	chainService.RegisterMessageTypeFilter(stakingEditValidatorTypeFilter)
 	chainService.RegisterMessageTypeFilter(distributionWithdrawDelegatorFilter)
 	chainService.RegisterMessageTypeFilter(stakingCancelUnbondingTypeFilter)
 	chainService.RegisterMessageTypeFilter(redelegateFilter)
 	chainService.RegisterMessageTypeFilter(distributionWithdrawCommissionFilter)
+	chainService.RegisterMessageTypeFilter(slashingUnjailFilter)
+	chainService.RegisterMessageTypeFilter(bankSendFilter)
+
+	govFilter, err := filter.NewRegexMessageTypeFilter("^/cosmos\\.gov.*Msg(SubmitProposal|Deposit|Vote|VoteWeighted)$", false)
+	if err != nil {
+		logger.Logger.Fatalf("Failed to create regex message type filter. Err: %v", err)
+	}
+	chainService.RegisterMessageTypeFilter(govFilter)
+
+	// Messages executed through MsgExec are dispatched to the parsers of their own type
+	authzFilter, err := filter.NewRegexMessageTypeFilter("^/cosmos\\.authz.*Msg(Exec|Grant|Revoke)$", false)
+	if err != nil {
+		logger.Logger.Fatalf("Failed to create regex message type filter. Err: %v", err)
+	}
+	chainService.RegisterMessageTypeFilter(authzFilter)
 
 	delegateParser := &parsers.MsgDelegateUndelegateParser{Id: "delegate"}
 	undelegateParser := &parsers.MsgDelegateUndelegateParser{Id: "undelegate"}
 	createValidatorParser := &parsers.MsgCreateValidatorParser{Id: "validator"}
+	editValidatorParser := &parsers.MsgEditValidatorParser{Id: "editValidator"}
 	withdrawDelegatorRewardParser := &parsers.MsgWithdrawDelegatorRewardParser{Id: "delegatorReward"}
 	cancelUnnbondingParser := &parsers.MsgCancelUnbondingParser{Id: "cancelUnbonding"}
 	redelegateParser := &parsers.MsgRedelegateParser{Id: "redelegate"}
-	withdrawCommissionParser := &parsers.MsgRedelegateParser{Id: "commissionReward"}
+	withdrawCommissionParser := &parsers.MsgWithdrawValidatorCommission{Id: "commissionReward"}
+	unjailParser := &parsers.MsgUnjailParser{Id: "unjail"}
+	transferParser := &parsers.TransferParser{Id: "transfer"}
+	rewardPayoutParser := &parsers.RewardPayoutParser{Id: "rewardPayout"}
+	proposalParser := &parsers.MsgSubmitProposalParser{Id: "proposal"}
+	proposalDepositParser := &parsers.MsgDepositParser{Id: "proposalDeposit"}
+	voteParser := &parsers.MsgVoteParser{Id: "vote"}
+	grantRevokeParser := &parsers.MsgGrantRevokeParser{Id: "authorization"}
 
 	chainService.RegisterCustomMessageParser("/cosmos.staking.v1beta1.MsgDelegate", delegateParser)
 	chainService.RegisterCustomMessageParser("/cosmos.staking.v1beta1.MsgUndelegate", undelegateParser)
 	chainService.RegisterCustomMessageParser("/cosmos.staking.v1beta1.MsgCreateValidator", createValidatorParser)
+	chainService.RegisterCustomMessageParser("/cosmos.staking.v1beta1.MsgEditValidator", editValidatorParser)
 	chainService.RegisterCustomMessageParser("/cosmos.distribution.v1beta1.MsgWithdrawDelegatorReward", withdrawDelegatorRewardParser)
 	chainService.RegisterCustomMessageParser("/cosmos.staking.v1beta1.MsgCancelUnbondingDelegation", cancelUnnbondingParser)
 	chainService.RegisterCustomMessageParser("/cosmos.staking.v1beta1.MsgBeginRedelegate", redelegateParser)
 	chainService.RegisterCustomMessageParser("/cosmos.distribution.v1beta1.MsgWithdrawValidatorCommission", withdrawCommissionParser)
-	var wg sync.WaitGroup
+	chainService.RegisterCustomMessageParser("/cosmos.slashing.v1beta1.MsgUnjail", unjailParser)
 
-	wg.Add(1)
-	chainService.Start(&wg)
+	chainService.RegisterCustomMessageParser("/cosmos.authz.v1beta1.MsgGrant", grantRevokeParser)
+	chainService.RegisterCustomMessageParser("/cosmos.authz.v1beta1.MsgRevoke", grantRevokeParser)
 
-	wg.Wait()
+	// Both gov message versions are accepted by the chain
+	for _, version := range []string{"v1", "v1beta1"} {
+		chainService.RegisterCustomMessageParser("/cosmos.gov."+version+".MsgSubmitProposal", proposalParser)
+		chainService.RegisterCustomMessageParser("/cosmos.gov."+version+".MsgDeposit", proposalDepositParser)
+		chainService.RegisterCustomMessageParser("/cosmos.gov."+version+".MsgVote", voteParser)
+		chainService.RegisterCustomMessageParser("/cosmos.gov."+version+".MsgVoteWeighted", voteParser)
+	}
+
+	chainService.RegisterCustomMessageParser("/cosmos.bank.v1beta1.MsgSend", transferParser)
+	chainService.RegisterCustomMessageParser("/cosmos.bank.v1beta1.MsgMultiSend", transferParser)
+
+	// Rewards paid out by these messages would otherwise be missing from the transfer
+	// history of the receiving account: the withdraw messages, and staking messages that
+	// change a delegation, which withdraw its pending rewards first.
+	for _, messageType := range []string{
+		"/cosmos.distribution.v1beta1.MsgWithdrawDelegatorReward",
+		"/cosmos.distribution.v1beta1.MsgWithdrawValidatorCommission",
+		"/cosmos.staking.v1beta1.MsgDelegate",
+		"/cosmos.staking.v1beta1.MsgUndelegate",
+		"/cosmos.staking.v1beta1.MsgBeginRedelegate",
+		"/cosmos.staking.v1beta1.MsgCancelUnbondingDelegation",
+	} {
+		chainService.RegisterCustomMessageParser(messageType, rewardPayoutParser)
+	}
+
+	completeUnbondingParser := &parsers.CompleteUnbondingParser{Id: "completeUnbonding"}
+
+	chainService.RegisterCustomEndBlockEventParser("complete_unbonding", completeUnbondingParser)
+	chainService.RegisterCustomEndBlockEventParser("complete_redelegation", completeUnbondingParser)
+
+	proposalResultParser := &parsers.ProposalResultParser{Id: "proposalResult"}
+
+	chainService.RegisterCustomEndBlockEventParser("active_proposal", proposalResultParser)
+	chainService.RegisterCustomEndBlockEventParser("inactive_proposal", proposalResultParser)
+
+	validatorIncidentParser := &parsers.ValidatorIncidentParser{Id: "validatorIncident"}
+
+	chainService.RegisterCustomBeginBlockEventParser("slash", validatorIncidentParser)
+	chainService.RegisterCustomBeginBlockEventParser("liveness", validatorIncidentParser)
+
+	return validatorIncidentParser
 }
@@ -0,0 +1,65 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+)
+
+// This defines the custom message parser for the authz grant and revoke message types
+// It implements the MessageParser interface
+type MsgGrantRevokeParser struct {
+	Id string
+}
+
+func (c *MsgGrantRevokeParser) Identifier() string {
+	return c.Id
+}
+
+func (c *MsgGrantRevokeParser) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerTxTypes.LogMessage) (*any, error) {
+	var record types.AuthorizationRecord
+	switch msg := cosmosMsg.(type) {
+	case *authz.MsgGrant:
+		authorization, err := msg.GetAuthorization()
+		if err != nil {
+			return nil, fmt.Errorf("invalid authorization: %w", err)
+		}
+		record = types.AuthorizationRecord{
+			Granter:       msg.Granter,
+			Grantee:       msg.Grantee,
+			Type:          types.AuthorizationGrant,
+			MsgTypeURL:    authorization.MsgTypeURL(),
+			Authorization: msg.Grant.Authorization.TypeUrl,
+		}
+		if msg.Grant.Expiration != nil {
+			record.Expiration = *msg.Grant.Expiration
+		}
+	case *authz.MsgRevoke:
+		record = types.AuthorizationRecord{
+			Granter:    msg.Granter,
+			Grantee:    msg.Grantee,
+			Type:       types.AuthorizationRevoke,
+			MsgTypeURL: msg.MsgTypeUrl,
+		}
+	default:
+		return nil, errors.New("not a grant or revoke message")
+	}
+
+	storageVal := any(record)
+	return &storageVal, nil
+}
+
+func (c *MsgGrantRevokeParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	record, ok := (*dataset).(types.AuthorizationRecord)
+	if !ok {
+		return errors.New("not an AuthorizationRecord type")
+	}
+	record.Height = message.Tx.Block.Height
+	record.TxHash = txhash
+	record.Time = message.Tx.Block.TimeStamp
+	return view.StoreRecord(&record)
+}
@@ -2,13 +2,14 @@ package parsers
 
 import (
 	abci "github.com/cometbft/cometbft/abci/types"
+	"mtt-indexer/db"
 	"mtt-indexer/types"
 )
 
 type BlockEventParser interface {
 	Identifier() string
 	ParseBlockEvent(abci.Event) (*any, error)
-	IndexBlockEvent(*any, types.Block, types.BlockEvent, []types.BlockEventAttribute) error
+	IndexBlockEvent(db.View, *any, types.Block, types.BlockEvent, []types.BlockEventAttribute) error
 }
 
 type BlockEventParsedData struct {
@@ -16,3 +17,13 @@ type BlockEventParsedData struct {
 	Error  error
 	Parser *BlockEventParser
 }
+
+// GetBlockEventAttribute returns the value of key in the event, or "" if it is missing.
+func GetBlockEventAttribute(event abci.Event, key string) string {
+	for _, attr := range event.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	stdTypes "github.com/cosmos/cosmos-sdk/types"
 	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/syndtr/goleveldb/leveldb"
 	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
 	"mtt-indexer/db"
 	"mtt-indexer/types"
@@ -42,26 +41,31 @@ func (c *MsgCancelUnbondingParser) ParseMessage(cosmosMsg stdTypes.Msg, log *ind
 		Base: msg.Amount.Denom,
 	}
 
-	storageVal := any(types.ValidatorRecord{
-		Delegator:      delegator.Address,
-		Validator:      validator.ValidatorAddress.Address,
-		Amount:         amount,
-		Denom:          denom.Base,
-		DelegationType: types.CancelUnbonding,
+	storageVal := any(CancelUnbondingData{
+		ValidatorRecord: types.ValidatorRecord{
+			Delegator:      delegator.Address,
+			Validator:      validator.ValidatorAddress.Address,
+			Amount:         amount,
+			Denom:          denom.Base,
+			DelegationType: types.CancelUnbonding,
+		},
+		CreationHeight: msg.CreationHeight,
 	})
 
 	return &storageVal, nil
 
 }
 
-func (c *MsgCancelUnbondingParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
-	validatorRecord, ok := (*dataset).(types.ValidatorRecord)
+func (c *MsgCancelUnbondingParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	cancelData, ok := (*dataset).(CancelUnbondingData)
 	if !ok {
-		return errors.New("not a ValidatorRecord type")
+		return errors.New("not a CancelUnbondingData type")
 	}
+	validatorRecord := cancelData.ValidatorRecord
 	validatorRecord.TxHash = txhash
 	validatorRecord.DelegationTime = message.Tx.Block.TimeStamp
-	err := db.StoreRecord(ldb.DB, batch, &validatorRecord)
+	validatorRecord.Grantee = message.Grantee
+	err := view.StoreRecord(&validatorRecord)
 	if err != nil {
 		return err
 	}
@@ -69,7 +73,7 @@ func (c *MsgCancelUnbondingParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batc
 	outList := &types.DelegatorOutList{
 		Delegator: validatorRecord.Delegator,
 	}
-	record, err := ldb.GetRecordByType(outList)
+	record, err := view.GetRecordByType(outList)
 	if err != nil {
 		return err
 	}
@@ -86,10 +90,21 @@ func (c *MsgCancelUnbondingParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batc
 		}
 	}
 	outList.AddValidatorRecord(validatorRecord, true)
-	err = db.StoreRecord(ldb.DB, batch, outList)
+	err = view.StoreRecord(outList)
 	if err != nil {
 		return err
 	}
 
-	return db.StoreRecord(ldb.DB, batch, validatorRecord.ToDelegate())
+	err = cancelUnbondingEntry(view, validatorRecord.Delegator, validatorRecord.Validator, cancelData.CreationHeight, validatorRecord.Amount)
+	if err != nil {
+		return err
+	}
+
+	return view.StoreRecord(validatorRecord.ToDelegate())
+}
+
+// CancelUnbondingData carries the creation height that identifies the cancelled unbonding entry.
+type CancelUnbondingData struct {
+	ValidatorRecord types.ValidatorRecord
+	CreationHeight  int64
 }
@@ -0,0 +1,86 @@
+package parsers
+
+import (
+	"errors"
+	abci "github.com/cometbft/cometbft/abci/types"
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+)
+
+// This defines the custom block event parser for the EndBlock complete_unbonding and complete_redelegation events
+// It implements the BlockEventParser interface
+type CompleteUnbondingParser struct {
+	Id string
+}
+
+func (c *CompleteUnbondingParser) Identifier() string {
+	return c.Id
+}
+
+func (c *CompleteUnbondingParser) ParseBlockEvent(event abci.Event) (*any, error) {
+	coin, err := stdTypes.ParseCoinNormalized(GetBlockEventAttribute(event, stdTypes.AttributeKeyAmount))
+	if err != nil {
+		return nil, err
+	}
+
+	switch event.Type {
+	case stakingTypes.EventTypeCompleteUnbonding:
+		storageVal := any(types.ValidatorRecord{
+			Delegator:      GetBlockEventAttribute(event, stakingTypes.AttributeKeyDelegator),
+			Validator:      GetBlockEventAttribute(event, stakingTypes.AttributeKeyValidator),
+			Amount:         coin.Amount.String(),
+			Denom:          coin.Denom,
+			DelegationType: types.CompleteUnbonding,
+		})
+		return &storageVal, nil
+	case stakingTypes.EventTypeCompleteRedelegation:
+		storageVal := any(types.RedelegateRecord{
+			Delegator:      GetBlockEventAttribute(event, stakingTypes.AttributeKeyDelegator),
+			Src:            GetBlockEventAttribute(event, stakingTypes.AttributeKeySrcValidator),
+			Dst:            GetBlockEventAttribute(event, stakingTypes.AttributeKeyDstValidator),
+			Amount:         coin.Amount.String(),
+			Denom:          coin.Denom,
+			DelegationType: types.CompleteRedelegation,
+		})
+		return &storageVal, nil
+	}
+
+	return nil, errors.New("not a complete unbonding or redelegation event")
+}
+
+func (c *CompleteUnbondingParser) IndexBlockEvent(view db.View, dataset *any, block types.Block, event types.BlockEvent, attributes []types.BlockEventAttribute) error {
+	switch record := (*dataset).(type) {
+	case types.ValidatorRecord:
+		record.DelegationTime = block.TimeStamp
+		err := view.StoreRecord(&record)
+		if err != nil {
+			return err
+		}
+		err = view.StoreRecord(record.ToDelegate())
+		if err != nil {
+			return err
+		}
+
+		return completeUnbondingEntries(view, record.Delegator, record.Validator, block.TimeStamp, record.Amount)
+	case types.RedelegateRecord:
+		// The redelegated stake was already moved when the redelegation was submitted,
+		// so completion is only recorded in both histories.
+		validatorRecord := &types.ValidatorRecord{
+			Delegator:      record.Delegator,
+			Validator:      record.Dst,
+			Amount:         record.Amount,
+			Denom:          record.Denom,
+			DelegationType: types.CompleteRedelegation,
+			DelegationTime: block.TimeStamp,
+		}
+		err := view.StoreRecord(validatorRecord)
+		if err != nil {
+			return err
+		}
+		return view.StoreRecord(validatorRecord.ToDelegate())
+	}
+
+	return errors.New("not a ValidatorRecord or RedelegateRecord type")
+}
@@ -0,0 +1,142 @@
+package parsers
+
+import (
+	abci "github.com/cometbft/cometbft/abci/types"
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+	"time"
+)
+
+const (
+	testDelegator      = "mtt12x07g3270742n42heupleuwvjuzn5j6x4dmysj"
+	testValidator      = "mttvaloper12x07g3270742n42heupleuwvjuzn5j6x2ekcn0"
+	testOtherValidator = "mttvaloper1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqzcvlzk"
+)
+
+func newTestLdb(t *testing.T) *db.LDB {
+	t.Helper()
+	store := db.NewLdb(db.LdbConfig{Path: t.TempDir()})
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// indexBlockEvent parses event and indexes it in a block at blockTime.
+func indexBlockEvent(t *testing.T, store *db.LDB, parser BlockEventParser, event abci.Event, blockTime time.Time) {
+	t.Helper()
+	dataset, err := parser.ParseBlockEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Transaction(func(view db.View) error {
+		return parser.IndexBlockEvent(view, dataset, types.Block{Height: 100, TimeStamp: blockTime}, types.BlockEvent{}, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func unbondingEntryHeights(t *testing.T, store *db.LDB, prefix types.DbRecordPrefix) []int64 {
+	t.Helper()
+	records, err := store.GetAllRecordsWithPrefix(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heights := []int64{}
+	for _, record := range records {
+		switch entry := record.(type) {
+		case *types.UnbondingEntry:
+			heights = append(heights, entry.CreationHeight)
+		case *types.ValidatorUnbondingEntry:
+			heights = append(heights, entry.CreationHeight)
+		}
+	}
+	return heights
+}
+
+func TestCompleteUnbondingRemovesMaturedEntries(t *testing.T) {
+	store := newTestLdb(t)
+	completion := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	err := store.Transaction(func(view db.View) error {
+		for _, entry := range []*types.UnbondingEntry{
+			{Delegator: testDelegator, Validator: testValidator, CreationHeight: 10, CompletionTime: completion.Add(-time.Hour), InitialBalance: "100", Balance: "60"},
+			{Delegator: testDelegator, Validator: testValidator, CreationHeight: 20, CompletionTime: completion, InitialBalance: "90", Balance: "90"},
+			{Delegator: testDelegator, Validator: testValidator, CreationHeight: 30, CompletionTime: completion.Add(time.Hour), InitialBalance: "50", Balance: "50"},
+			{Delegator: testDelegator, Validator: testOtherValidator, CreationHeight: 10, CompletionTime: completion, InitialBalance: "70", Balance: "70"},
+		} {
+			if err := addUnbondingEntry(view, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &CompleteUnbondingParser{Id: "complete_unbonding"}
+	indexBlockEvent(t, store, parser, abci.Event{Type: stakingTypes.EventTypeCompleteUnbonding, Attributes: []abci.EventAttribute{
+		{Key: stdTypes.AttributeKeyAmount, Value: "150amtt"},
+		{Key: stakingTypes.AttributeKeyValidator, Value: testValidator},
+		{Key: stakingTypes.AttributeKeyDelegator, Value: testDelegator},
+	}}, completion)
+
+	// Entries of the pair that matured by the block time are gone, the others stay pending
+	if heights := unbondingEntryHeights(t, store, &types.UnbondingEntry{Delegator: testDelegator}); len(heights) != 2 {
+		t.Errorf("got pending entries at heights %v, want the later one and the other validator's", heights)
+	}
+	if heights := unbondingEntryHeights(t, store, &types.ValidatorUnbondingEntry{Validator: testValidator}); len(heights) != 1 || heights[0] != 30 {
+		t.Errorf("got validator entries at heights %v, want [30]", heights)
+	}
+	if heights := unbondingEntryHeights(t, store, &types.ValidatorUnbondingEntry{Validator: testOtherValidator}); len(heights) != 1 {
+		t.Errorf("got entries %v of the other validator, want it untouched", heights)
+	}
+
+	records, _, err := store.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: testValidator}, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d validator records, want the completion", len(records))
+	}
+	record := records[0].(*types.ValidatorRecord)
+	if record.DelegationType != types.CompleteUnbonding || record.Amount != "150" || record.Delegator != testDelegator || !record.DelegationTime.Equal(completion) {
+		t.Errorf("got validator record %+v", record)
+	}
+	delegatorRecords, _, err := store.GetAllRecordsWithAutoId(&types.DelegatorRecord{Delegator: testDelegator}, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delegatorRecords) != 1 {
+		t.Errorf("got %d delegator records, want the completion", len(delegatorRecords))
+	}
+}
+
+func TestCompleteRedelegationRecordsHistory(t *testing.T) {
+	store := newTestLdb(t)
+	completion := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	parser := &CompleteUnbondingParser{Id: "complete_unbonding"}
+	indexBlockEvent(t, store, parser, abci.Event{Type: stakingTypes.EventTypeCompleteRedelegation, Attributes: []abci.EventAttribute{
+		{Key: stdTypes.AttributeKeyAmount, Value: "40amtt"},
+		{Key: stakingTypes.AttributeKeyDelegator, Value: testDelegator},
+		{Key: stakingTypes.AttributeKeySrcValidator, Value: testOtherValidator},
+		{Key: stakingTypes.AttributeKeyDstValidator, Value: testValidator},
+	}}, completion)
+
+	records, _, err := store.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: testValidator}, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records of the destination validator, want 1", len(records))
+	}
+	if record := records[0].(*types.ValidatorRecord); record.DelegationType != types.CompleteRedelegation || record.Amount != "40" {
+		t.Errorf("got record %+v", record)
+	}
+
+	if _, err := parser.ParseBlockEvent(abci.Event{Type: "unbond", Attributes: []abci.EventAttribute{{Key: stdTypes.AttributeKeyAmount, Value: "1amtt"}}}); err == nil {
+		t.Error("parsed an event that is not a completion")
+	}
+}
@@ -2,13 +2,12 @@ package parsers
 
 import (
 	"errors"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
 	stdTypes "github.com/cosmos/cosmos-sdk/types"
 	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/syndtr/goleveldb/leveldb"
 	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
 	"mtt-indexer/db"
 	"mtt-indexer/types"
-	"time"
 )
 
 // This defines the custom message parsers for the delegation and undelegation message type
@@ -42,24 +41,41 @@ func (c *MsgCreateValidatorParser) ParseMessage(cosmosMsg stdTypes.Msg, log *ind
 		Base: msgCreateValidator.Value.Denom,
 	}
 
-	storageVal := any(types.ValidatorRecord{
-		Delegator:      delegator.Address,
-		Validator:      validator.ValidatorAddress.Address,
-		Amount:         amount,
-		Denom:          denom.Base,
-		DelegationType: types.Delegate,
+	consAddress := ""
+	if pubKey, ok := msgCreateValidator.Pubkey.GetCachedValue().(cryptotypes.PubKey); ok {
+		consAddress = stdTypes.ConsAddress(pubKey.Address()).String()
+	}
+
+	storageVal := any(CreateValidatorData{
+		ValidatorRecord: types.ValidatorRecord{
+			Delegator:      delegator.Address,
+			Validator:      validator.ValidatorAddress.Address,
+			Amount:         amount,
+			Denom:          denom.Base,
+			DelegationType: types.Delegate,
+		},
+		ConsAddress: consAddress,
+		Commission: types.CommissionRecord{
+			Validator:     validator.ValidatorAddress.Address,
+			Commission:    msgCreateValidator.Commission.Rate.MustFloat64(),
+			Type:          types.CommissionCreate,
+			Rate:          msgCreateValidator.Commission.Rate.String(),
+			MaxRate:       msgCreateValidator.Commission.MaxRate.String(),
+			MaxChangeRate: msgCreateValidator.Commission.MaxChangeRate.String(),
+		},
 	})
 	return &storageVal, nil
 }
 
-func (c *MsgCreateValidatorParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
-	validatorRecord, ok := (*dataset).(types.ValidatorRecord)
+func (c *MsgCreateValidatorParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	createValidatorData, ok := (*dataset).(CreateValidatorData)
 	if !ok {
-		return errors.New("not a ValidatorRecord type")
+		return errors.New("not a CreateValidatorData type")
 	}
+	validatorRecord := createValidatorData.ValidatorRecord
 	validatorRecord.TxHash = txhash
 	validatorRecord.DelegationTime = message.Tx.Block.TimeStamp
-	err := db.StoreRecord(ldb.DB, batch, &validatorRecord)
+	err := view.StoreRecord(&validatorRecord)
 	if err != nil {
 		return err
 	}
@@ -67,7 +83,7 @@ func (c *MsgCreateValidatorParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batc
 	outList := &types.DelegatorOutList{
 		Delegator: validatorRecord.Delegator,
 	}
-	record, err := ldb.GetRecordByType(outList)
+	record, err := view.GetRecordByType(outList)
 	if err != nil {
 		return err
 	}
@@ -85,12 +101,38 @@ func (c *MsgCreateValidatorParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batc
 	}
 	outList.AddValidatorRecord(validatorRecord, true)
 
-	return db.StoreRecord(ldb.DB, batch, outList)
+	if createValidatorData.ConsAddress != "" {
+		err = view.StoreRecord(&types.ValidatorConsAddress{
+			ConsAddress: createValidatorData.ConsAddress,
+			Operator:    validatorRecord.Validator,
+		})
+		if err != nil {
+			return err
+		}
+		err = view.StoreRecord(&types.ValidatorOperator{
+			Operator:    validatorRecord.Validator,
+			ConsAddress: createValidatorData.ConsAddress,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	commissionRecord := createValidatorData.Commission
+	commissionRecord.TxHash = txhash
+	commissionRecord.Height = message.Tx.Block.Height
+	commissionRecord.Time = message.Tx.Block.TimeStamp
+	if err := storeCommissionChange(view, &commissionRecord); err != nil {
+		return err
+	}
+
+	return view.StoreRecord(outList)
 }
 
-type CommissionChangeEvent struct {
-	ID         uint
-	Validator  Validator
-	Commission float64
-	Time       time.Time
+// CreateValidatorData carries the consensus address and initial commission of the new
+// validator next to its self delegation.
+type CreateValidatorData struct {
+	ValidatorRecord types.ValidatorRecord
+	ConsAddress     string
+	Commission      types.CommissionRecord
 }
@@ -4,10 +4,11 @@ import (
 	"errors"
 	stdTypes "github.com/cosmos/cosmos-sdk/types"
 	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/syndtr/goleveldb/leveldb"
 	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
 	"mtt-indexer/db"
+	"mtt-indexer/logger"
 	"mtt-indexer/types"
+	"time"
 )
 
 // This defines the custom message parsers for the delegation and undelegation message type
@@ -80,14 +81,15 @@ func (c *MsgDelegateUndelegateParser) ParseMessage(cosmosMsg stdTypes.Msg, log *
 	return &storageVal, nil
 }
 
-func (c *MsgDelegateUndelegateParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+func (c *MsgDelegateUndelegateParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
 	validatorRecord, ok := (*dataset).(types.ValidatorRecord)
 	if !ok {
 		return errors.New("not a ValidatorRecord type")
 	}
 	validatorRecord.TxHash = txhash
 	validatorRecord.DelegationTime = message.Tx.Block.TimeStamp
-	err := db.StoreRecord(ldb.DB, batch, &validatorRecord)
+	validatorRecord.Grantee = message.Grantee
+	err := view.StoreRecord(&validatorRecord)
 	if err != nil {
 		return err
 	}
@@ -95,7 +97,7 @@ func (c *MsgDelegateUndelegateParser) IndexMessage(ldb *db.LDB, batch *leveldb.B
 	outList := &types.DelegatorOutList{
 		Delegator: validatorRecord.Delegator,
 	}
-	record, err := ldb.GetRecordByType(outList)
+	record, err := view.GetRecordByType(outList)
 	if err != nil {
 		return err
 	}
@@ -117,12 +119,38 @@ func (c *MsgDelegateUndelegateParser) IndexMessage(ldb *db.LDB, batch *leveldb.B
 		outList.AddValidatorRecord(validatorRecord, false)
 	}
 
-	err = db.StoreRecord(ldb.DB, batch, outList)
+	err = view.StoreRecord(outList)
 	if err != nil {
 		return err
 	}
 
-	return db.StoreRecord(ldb.DB, batch, validatorRecord.ToDelegate())
+	if validatorRecord.DelegationType == types.Undelegate {
+		err = addPendingUnbonding(view, validatorRecord, message, messageEvents)
+		if err != nil {
+			return err
+		}
+	}
+
+	return view.StoreRecord(validatorRecord.ToDelegate())
+}
+
+func addPendingUnbonding(view db.View, validatorRecord types.ValidatorRecord, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	completionTime, err := time.Parse(time.RFC3339, GetMessageEventAttribute(messageEvents, stakingTypes.EventTypeUnbond, stakingTypes.AttributeKeyCompletionTime))
+	if err != nil {
+		logger.Logger.Warnf("Unbond event of tx %s has no valid completion time, not tracking it as pending: %v", validatorRecord.TxHash, err)
+		return nil
+	}
+
+	return addUnbondingEntry(view, &types.UnbondingEntry{
+		Delegator:      validatorRecord.Delegator,
+		Validator:      validatorRecord.Validator,
+		CreationHeight: message.Tx.Block.Height,
+		CompletionTime: completionTime,
+		InitialBalance: validatorRecord.Amount,
+		Balance:        validatorRecord.Amount,
+		Denom:          validatorRecord.Denom,
+		TxHash:         validatorRecord.TxHash,
+	})
 }
 
 type MsgUndelegateParser struct{}
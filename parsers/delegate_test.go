@@ -0,0 +1,49 @@
+package parsers
+
+import (
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+)
+
+func TestDelegationsInOneBlockKeepEveryUpdate(t *testing.T) {
+	store := newTestLdb(t)
+	parser := &MsgDelegateUndelegateParser{Id: "delegate"}
+	message := types.Message{Tx: types.Tx{Block: types.Block{Height: 5}}}
+
+	// Both messages are indexed in the transaction of one block
+	err := store.Transaction(func(view db.View) error {
+		for i, amount := range []int64{100, 50} {
+			dataset, err := parser.ParseMessage(&stakingTypes.MsgDelegate{DelegatorAddress: testDelegator, ValidatorAddress: testValidator, Amount: stdTypes.NewInt64Coin("amtt", amount)}, nil)
+			if err != nil {
+				return err
+			}
+			if err := parser.IndexMessage(view, []string{"tx1", "tx2"}[i], dataset, message, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, total, err := store.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: testValidator}, 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(records) != 2 || records[0].(*types.ValidatorRecord).TxHash != "tx1" || records[1].(*types.ValidatorRecord).TxHash != "tx2" {
+		t.Errorf("got %d of %d validator records, want both delegations under their own ids", len(records), total)
+	}
+
+	record, err := store.GetRecordByType(&types.DelegatorOutList{Delegator: testDelegator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outList, ok := record.(*types.DelegatorOutList)
+	if !ok || len(outList.Amounts) != 1 || outList.Amounts[0] != "150" {
+		t.Errorf("got out list %+v, want both delegations added up", record)
+	}
+}
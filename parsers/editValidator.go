@@ -4,13 +4,12 @@ import (
 	"errors"
 	stdTypes "github.com/cosmos/cosmos-sdk/types"
 	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/syndtr/goleveldb/leveldb"
 	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
 	"mtt-indexer/db"
 	"mtt-indexer/types"
 )
 
-// This defines the custom message parsers for the delegation and undelegation message type
+// This defines the custom message parsers for the edit validator message type
 // It implements the MessageParser interface
 type MsgEditValidatorParser struct {
 	Id string
@@ -23,30 +22,62 @@ func (c *MsgEditValidatorParser) Identifier() string {
 func (c *MsgEditValidatorParser) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerTxTypes.LogMessage) (*any, error) {
 	msgEditValidator, ok := cosmosMsg.(*stakingTypes.MsgEditValidator)
 	if !ok {
-		return nil, errors.New("not a delegation message")
+		return nil, errors.New("not an edit validator message")
 	}
 
-	validator := Validator{
-		ValidatorAddress: types.Address{
-			Address: msgEditValidator.ValidatorAddress,
-		},
+	// Edits of the description only leave the commission rate unset
+	if msgEditValidator.CommissionRate == nil {
+		return nil, nil
 	}
 
-	commission := msgEditValidator.CommissionRate.MustFloat64()
-
-	storageVal := any(CommissionChangeEvent{
-		Validator:  validator,
-		Commission: commission,
+	storageVal := any(types.CommissionRecord{
+		Validator:  msgEditValidator.ValidatorAddress,
+		Commission: msgEditValidator.CommissionRate.MustFloat64(),
+		Type:       types.CommissionEdit,
+		Rate:       msgEditValidator.CommissionRate.String(),
 	})
 
 	return &storageVal, nil
 }
 
-func (c *MsgEditValidatorParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+func (c *MsgEditValidatorParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
 	commissionRecord, ok := (*dataset).(types.CommissionRecord)
 	if !ok {
-		return errors.New("not a delegation event type")
+		return errors.New("not a CommissionRecord type")
 	}
+	commissionRecord.TxHash = txhash
+	commissionRecord.Height = message.Tx.Block.Height
 	commissionRecord.Time = message.Tx.Block.TimeStamp
-	return db.StoreRecord(ldb.DB, batch, &commissionRecord)
+	return storeCommissionChange(view, &commissionRecord)
+}
+
+// storeCommissionChange adds record to the commission history of its validator and makes
+// it the validator's current commission. The rate before the change, and for edits the
+// max rates that cannot change after creation, come from the current commission.
+func storeCommissionChange(view db.View, record *types.CommissionRecord) error {
+	current := &types.ValidatorCommission{Validator: record.Validator}
+	stored, err := view.GetRecordByType(current)
+	if err != nil {
+		return err
+	}
+	if validatorCommission, ok := stored.(*types.ValidatorCommission); ok {
+		current = validatorCommission
+		record.PreviousRate = current.Rate
+		if record.Type == types.CommissionEdit {
+			record.MaxRate = current.MaxRate
+			record.MaxChangeRate = current.MaxChangeRate
+		}
+	}
+
+	if err := view.StoreRecord(record); err != nil {
+		return err
+	}
+
+	current.Rate = record.Rate
+	if record.Type == types.CommissionCreate {
+		current.MaxRate = record.MaxRate
+		current.MaxChangeRate = record.MaxChangeRate
+	}
+	current.UpdateTime = record.Time
+	return view.StoreRecord(current)
 }
@@ -0,0 +1,30 @@
+package parsers
+
+import (
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"mtt-indexer/types"
+	"testing"
+)
+
+func TestEditValidatorParserSkipsDescriptionEdits(t *testing.T) {
+	parser := &MsgEditValidatorParser{Id: "edit_validator"}
+
+	dataset, err := parser.ParseMessage(&stakingTypes.MsgEditValidator{
+		Description:      stakingTypes.Description{Moniker: "renamed"},
+		ValidatorAddress: testValidator,
+	}, nil)
+	if dataset != nil || err != nil {
+		t.Errorf("got dataset %v and error %v for a description edit, want neither", dataset, err)
+	}
+
+	rate := stdTypes.NewDecWithPrec(5, 2)
+	dataset, err = parser.ParseMessage(&stakingTypes.MsgEditValidator{ValidatorAddress: testValidator, CommissionRate: &rate}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, ok := (*dataset).(types.CommissionRecord)
+	if !ok || record.Validator != testValidator || record.Rate != rate.String() || record.Type != types.CommissionEdit {
+		t.Errorf("got dataset %+v for a commission edit", *dataset)
+	}
+}
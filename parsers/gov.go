@@ -0,0 +1,376 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	abci "github.com/cometbft/cometbft/abci/types"
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	govTypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	govV1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
+	govV1beta1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1beta1"
+	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"strconv"
+)
+
+// This defines the custom message parser for the gov v1 and v1beta1 submit proposal message types
+// It implements the MessageParser interface
+type MsgSubmitProposalParser struct {
+	Id string
+}
+
+func (c *MsgSubmitProposalParser) Identifier() string {
+	return c.Id
+}
+
+// ParseMessage returns the proposal without its ID, which is only known from the events.
+func (c *MsgSubmitProposalParser) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerTxTypes.LogMessage) (*any, error) {
+	var proposal types.Proposal
+	var deposit stdTypes.Coins
+	switch msg := cosmosMsg.(type) {
+	case *govV1.MsgSubmitProposal:
+		proposal = types.Proposal{
+			Title:    msg.Title,
+			Summary:  msg.Summary,
+			Metadata: msg.Metadata,
+			Proposer: msg.Proposer,
+		}
+		for _, message := range msg.Messages {
+			proposal.Messages = append(proposal.Messages, message.TypeUrl)
+		}
+		deposit = msg.InitialDeposit
+	case *govV1beta1.MsgSubmitProposal:
+		proposal = types.Proposal{
+			Proposer: msg.Proposer,
+		}
+		if msg.Content != nil {
+			proposal.Messages = []string{msg.Content.TypeUrl}
+		}
+		if content := msg.GetContent(); content != nil {
+			proposal.Title = content.GetTitle()
+			proposal.Summary = content.GetDescription()
+		}
+		deposit = msg.InitialDeposit
+	default:
+		return nil, errors.New("not a submit proposal message")
+	}
+	proposal.TotalDeposit = toCoins(deposit)
+
+	storageVal := any(proposal)
+	return &storageVal, nil
+}
+
+func (c *MsgSubmitProposalParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	proposal, ok := (*dataset).(types.Proposal)
+	if !ok {
+		return errors.New("not a Proposal type")
+	}
+	proposalID, err := strconv.ParseUint(GetMessageEventAttribute(messageEvents, govTypes.EventTypeSubmitProposal, govTypes.AttributeKeyProposalID), 10, 64)
+	if err != nil {
+		return fmt.Errorf("submit proposal without a proposal id: %w", err)
+	}
+	proposal.ProposalID = proposalID
+	proposal.Status = types.ProposalDepositPeriod
+	proposal.SubmitHeight = message.Tx.Block.Height
+	proposal.SubmitTime = message.Tx.Block.TimeStamp
+	proposal.SubmitTxHash = txhash
+
+	err = view.StoreRecord(&types.ProposalEvent{
+		ProposalID: proposalID,
+		Type:       types.ProposalEventSubmit,
+		Address:    proposal.Proposer,
+		Coins:      proposal.TotalDeposit,
+		Height:     message.Tx.Block.Height,
+		TxHash:     txhash,
+		Time:       message.Tx.Block.TimeStamp,
+	})
+	if err != nil {
+		return err
+	}
+
+	if GetMessageEventAttribute(messageEvents, govTypes.EventTypeSubmitProposal, govTypes.AttributeKeyVotingPeriodStart) != "" {
+		if err := startVotingPeriod(view, &proposal, message.Tx.Block, txhash); err != nil {
+			return err
+		}
+	}
+	return view.StoreRecord(&proposal)
+}
+
+// This defines the custom message parser for the gov v1 and v1beta1 deposit message types
+// It implements the MessageParser interface
+type MsgDepositParser struct {
+	Id string
+}
+
+func (c *MsgDepositParser) Identifier() string {
+	return c.Id
+}
+
+func (c *MsgDepositParser) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerTxTypes.LogMessage) (*any, error) {
+	var event types.ProposalEvent
+	switch msg := cosmosMsg.(type) {
+	case *govV1.MsgDeposit:
+		event = types.ProposalEvent{ProposalID: msg.ProposalId, Address: msg.Depositor, Coins: toCoins(msg.Amount)}
+	case *govV1beta1.MsgDeposit:
+		event = types.ProposalEvent{ProposalID: msg.ProposalId, Address: msg.Depositor, Coins: toCoins(msg.Amount)}
+	default:
+		return nil, errors.New("not a deposit message")
+	}
+	event.Type = types.ProposalEventDeposit
+
+	storageVal := any(event)
+	return &storageVal, nil
+}
+
+func (c *MsgDepositParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	event, ok := (*dataset).(types.ProposalEvent)
+	if !ok {
+		return errors.New("not a ProposalEvent type")
+	}
+	event.Height = message.Tx.Block.Height
+	event.TxHash = txhash
+	event.Time = message.Tx.Block.TimeStamp
+	if err := view.StoreRecord(&event); err != nil {
+		return err
+	}
+
+	proposal, err := getProposal(view, event.ProposalID)
+	if err != nil {
+		return err
+	}
+	total, err := fromCoins(proposal.TotalDeposit)
+	if err != nil {
+		return err
+	}
+	deposit, err := fromCoins(event.Coins)
+	if err != nil {
+		return err
+	}
+	proposal.TotalDeposit = toCoins(total.Add(deposit...))
+
+	if GetMessageEventAttribute(messageEvents, govTypes.EventTypeProposalDeposit, govTypes.AttributeKeyVotingPeriodStart) != "" {
+		if err := startVotingPeriod(view, proposal, message.Tx.Block, txhash); err != nil {
+			return err
+		}
+	}
+	return view.StoreRecord(proposal)
+}
+
+// This defines the custom message parser for the gov v1 and v1beta1 vote and weighted vote message types
+// It implements the MessageParser interface
+type MsgVoteParser struct {
+	Id string
+}
+
+func (c *MsgVoteParser) Identifier() string {
+	return c.Id
+}
+
+func (c *MsgVoteParser) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerTxTypes.LogMessage) (*any, error) {
+	var vote types.ProposalVote
+	switch msg := cosmosMsg.(type) {
+	case *govV1.MsgVote:
+		vote = types.ProposalVote{ProposalID: msg.ProposalId, Voter: msg.Voter, Options: []types.VoteOption{{Option: msg.Option.String(), Weight: stdTypes.OneDec().String()}}}
+	case *govV1beta1.MsgVote:
+		vote = types.ProposalVote{ProposalID: msg.ProposalId, Voter: msg.Voter, Options: []types.VoteOption{{Option: msg.Option.String(), Weight: stdTypes.OneDec().String()}}}
+	case *govV1.MsgVoteWeighted:
+		vote = types.ProposalVote{ProposalID: msg.ProposalId, Voter: msg.Voter}
+		for _, option := range msg.Options {
+			vote.Options = append(vote.Options, types.VoteOption{Option: option.Option.String(), Weight: option.Weight})
+		}
+	case *govV1beta1.MsgVoteWeighted:
+		vote = types.ProposalVote{ProposalID: msg.ProposalId, Voter: msg.Voter}
+		for _, option := range msg.Options {
+			vote.Options = append(vote.Options, types.VoteOption{Option: option.Option.String(), Weight: option.Weight.String()})
+		}
+	default:
+		return nil, errors.New("not a vote message")
+	}
+
+	storageVal := any(vote)
+	return &storageVal, nil
+}
+
+// IndexMessage stores the vote in the vote list of the proposal and the voting history of
+// the voter, and votes of validators also in the validator vote list of the proposal. A
+// new vote of the same voter replaces the previous one in the vote lists of the proposal.
+func (c *MsgVoteParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	vote, ok := (*dataset).(types.ProposalVote)
+	if !ok {
+		return errors.New("not a ProposalVote type")
+	}
+	vote.Height = message.Tx.Block.Height
+	vote.TxHash = txhash
+	vote.Time = message.Tx.Block.TimeStamp
+
+	validator, err := voterValidator(view, vote.Voter)
+	if err != nil {
+		return err
+	}
+	vote.Validator = validator
+
+	current := &types.CurrentVote{ProposalID: vote.ProposalID, Voter: vote.Voter}
+	record, err := view.GetRecordByType(current)
+	if err != nil {
+		return err
+	}
+	if previous, ok := record.(*types.CurrentVote); ok {
+		if err := view.DeleteRecord(&types.ProposalVote{ProposalID: vote.ProposalID, ID: previous.VoteID}); err != nil {
+			return err
+		}
+		if previous.ValidatorVoteID != 0 {
+			if err := view.DeleteRecord(&types.ValidatorVote{ProposalID: vote.ProposalID, ID: previous.ValidatorVoteID}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := view.StoreRecord(&vote); err != nil {
+		return err
+	}
+	current.VoteID = vote.ID
+	if err := view.StoreRecord(vote.ToVoter()); err != nil {
+		return err
+	}
+	if vote.Validator != "" {
+		validatorVote := vote.ToValidator()
+		if err := view.StoreRecord(validatorVote); err != nil {
+			return err
+		}
+		current.ValidatorVoteID = validatorVote.ID
+	}
+	return view.StoreRecord(current)
+}
+
+// This defines the custom block event parser for the EndBlock active_proposal and inactive_proposal events
+// It implements the BlockEventParser interface
+type ProposalResultParser struct {
+	Id string
+}
+
+func (c *ProposalResultParser) Identifier() string {
+	return c.Id
+}
+
+func (c *ProposalResultParser) ParseBlockEvent(event abci.Event) (*any, error) {
+	proposalID, err := strconv.ParseUint(GetBlockEventAttribute(event, govTypes.AttributeKeyProposalID), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proposal id: %w", err)
+	}
+
+	var eventType types.ProposalEventType
+	switch result := GetBlockEventAttribute(event, govTypes.AttributeKeyProposalResult); result {
+	case govTypes.AttributeValueProposalPassed:
+		eventType = types.ProposalEventPassed
+	case govTypes.AttributeValueProposalRejected:
+		eventType = types.ProposalEventRejected
+	case govTypes.AttributeValueProposalFailed:
+		eventType = types.ProposalEventFailed
+	case govTypes.AttributeValueProposalDropped:
+		eventType = types.ProposalEventDropped
+	default:
+		return nil, fmt.Errorf("unknown proposal result %q", result)
+	}
+
+	storageVal := any(types.ProposalEvent{
+		ProposalID: proposalID,
+		Type:       eventType,
+	})
+	return &storageVal, nil
+}
+
+func (c *ProposalResultParser) IndexBlockEvent(view db.View, dataset *any, block types.Block, event types.BlockEvent, attributes []types.BlockEventAttribute) error {
+	proposalEvent, ok := (*dataset).(types.ProposalEvent)
+	if !ok {
+		return errors.New("not a ProposalEvent type")
+	}
+	proposalEvent.Height = block.Height
+	proposalEvent.Time = block.TimeStamp
+	if err := view.StoreRecord(&proposalEvent); err != nil {
+		return err
+	}
+
+	proposal, err := getProposal(view, proposalEvent.ProposalID)
+	if err != nil {
+		return err
+	}
+	switch proposalEvent.Type {
+	case types.ProposalEventPassed:
+		proposal.Status = types.ProposalPassed
+	case types.ProposalEventRejected:
+		proposal.Status = types.ProposalRejected
+	case types.ProposalEventFailed:
+		proposal.Status = types.ProposalFailed
+	case types.ProposalEventDropped:
+		proposal.Status = types.ProposalDropped
+	}
+	proposal.EndHeight = block.Height
+	proposal.EndTime = block.TimeStamp
+	return view.StoreRecord(proposal)
+}
+
+// getProposal returns the stored proposal, or a new one for proposals submitted before
+// the indexed range.
+func getProposal(view db.View, proposalID uint64) (*types.Proposal, error) {
+	record, err := view.GetRecordByType(&types.Proposal{ProposalID: proposalID})
+	if err != nil {
+		return nil, err
+	}
+	if proposal, ok := record.(*types.Proposal); ok {
+		return proposal, nil
+	}
+	return &types.Proposal{ProposalID: proposalID}, nil
+}
+
+func startVotingPeriod(view db.View, proposal *types.Proposal, block types.Block, txhash string) error {
+	proposal.Status = types.ProposalVotingPeriod
+	proposal.VotingStartHeight = block.Height
+	proposal.VotingStartTime = block.TimeStamp
+	return view.StoreRecord(&types.ProposalEvent{
+		ProposalID: proposal.ProposalID,
+		Type:       types.ProposalEventVotingStart,
+		Height:     block.Height,
+		TxHash:     txhash,
+		Time:       block.TimeStamp,
+	})
+}
+
+// voterValidator returns the operator address of the validator whose account voter is, or
+// "" when voter is not a validator.
+func voterValidator(view db.View, voter string) (string, error) {
+	account, err := stdTypes.AccAddressFromBech32(voter)
+	if err != nil {
+		return "", nil
+	}
+	operator := stdTypes.ValAddress(account).String()
+
+	record, err := view.GetRecordByType(&types.ValidatorOperator{Operator: operator})
+	if err != nil {
+		return "", err
+	}
+	if _, ok := record.(*types.ValidatorOperator); ok {
+		return operator, nil
+	}
+	return "", nil
+}
+
+func toCoins(coins stdTypes.Coins) []types.Coin {
+	var result []types.Coin
+	for _, coin := range coins {
+		result = append(result, types.Coin{Denom: coin.Denom, Amount: coin.Amount.String()})
+	}
+	return result
+}
+
+func fromCoins(coins []types.Coin) (stdTypes.Coins, error) {
+	result := stdTypes.Coins{}
+	for _, coin := range coins {
+		amount, ok := stdTypes.NewIntFromString(coin.Amount)
+		if !ok {
+			return nil, fmt.Errorf("invalid amount %q of %s", coin.Amount, coin.Denom)
+		}
+		result = result.Add(stdTypes.NewCoin(coin.Denom, amount))
+	}
+	return result, nil
+}
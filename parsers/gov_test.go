@@ -0,0 +1,220 @@
+package parsers
+
+import (
+	abci "github.com/cometbft/cometbft/abci/types"
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	govTypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	govV1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
+	govV1beta1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1beta1"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+	"time"
+)
+
+func messageEvent(eventType string, attributes ...string) MessageEventWithAttributes {
+	event := MessageEventWithAttributes{Event: types.MessageEvent{MessageEventType: types.MessageEventType{Type: eventType}}}
+	for i := 0; i < len(attributes); i += 2 {
+		event.Attributes = append(event.Attributes, types.MessageEventAttribute{Value: attributes[i+1], MessageEventAttributeKey: types.MessageEventAttributeKey{Key: attributes[i]}})
+	}
+	return event
+}
+
+// indexGovMessage parses msg and indexes it in its own block at height.
+func indexGovMessage(t *testing.T, store *db.LDB, parser MessageParser, msg stdTypes.Msg, height int64, events ...MessageEventWithAttributes) {
+	t.Helper()
+	dataset, err := parser.ParseMessage(msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := types.Message{Tx: types.Tx{Block: types.Block{Height: height, TimeStamp: time.Unix(height, 0).UTC()}}}
+	err = store.Transaction(func(view db.View) error {
+		return parser.IndexMessage(view, "tx", dataset, message, events)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func storedProposal(t *testing.T, store *db.LDB, proposalID uint64) *types.Proposal {
+	t.Helper()
+	record, err := store.GetRecordByType(&types.Proposal{ProposalID: proposalID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposal, ok := record.(*types.Proposal)
+	if !ok {
+		t.Fatalf("proposal %d was not stored", proposalID)
+	}
+	return proposal
+}
+
+func proposalEventTypes(t *testing.T, store *db.LDB, proposalID uint64) []types.ProposalEventType {
+	t.Helper()
+	records, _, err := store.GetAllRecordsWithAutoId(&types.ProposalEvent{ProposalID: proposalID}, 20, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventTypes := []types.ProposalEventType{}
+	for _, record := range records {
+		eventTypes = append(eventTypes, record.(*types.ProposalEvent).Type)
+	}
+	return eventTypes
+}
+
+func TestProposalTimeline(t *testing.T) {
+	store := newTestLdb(t)
+	proposer := stdTypes.AccAddress(make([]byte, 20)).String()
+
+	// A v1 proposal waits for deposits, a later deposit starts the voting period
+	submit, err := govV1.NewMsgSubmitProposal(nil, stdTypes.NewCoins(stdTypes.NewInt64Coin("amtt", 10)), proposer, "metadata", "Upgrade", "Upgrade the chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexGovMessage(t, store, &MsgSubmitProposalParser{Id: "submit_proposal"}, submit, 5,
+		messageEvent(govTypes.EventTypeSubmitProposal, govTypes.AttributeKeyProposalID, "10"))
+	if proposal := storedProposal(t, store, 10); proposal.Status != types.ProposalDepositPeriod || proposal.Title != "Upgrade" || proposal.Proposer != proposer || proposal.SubmitHeight != 5 {
+		t.Errorf("got submitted proposal %+v", proposal)
+	}
+
+	indexGovMessage(t, store, &MsgDepositParser{Id: "deposit"}, govV1beta1.NewMsgDeposit(stdTypes.AccAddress(make([]byte, 20)), 10, stdTypes.NewCoins(stdTypes.NewInt64Coin("amtt", 90))), 6,
+		messageEvent(govTypes.EventTypeProposalDeposit, govTypes.AttributeKeyProposalID, "10", govTypes.AttributeKeyVotingPeriodStart, "10"))
+	proposal := storedProposal(t, store, 10)
+	if proposal.Status != types.ProposalVotingPeriod || proposal.VotingStartHeight != 6 || len(proposal.TotalDeposit) != 1 || proposal.TotalDeposit[0].Amount != "100" {
+		t.Errorf("got proposal %+v after the deposit", proposal)
+	}
+
+	// The EndBlock result closes the proposal
+	parser := &ProposalResultParser{Id: "proposal_result"}
+	dataset, err := parser.ParseBlockEvent(abci.Event{Type: govTypes.EventTypeActiveProposal, Attributes: []abci.EventAttribute{
+		{Key: govTypes.AttributeKeyProposalID, Value: "10"},
+		{Key: govTypes.AttributeKeyProposalResult, Value: govTypes.AttributeValueProposalPassed},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Transaction(func(view db.View) error {
+		return parser.IndexBlockEvent(view, dataset, types.Block{Height: 9, TimeStamp: time.Unix(9, 0).UTC()}, types.BlockEvent{}, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposal = storedProposal(t, store, 10)
+	if proposal.Status != types.ProposalPassed || proposal.EndHeight != 9 || proposal.Title != "Upgrade" {
+		t.Errorf("got proposal %+v after the result", proposal)
+	}
+	want := []types.ProposalEventType{types.ProposalEventSubmit, types.ProposalEventDeposit, types.ProposalEventVotingStart, types.ProposalEventPassed}
+	if got := proposalEventTypes(t, store, 10); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] {
+		t.Errorf("got timeline %v, want %v", got, want)
+	}
+
+	// A v1beta1 proposal with enough deposit votes right away
+	content := govV1beta1.NewTextProposal("Signal", "Text proposal")
+	legacySubmit, err := govV1beta1.NewMsgSubmitProposal(content, stdTypes.NewCoins(stdTypes.NewInt64Coin("amtt", 100)), stdTypes.AccAddress(make([]byte, 20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexGovMessage(t, store, &MsgSubmitProposalParser{Id: "submit_proposal"}, legacySubmit, 7,
+		messageEvent(govTypes.EventTypeSubmitProposal, govTypes.AttributeKeyProposalID, "1", govTypes.AttributeKeyVotingPeriodStart, "1"))
+	if proposal := storedProposal(t, store, 1); proposal.Status != types.ProposalVotingPeriod || proposal.Title != "Signal" || len(proposal.Messages) != 1 {
+		t.Errorf("got legacy proposal %+v", proposal)
+	}
+
+	if _, err := parser.ParseBlockEvent(abci.Event{Type: govTypes.EventTypeActiveProposal, Attributes: []abci.EventAttribute{{Key: govTypes.AttributeKeyProposalID, Value: "1"}}}); err == nil {
+		t.Error("parsed a result event without a result")
+	}
+}
+
+func TestProposalVotes(t *testing.T) {
+	store := newTestLdb(t)
+	validatorAccount := stdTypes.AccAddress([]byte("validator-account-01"))
+	operator := stdTypes.ValAddress(validatorAccount).String()
+	voter := stdTypes.AccAddress([]byte("delegator-account-01")).String()
+	err := store.Transaction(func(view db.View) error {
+		return view.StoreRecord(&types.ValidatorOperator{Operator: operator, ConsAddress: "mttvalcons1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &MsgVoteParser{Id: "vote"}
+	indexGovMessage(t, store, parser, govV1.NewMsgVote(validatorAccount, 3, govV1.OptionYes, ""), 5)
+	weighted := govV1beta1.NewMsgVoteWeighted(stdTypes.MustAccAddressFromBech32(voter), 3, govV1beta1.WeightedVoteOptions{
+		{Option: govV1beta1.OptionYes, Weight: stdTypes.NewDecWithPrec(7, 1)},
+		{Option: govV1beta1.OptionAbstain, Weight: stdTypes.NewDecWithPrec(3, 1)},
+	})
+	indexGovMessage(t, store, parser, weighted, 6)
+
+	records, _, err := store.GetAllRecordsWithAutoId(&types.ProposalVote{ProposalID: 3}, 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d votes on the proposal, want 2", len(records))
+	}
+	validatorVote := records[0].(*types.ProposalVote)
+	if validatorVote.Validator != operator || len(validatorVote.Options) != 1 || validatorVote.Options[0].Option != govV1.OptionYes.String() {
+		t.Errorf("got validator vote %+v", validatorVote)
+	}
+	weightedVote := records[1].(*types.ProposalVote)
+	if weightedVote.Validator != "" || len(weightedVote.Options) != 2 || weightedVote.Options[1].Weight != stdTypes.NewDecWithPrec(3, 1).String() {
+		t.Errorf("got weighted vote %+v", weightedVote)
+	}
+
+	// Only the validator's vote is in the validator list, each voter has their own history
+	validatorVotes, _, err := store.GetAllRecordsWithAutoId(&types.ValidatorVote{ProposalID: 3}, 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(validatorVotes) != 1 {
+		t.Errorf("got %d validator votes, want 1", len(validatorVotes))
+	}
+	voterVotes, _, err := store.GetAllRecordsWithAutoId(&types.VoterVote{Voter: voter}, 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voterVotes) != 1 || voterVotes[0].(*types.VoterVote).ProposalID != 3 {
+		t.Errorf("got voting history %+v", voterVotes)
+	}
+}
+
+func TestProposalRevoteReplacesCurrentVote(t *testing.T) {
+	store := newTestLdb(t)
+	validatorAccount := stdTypes.AccAddress([]byte("validator-account-01"))
+	err := store.Transaction(func(view db.View) error {
+		return view.StoreRecord(&types.ValidatorOperator{Operator: stdTypes.ValAddress(validatorAccount).String(), ConsAddress: "mttvalcons1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &MsgVoteParser{Id: "vote"}
+	indexGovMessage(t, store, parser, govV1.NewMsgVote(validatorAccount, 3, govV1.OptionYes, ""), 5)
+	indexGovMessage(t, store, parser, govV1.NewMsgVote(validatorAccount, 3, govV1.OptionNo, ""), 6)
+
+	for _, record := range []types.DbRecordAutoId{&types.ProposalVote{ProposalID: 3}, &types.ValidatorVote{ProposalID: 3}} {
+		records, total, err := store.GetAllRecordsWithAutoId(record, 10, 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(records) != 1 {
+			t.Fatalf("got %d of %d votes in %T list, want only the current vote", len(records), total, record)
+		}
+	}
+	records, _, err := store.GetAllRecordsWithAutoId(&types.ProposalVote{ProposalID: 3}, 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vote := records[0].(*types.ProposalVote); vote.Height != 6 || vote.Options[0].Option != govV1.OptionNo.String() {
+		t.Errorf("got current vote %+v, want the revote", vote)
+	}
+
+	// The voting history keeps both votes
+	voterVotes, _, err := store.GetAllRecordsWithAutoId(&types.VoterVote{Voter: validatorAccount.String()}, 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voterVotes) != 2 {
+		t.Errorf("got voting history %+v, want both votes", voterVotes)
+	}
+}
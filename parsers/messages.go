@@ -2,7 +2,6 @@ package parsers
 
 import (
 	sdkTypes "github.com/cosmos/cosmos-sdk/types"
-	"github.com/syndtr/goleveldb/leveldb"
 	txtypes "mtt-indexer/cosmos/modules/tx"
 	"mtt-indexer/db"
 	"mtt-indexer/types"
@@ -17,8 +16,9 @@ type MessageEventWithAttributes struct {
 
 type MessageParser interface {
 	Identifier() string
+	// ParseMessage returns no dataset and no error for messages with nothing to index
 	ParseMessage(sdkTypes.Msg, *txtypes.LogMessage) (*any, error)
-	IndexMessage(*db.LDB, *leveldb.Batch, string, *any, types.Message, []MessageEventWithAttributes) error
+	IndexMessage(db.View, string, *any, types.Message, []MessageEventWithAttributes) error
 }
 
 type MessageParsedData struct {
@@ -26,3 +26,18 @@ type MessageParsedData struct {
 	Error  error
 	Parser *MessageParser
 }
+
+// GetMessageEventAttribute returns the value of key in the first event of eventType, or "" if there is none.
+func GetMessageEventAttribute(messageEvents []MessageEventWithAttributes, eventType string, key string) string {
+	for _, event := range messageEvents {
+		if event.Event.MessageEventType.Type != eventType {
+			continue
+		}
+		for _, attr := range event.Attributes {
+			if attr.MessageEventAttributeKey.Key == key {
+				return attr.Value
+			}
+		}
+	}
+	return ""
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	stdTypes "github.com/cosmos/cosmos-sdk/types"
 	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
-	"github.com/syndtr/goleveldb/leveldb"
 	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
 	"mtt-indexer/db"
 	"mtt-indexer/types"
@@ -40,7 +39,7 @@ func (c *MsgRedelegateParser) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerT
 
 }
 
-func (c *MsgRedelegateParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+func (c *MsgRedelegateParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
 	//src
 	record, ok := (*dataset).(types.RedelegateRecord)
 	if !ok {
@@ -55,9 +54,10 @@ func (c *MsgRedelegateParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, tx
 		TxHash:         txhash,
 		DelegationType: types.Redelegate,
 		DelegationTime: message.Tx.Block.TimeStamp,
+		Grantee:        message.Grantee,
 	}
 
-	err := db.StoreRecord(ldb.DB, batch, validatorSrcRecord)
+	err := view.StoreRecord(validatorSrcRecord)
 	if err != nil {
 		return err
 	}
@@ -65,7 +65,7 @@ func (c *MsgRedelegateParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, tx
 	outList := &types.DelegatorOutList{
 		Delegator: validatorSrcRecord.Delegator,
 	}
-	recordOutList, err := ldb.GetRecordByType(outList)
+	recordOutList, err := view.GetRecordByType(outList)
 	if err != nil {
 		return err
 	}
@@ -82,28 +82,28 @@ func (c *MsgRedelegateParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, tx
 		}
 	}
 	outList.AddValidatorRecord(*validatorSrcRecord, false)
-	err = db.StoreRecord(ldb.DB, batch, outList)
+	err = view.StoreRecord(outList)
 	if err != nil {
 		return err
 	}
 
-	err = db.StoreRecord(ldb.DB, batch, validatorSrcRecord.ToDelegate())
+	err = view.StoreRecord(validatorSrcRecord.ToDelegate())
 	if err != nil {
 		return err
 	}
 
 	//dst
 	validatorSrcRecord.Validator = record.Dst
-	err = db.StoreRecord(ldb.DB, batch, validatorSrcRecord)
+	err = view.StoreRecord(validatorSrcRecord)
 	if err != nil {
 		return err
 	}
 	outList.AddValidatorRecord(*validatorSrcRecord, true)
-	err = db.StoreRecord(ldb.DB, batch, outList)
+	err = view.StoreRecord(outList)
 	if err != nil {
 		return err
 	}
-	err = db.StoreRecord(ldb.DB, batch, validatorSrcRecord.ToDelegate())
+	err = view.StoreRecord(validatorSrcRecord.ToDelegate())
 	if err != nil {
 		return err
 	}
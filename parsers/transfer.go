@@ -0,0 +1,152 @@
+package parsers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	distributionTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+)
+
+// TransferParser stores the transfers of a bank send in the transfer history of both
+// accounts. The transfers are read from the MsgSend or MsgMultiSend itself, which moves
+// exactly the listed amounts, so a tx without a parsable log is still recorded.
+// It implements the MessageParser interface
+type TransferParser struct {
+	Id string
+}
+
+func (c *TransferParser) Identifier() string {
+	return c.Id
+}
+
+func (c *TransferParser) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerTxTypes.LogMessage) (*any, error) {
+	var transfers []indexerTxTypes.TransferEvent
+	switch msg := cosmosMsg.(type) {
+	case *bankTypes.MsgSend:
+		transfers = append(transfers, indexerTxTypes.TransferEvent{
+			Recipient: msg.ToAddress,
+			Sender:    msg.FromAddress,
+			Amount:    msg.Amount.String(),
+		})
+	case *bankTypes.MsgMultiSend:
+		if len(msg.Inputs) != 1 {
+			return nil, errors.New("multi send must have exactly one input")
+		}
+		for _, output := range msg.Outputs {
+			transfers = append(transfers, indexerTxTypes.TransferEvent{
+				Recipient: output.Address,
+				Sender:    msg.Inputs[0].Address,
+				Amount:    output.Coins.String(),
+			})
+		}
+	default:
+		return nil, errors.New("not a bank send message")
+	}
+
+	storageVal := any(transfers)
+	return &storageVal, nil
+}
+
+func (c *TransferParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	transfers, ok := (*dataset).([]indexerTxTypes.TransferEvent)
+	if !ok {
+		return errors.New("not a TransferEvent list")
+	}
+	return storeTransfers(view, txhash, transfers, message)
+}
+
+// RewardPayoutParser stores the rewards the distribution module pays out during a message
+// in the transfer history of both accounts. Withdraw messages pay out rewards or commission,
+// and staking messages that change a delegation withdraw its pending rewards first. Only
+// transfer events sent by the distribution module are taken, the stake itself moves
+// without a transfer event.
+// It implements the MessageParser interface
+type RewardPayoutParser struct {
+	Id string
+}
+
+func (c *RewardPayoutParser) Identifier() string {
+	return c.Id
+}
+
+// ParseMessage returns nil when the message paid out nothing.
+func (c *RewardPayoutParser) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerTxTypes.LogMessage) (*any, error) {
+	var transfers []indexerTxTypes.TransferEvent
+	for _, event := range indexerTxTypes.GetAllEventsWithType(bankTypes.EventTypeTransfer, log) {
+		eventTransfers, err := indexerTxTypes.ParseTransferEvent(event)
+		if err != nil {
+			return nil, err
+		}
+		for _, transfer := range eventTransfers {
+			if isDistributionModule(transfer.Sender) {
+				transfers = append(transfers, transfer)
+			}
+		}
+	}
+
+	if len(transfers) == 0 {
+		return nil, nil
+	}
+	storageVal := any(transfers)
+	return &storageVal, nil
+}
+
+func (c *RewardPayoutParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	transfers, ok := (*dataset).([]indexerTxTypes.TransferEvent)
+	if !ok {
+		return errors.New("not a TransferEvent list")
+	}
+	return storeTransfers(view, txhash, transfers, message)
+}
+
+// isDistributionModule compares the address bytes, so it does not depend on the bech32 prefix.
+func isDistributionModule(address string) bool {
+	_, addressBytes, err := bech32.DecodeAndConvert(address)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(addressBytes, authTypes.NewModuleAddress(distributionTypes.ModuleName))
+}
+
+func storeTransfers(view db.View, txhash string, transfers []indexerTxTypes.TransferEvent, message types.Message) error {
+	for _, transfer := range transfers {
+		coins, err := stdTypes.ParseCoinsNormalized(transfer.Amount)
+		if err != nil {
+			return fmt.Errorf("invalid transfer amount %q: %w", transfer.Amount, err)
+		}
+		for _, coin := range coins {
+			record := types.TransferRecord{
+				Amount:      coin.Amount.String(),
+				Denom:       coin.Denom,
+				MessageType: message.MessageType.MessageType,
+				Height:      message.Tx.Block.Height,
+				TxHash:      txhash,
+				Time:        message.Tx.Block.TimeStamp,
+			}
+
+			out := record
+			out.Address = transfer.Sender
+			out.Counterparty = transfer.Recipient
+			out.Direction = types.TransferOut
+			if err := view.StoreRecord(&out); err != nil {
+				return err
+			}
+
+			in := record
+			in.Address = transfer.Recipient
+			in.Counterparty = transfer.Sender
+			in.Direction = types.TransferIn
+			if err := view.StoreRecord(&in); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
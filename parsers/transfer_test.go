@@ -0,0 +1,69 @@
+package parsers
+
+import (
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	authTypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	bankTypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	distributionTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
+	"testing"
+)
+
+var (
+	testDistributionModule = stdTypes.AccAddress(authTypes.NewModuleAddress(distributionTypes.ModuleName)).String()
+	testOtherAccount       = stdTypes.AccAddress([]byte("other-account-000001")).String()
+)
+
+func transferEvent(recipient, sender, amount string) indexerTxTypes.LogMessageEvent {
+	return indexerTxTypes.LogMessageEvent{Type: bankTypes.EventTypeTransfer, Attributes: []indexerTxTypes.Attribute{
+		{Key: "recipient", Value: recipient}, {Key: "sender", Value: sender}, {Key: "amount", Value: amount},
+	}}
+}
+
+func TestRewardPayoutParserTakesOnlyDistributionPayouts(t *testing.T) {
+	parser := &RewardPayoutParser{Id: "rewardPayout"}
+	delegate := &stakingTypes.MsgDelegate{DelegatorAddress: testDelegator, ValidatorAddress: testValidator, Amount: stdTypes.NewInt64Coin("amtt", 100)}
+
+	// Delegating moves the stake without a transfer event
+	delegateLog := &indexerTxTypes.LogMessage{Events: []indexerTxTypes.LogMessageEvent{
+		{Type: "coin_spent", Attributes: []indexerTxTypes.Attribute{{Key: "spender", Value: testDelegator}, {Key: "amount", Value: "100amtt"}}},
+		{Type: "delegate", Attributes: []indexerTxTypes.Attribute{{Key: "validator", Value: testValidator}, {Key: "amount", Value: "100amtt"}}},
+	}}
+	dataset, err := parser.ParseMessage(delegate, delegateLog)
+	if dataset != nil || err != nil {
+		t.Errorf("got dataset %v and error %v for a delegation without rewards", dataset, err)
+	}
+
+	// The pending rewards withdrawn by the delegation are paid by the distribution module,
+	// other transfers of the message are not payouts
+	delegateLog.Events = append(delegateLog.Events,
+		transferEvent(testDelegator, testDistributionModule, "7amtt"),
+		transferEvent(testDelegator, testOtherAccount, "2amtt"),
+	)
+	dataset, err = parser.ParseMessage(delegate, delegateLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfers := (*dataset).([]indexerTxTypes.TransferEvent)
+	if len(transfers) != 1 || transfers[0].Sender != testDistributionModule || transfers[0].Recipient != testDelegator || transfers[0].Amount != "7amtt" {
+		t.Errorf("got transfers %+v, want the reward payout", transfers)
+	}
+}
+
+func TestTransferParserReadsSends(t *testing.T) {
+	parser := &TransferParser{Id: "transfer"}
+
+	// A send is read from the message, with or without a log
+	dataset, err := parser.ParseMessage(&bankTypes.MsgSend{FromAddress: testDelegator, ToAddress: testOtherAccount, Amount: stdTypes.NewCoins(stdTypes.NewInt64Coin("amtt", 3))}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfers := (*dataset).([]indexerTxTypes.TransferEvent); len(transfers) != 1 || transfers[0].Sender != testDelegator || transfers[0].Amount != "3amtt" {
+		t.Errorf("got transfers %+v for a send", transfers)
+	}
+
+	if _, err := parser.ParseMessage(&stakingTypes.MsgDelegate{DelegatorAddress: testDelegator}, nil); err == nil {
+		t.Error("parsed a delegation as a send")
+	}
+}
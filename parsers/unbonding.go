@@ -0,0 +1,134 @@
+package parsers
+
+import (
+	sdkmath "cosmossdk.io/math"
+	"fmt"
+	"mtt-indexer/db"
+	"mtt-indexer/logger"
+	"mtt-indexer/types"
+	"time"
+)
+
+func getUnbondingEntry(view db.View, delegator, validator string, creationHeight int64) (*types.UnbondingEntry, error) {
+	record, err := view.GetRecordByType(&types.UnbondingEntry{
+		Delegator:      delegator,
+		Validator:      validator,
+		CreationHeight: creationHeight,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := record.(*types.UnbondingEntry); ok {
+		return entry, nil
+	}
+	return nil, nil
+}
+
+func storeUnbondingEntry(view db.View, entry *types.UnbondingEntry) error {
+	err := view.StoreRecord(entry)
+	if err != nil {
+		return err
+	}
+	return view.StoreRecord(entry.ToValidator())
+}
+
+func deleteUnbondingEntry(view db.View, entry *types.UnbondingEntry) error {
+	err := view.DeleteRecord(entry)
+	if err != nil {
+		return err
+	}
+	return view.DeleteRecord(entry.ToValidator())
+}
+
+func unbondingAmount(amount string) (sdkmath.Int, error) {
+	value, ok := sdkmath.NewIntFromString(amount)
+	if !ok {
+		return sdkmath.Int{}, fmt.Errorf("invalid unbonding amount %q", amount)
+	}
+	return value, nil
+}
+
+// addUnbondingEntry records a new undelegation. Undelegations of the same pair in one block
+// share an entry, matching how the staking module merges entries with equal creation height.
+func addUnbondingEntry(view db.View, entry *types.UnbondingEntry) error {
+	stored, err := getUnbondingEntry(view, entry.Delegator, entry.Validator, entry.CreationHeight)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		initial, err := unbondingAmount(stored.InitialBalance)
+		if err != nil {
+			return err
+		}
+		balance, err := unbondingAmount(stored.Balance)
+		if err != nil {
+			return err
+		}
+		amount, err := unbondingAmount(entry.InitialBalance)
+		if err != nil {
+			return err
+		}
+		stored.InitialBalance = initial.Add(amount).String()
+		stored.Balance = balance.Add(amount).String()
+		entry = stored
+	}
+	return storeUnbondingEntry(view, entry)
+}
+
+// cancelUnbondingEntry reduces the remaining balance of the entry created at creationHeight,
+// dropping the entry once nothing is left, as the staking module does.
+func cancelUnbondingEntry(view db.View, delegator, validator string, creationHeight int64, amount string) error {
+	entry, err := getUnbondingEntry(view, delegator, validator, creationHeight)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		logger.Logger.Warnf("No unbonding entry of %s from %s at height %d to cancel", delegator, validator, creationHeight)
+		return nil
+	}
+
+	balance, err := unbondingAmount(entry.Balance)
+	if err != nil {
+		return err
+	}
+	cancelAmount, err := unbondingAmount(amount)
+	if err != nil {
+		return err
+	}
+	balance = balance.Sub(cancelAmount)
+	if !balance.IsPositive() {
+		return deleteUnbondingEntry(view, entry)
+	}
+	entry.Balance = balance.String()
+	return storeUnbondingEntry(view, entry)
+}
+
+// completeUnbondingEntries removes every entry of the pair that matured by completionTime.
+// The chain emits one complete_unbonding event per pair with the summed balance of those entries.
+func completeUnbondingEntries(view db.View, delegator, validator string, completionTime time.Time, amount string) error {
+	records, err := view.GetAllRecordsWithPrefix(&types.UnbondingEntry{Delegator: delegator})
+	if err != nil {
+		return err
+	}
+
+	completed := sdkmath.ZeroInt()
+	for _, record := range records {
+		entry, ok := record.(*types.UnbondingEntry)
+		if !ok || entry.Validator != validator || entry.CompletionTime.After(completionTime) {
+			continue
+		}
+		balance, err := unbondingAmount(entry.Balance)
+		if err != nil {
+			return err
+		}
+		completed = completed.Add(balance)
+		if err := deleteUnbondingEntry(view, entry); err != nil {
+			return err
+		}
+	}
+
+	if expected, ok := sdkmath.NewIntFromString(amount); ok && !expected.Equal(completed) {
+		logger.Logger.Warnf("Completed unbonding of %s from %s is %s but indexed entries add up to %s", delegator, validator, amount, completed)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package parsers
+
+import (
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+	"time"
+)
+
+func TestUnbondingEntryBalances(t *testing.T) {
+	store := newTestLdb(t)
+	completion := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	update := func(f func(view db.View) error) {
+		t.Helper()
+		if err := store.Transaction(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entry := func(validator string) *types.UnbondingEntry {
+		t.Helper()
+		record, err := store.GetRecordByType(&types.UnbondingEntry{Delegator: testDelegator, Validator: validator, CreationHeight: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := record.(*types.UnbondingEntry)
+		return stored
+	}
+
+	// Two undelegations of the pair in one block share an entry
+	update(func(view db.View) error {
+		for _, amount := range []string{"100", "30"} {
+			err := addUnbondingEntry(view, &types.UnbondingEntry{Delegator: testDelegator, Validator: testValidator, CreationHeight: 10, CompletionTime: completion, InitialBalance: amount, Balance: amount, Denom: "amtt"})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if stored := entry(testValidator); stored == nil || stored.InitialBalance != "130" || stored.Balance != "130" {
+		t.Fatalf("got entry %+v, want both undelegations merged", stored)
+	}
+
+	// Cancelling reduces the remaining balance and keeps the initial one
+	update(func(view db.View) error {
+		return cancelUnbondingEntry(view, testDelegator, testValidator, 10, "50")
+	})
+	if stored := entry(testValidator); stored == nil || stored.InitialBalance != "130" || stored.Balance != "80" {
+		t.Errorf("got entry %+v after a partial cancel", stored)
+	}
+	record, err := store.GetRecordByType(&types.ValidatorUnbondingEntry{Delegator: testDelegator, Validator: testValidator, CreationHeight: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, ok := record.(*types.ValidatorUnbondingEntry); !ok || stored.Balance != "80" {
+		t.Errorf("got validator entry %+v, want it in step with the delegator entry", record)
+	}
+
+	// Cancelling the rest drops the entry from both views
+	update(func(view db.View) error {
+		return cancelUnbondingEntry(view, testDelegator, testValidator, 10, "80")
+	})
+	if stored := entry(testValidator); stored != nil {
+		t.Errorf("got entry %+v after cancelling everything", stored)
+	}
+	if heights := unbondingEntryHeights(t, store, &types.ValidatorUnbondingEntry{Validator: testValidator}); len(heights) != 0 {
+		t.Errorf("got validator entries at %v after cancelling everything", heights)
+	}
+
+	// An unknown entry is left alone, a broken amount fails the block
+	update(func(view db.View) error {
+		return cancelUnbondingEntry(view, testDelegator, testOtherValidator, 10, "5")
+	})
+	update(func(view db.View) error {
+		return addUnbondingEntry(view, &types.UnbondingEntry{Delegator: testDelegator, Validator: testValidator, CreationHeight: 10, InitialBalance: "5", Balance: "5"})
+	})
+	err = store.Transaction(func(view db.View) error {
+		return cancelUnbondingEntry(view, testDelegator, testValidator, 10, "five")
+	})
+	if err == nil {
+		t.Error("cancelled an invalid amount")
+	}
+	err = store.Transaction(func(view db.View) error {
+		return addUnbondingEntry(view, &types.UnbondingEntry{Delegator: testDelegator, Validator: testValidator, CreationHeight: 10, InitialBalance: "five", Balance: "five"})
+	})
+	if err == nil {
+		t.Error("merged an invalid amount into an entry")
+	}
+	if stored := entry(testValidator); stored == nil || stored.Balance != "5" {
+		t.Errorf("got entry %+v, want it untouched by the failed undelegation", stored)
+	}
+}
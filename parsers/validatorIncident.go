@@ -0,0 +1,172 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	abci "github.com/cometbft/cometbft/abci/types"
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	evidenceTypes "github.com/cosmos/cosmos-sdk/x/evidence/types"
+	slashingTypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"strconv"
+	"time"
+)
+
+// attributeKeyJailUntil is the slash event attribute holding when a validator jailed for
+// downtime can unjail, as an RFC 3339 time.
+const attributeKeyJailUntil = "jail_until"
+
+// This defines the custom block event parser for the BeginBlock slash and liveness events
+// It implements the BlockEventParser interface
+type ValidatorIncidentParser struct {
+	Id string
+	// DowntimeJailDuration is the slashing module parameter used to compute when a validator jailed for downtime can unjail
+	// on chains that do not emit the jail_until attribute, the end stays unknown when it is 0
+	DowntimeJailDuration time.Duration
+}
+
+func (c *ValidatorIncidentParser) Identifier() string {
+	return c.Id
+}
+
+func (c *ValidatorIncidentParser) ParseBlockEvent(event abci.Event) (*any, error) {
+	switch event.Type {
+	case slashingTypes.EventTypeSlash:
+		reason := GetBlockEventAttribute(event, slashingTypes.AttributeKeyReason)
+		jailed := GetBlockEventAttribute(event, slashingTypes.AttributeKeyJailed)
+		consAddress := GetBlockEventAttribute(event, slashingTypes.AttributeKeyAddress)
+
+		if reason == "" && jailed != "" {
+			// Jail() emits a slash event holding only the jailed address. Outside of the
+			// downtime path, which reports jailing on its slash event, only evidence of
+			// double signing jails a validator, and that jail never ends.
+			storageVal := any(types.ValidatorIncident{
+				ConsAddress: jailed,
+				Type:        types.Jail,
+				Reason:      slashingTypes.AttributeValueDoubleSign,
+				Jailed:      true,
+				JailedUntil: evidenceTypes.DoubleSignJailEndTime,
+			})
+			return &storageVal, nil
+		}
+
+		if consAddress == "" {
+			return nil, errors.New("slash event has no address")
+		}
+		incident := types.ValidatorIncident{
+			ConsAddress:  consAddress,
+			Type:         types.Slash,
+			Reason:       reason,
+			SlashedPower: GetBlockEventAttribute(event, slashingTypes.AttributeKeyPower),
+			BurnedCoins:  GetBlockEventAttribute(event, slashingTypes.AttributeKeyBurnedCoins),
+			Jailed:       jailed != "",
+		}
+		if jailUntil := GetBlockEventAttribute(event, attributeKeyJailUntil); jailUntil != "" {
+			jailedUntil, err := time.Parse(time.RFC3339Nano, jailUntil)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jail_until %q: %w", jailUntil, err)
+			}
+			incident.JailedUntil = jailedUntil.UTC()
+		}
+		storageVal := any(incident)
+		return &storageVal, nil
+	case slashingTypes.EventTypeLiveness:
+		missedBlocks, err := strconv.ParseInt(GetBlockEventAttribute(event, slashingTypes.AttributeKeyMissedBlocks), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		height, err := strconv.ParseInt(GetBlockEventAttribute(event, slashingTypes.AttributeKeyHeight), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		storageVal := any(types.ValidatorLiveness{
+			ConsAddress:      GetBlockEventAttribute(event, slashingTypes.AttributeKeyAddress),
+			MissedBlocks:     missedBlocks,
+			LastMissedHeight: height,
+		})
+		return &storageVal, nil
+	}
+
+	return nil, errors.New("not a slash or liveness event")
+}
+
+func (c *ValidatorIncidentParser) IndexBlockEvent(view db.View, dataset *any, block types.Block, event types.BlockEvent, attributes []types.BlockEventAttribute) error {
+	switch record := (*dataset).(type) {
+	case types.ValidatorIncident:
+		if record.Jailed && record.JailedUntil.IsZero() && c.DowntimeJailDuration > 0 {
+			// Jailed for downtime without a jail_until attribute, the jail period starts at this block
+			record.JailedUntil = block.TimeStamp.Add(c.DowntimeJailDuration)
+		}
+		return storeValidatorIncident(view, &record, block)
+	case types.ValidatorLiveness:
+		operator, err := resolveOperatorAddress(view, record.ConsAddress)
+		if err != nil {
+			return err
+		}
+		record.Validator = operator
+		record.LastMissedTime = block.TimeStamp
+		return view.StoreRecord(&record)
+	}
+
+	return errors.New("not a ValidatorIncident or ValidatorLiveness type")
+}
+
+func storeValidatorIncident(view db.View, incident *types.ValidatorIncident, block types.Block) error {
+	operator, err := resolveOperatorAddress(view, incident.ConsAddress)
+	if err != nil {
+		return err
+	}
+	incident.Validator = operator
+	incident.Height = block.Height
+	incident.Time = block.TimeStamp
+	return view.StoreRecord(incident)
+}
+
+// resolveOperatorAddress maps a consensus address to the operator address, falling back to
+// the consensus address itself for validators whose keys have not been indexed yet.
+func resolveOperatorAddress(view db.View, consAddress string) (string, error) {
+	record, err := view.GetRecordByType(&types.ValidatorConsAddress{ConsAddress: consAddress})
+	if err != nil {
+		return "", err
+	}
+	if mapping, ok := record.(*types.ValidatorConsAddress); ok && mapping.Operator != "" {
+		return mapping.Operator, nil
+	}
+	return consAddress, nil
+}
+
+// This defines the custom message parser for the unjail message type
+// It implements the MessageParser interface
+type MsgUnjailParser struct {
+	Id string
+}
+
+func (c *MsgUnjailParser) Identifier() string {
+	return c.Id
+}
+
+func (c *MsgUnjailParser) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerTxTypes.LogMessage) (*any, error) {
+	msg, ok := cosmosMsg.(*slashingTypes.MsgUnjail)
+	if !ok {
+		return nil, errors.New("not an unjail message")
+	}
+
+	storageVal := any(types.ValidatorIncident{
+		Validator: msg.ValidatorAddr,
+		Type:      types.Unjail,
+	})
+	return &storageVal, nil
+}
+
+func (c *MsgUnjailParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	incident, ok := (*dataset).(types.ValidatorIncident)
+	if !ok {
+		return errors.New("not a ValidatorIncident type")
+	}
+	incident.TxHash = txhash
+	incident.Height = message.Tx.Block.Height
+	incident.Time = message.Tx.Block.TimeStamp
+	return view.StoreRecord(&incident)
+}
@@ -0,0 +1,146 @@
+package parsers
+
+import (
+	abci "github.com/cometbft/cometbft/abci/types"
+	evidenceTypes "github.com/cosmos/cosmos-sdk/x/evidence/types"
+	slashingTypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+	"time"
+)
+
+const testConsAddress = "mttvalcons12x07g3270742n42heupleuwvjuzn5j6x9xc3v7"
+
+func slashEvent(attributes ...string) abci.Event {
+	event := abci.Event{Type: slashingTypes.EventTypeSlash}
+	for i := 0; i < len(attributes); i += 2 {
+		event.Attributes = append(event.Attributes, abci.EventAttribute{Key: attributes[i], Value: attributes[i+1]})
+	}
+	return event
+}
+
+func validatorIncidents(t *testing.T, store *db.LDB) []*types.ValidatorIncident {
+	t.Helper()
+	records, _, err := store.GetAllRecordsWithAutoId(&types.ValidatorIncident{Validator: testValidator}, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Oldest first
+	incidents := []*types.ValidatorIncident{}
+	for i := len(records) - 1; i >= 0; i-- {
+		incidents = append(incidents, records[i].(*types.ValidatorIncident))
+	}
+	return incidents
+}
+
+func TestValidatorIncidentParserSlashAndJail(t *testing.T) {
+	store := newTestLdb(t)
+	err := store.Transaction(func(view db.View) error {
+		return view.StoreRecord(&types.ValidatorConsAddress{ConsAddress: testConsAddress, Operator: testValidator})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := &ValidatorIncidentParser{Id: "validator_incident", DowntimeJailDuration: 10 * time.Minute}
+	blockTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Downtime slashes and jails on one event, the jail starts at the block
+	indexBlockEvent(t, store, parser, slashEvent(
+		slashingTypes.AttributeKeyAddress, testConsAddress,
+		slashingTypes.AttributeKeyPower, "1000",
+		slashingTypes.AttributeKeyReason, slashingTypes.AttributeValueMissingSignature,
+		slashingTypes.AttributeKeyJailed, testConsAddress,
+		slashingTypes.AttributeKeyBurnedCoins, "10",
+	), blockTime)
+	// Double signing jails on an event holding only the jailed address
+	indexBlockEvent(t, store, parser, slashEvent(slashingTypes.AttributeKeyJailed, testConsAddress), blockTime)
+
+	incidents := validatorIncidents(t, store)
+	if len(incidents) != 2 {
+		t.Fatalf("got %d incidents, want 2", len(incidents))
+	}
+	downtime := incidents[0]
+	if downtime.Type != types.Slash || downtime.Reason != slashingTypes.AttributeValueMissingSignature || downtime.SlashedPower != "1000" || downtime.BurnedCoins != "10" ||
+		!downtime.Jailed || !downtime.JailedUntil.Equal(blockTime.Add(10*time.Minute)) || downtime.Height != 100 || downtime.ConsAddress != testConsAddress {
+		t.Errorf("got downtime incident %+v", downtime)
+	}
+	doubleSign := incidents[1]
+	if doubleSign.Type != types.Jail || doubleSign.Reason != slashingTypes.AttributeValueDoubleSign || !doubleSign.JailedUntil.Equal(evidenceTypes.DoubleSignJailEndTime) {
+		t.Errorf("got double sign incident %+v", doubleSign)
+	}
+
+	if _, err := parser.ParseBlockEvent(slashEvent(slashingTypes.AttributeKeyReason, slashingTypes.AttributeValueMissingSignature)); err == nil {
+		t.Error("parsed a slash event without an address")
+	}
+}
+
+func TestValidatorIncidentParserJailUntilAttribute(t *testing.T) {
+	store := newTestLdb(t)
+	parser := &ValidatorIncidentParser{Id: "validator_incident", DowntimeJailDuration: 10 * time.Minute}
+	blockTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	jailUntil := blockTime.Add(time.Hour)
+
+	indexBlockEvent(t, store, parser, slashEvent(
+		slashingTypes.AttributeKeyAddress, testValidator,
+		slashingTypes.AttributeKeyReason, slashingTypes.AttributeValueMissingSignature,
+		slashingTypes.AttributeKeyJailed, testValidator,
+		attributeKeyJailUntil, jailUntil.Format(time.RFC3339Nano),
+	), blockTime)
+
+	incidents := validatorIncidents(t, store)
+	if len(incidents) != 1 || !incidents[0].JailedUntil.Equal(jailUntil) {
+		t.Errorf("got incidents %+v, want jailed until %s", incidents, jailUntil)
+	}
+
+	if _, err := parser.ParseBlockEvent(slashEvent(slashingTypes.AttributeKeyAddress, testValidator, attributeKeyJailUntil, "soon")); err == nil {
+		t.Error("parsed a slash event with an invalid jail_until")
+	}
+}
+
+func TestValidatorIncidentParserLiveness(t *testing.T) {
+	store := newTestLdb(t)
+	parser := &ValidatorIncidentParser{Id: "validator_incident"}
+	blockTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	indexBlockEvent(t, store, parser, abci.Event{Type: slashingTypes.EventTypeLiveness, Attributes: []abci.EventAttribute{
+		{Key: slashingTypes.AttributeKeyAddress, Value: testConsAddress},
+		{Key: slashingTypes.AttributeKeyMissedBlocks, Value: "7"},
+		{Key: slashingTypes.AttributeKeyHeight, Value: "99"},
+	}}, blockTime)
+
+	// A validator without a known operator address is kept under its consensus address
+	record, err := store.GetRecordByType(&types.ValidatorLiveness{Validator: testConsAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	liveness, ok := record.(*types.ValidatorLiveness)
+	if !ok || liveness.MissedBlocks != 7 || liveness.LastMissedHeight != 99 || !liveness.LastMissedTime.Equal(blockTime) {
+		t.Errorf("got liveness %+v", record)
+	}
+
+	if _, err := parser.ParseBlockEvent(abci.Event{Type: slashingTypes.EventTypeLiveness}); err == nil {
+		t.Error("parsed a liveness event without missed blocks")
+	}
+}
+
+func TestMsgUnjailParser(t *testing.T) {
+	store := newTestLdb(t)
+	parser := &MsgUnjailParser{Id: "unjail"}
+	dataset, err := parser.ParseMessage(&slashingTypes.MsgUnjail{ValidatorAddr: testValidator}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	message := types.Message{Tx: types.Tx{Block: types.Block{Height: 42, TimeStamp: blockTime}}}
+	err = store.Transaction(func(view db.View) error {
+		return parser.IndexMessage(view, "unjailtx", dataset, message, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	incidents := validatorIncidents(t, store)
+	if len(incidents) != 1 || incidents[0].Type != types.Unjail || incidents[0].TxHash != "unjailtx" || incidents[0].Height != 42 || !incidents[0].Time.Equal(blockTime) {
+		t.Errorf("got incidents %+v, want the unjail", incidents)
+	}
+}
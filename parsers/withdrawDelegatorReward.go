@@ -5,10 +5,10 @@ import (
 	"errors"
 	stdTypes "github.com/cosmos/cosmos-sdk/types"
 	distributionTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
-	"github.com/syndtr/goleveldb/leveldb"
 	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
 	"mtt-indexer/db"
 	"mtt-indexer/types"
+	"strings"
 )
 
 // This defines the custom message parsers for the delegation and undelegation message type
@@ -27,11 +27,12 @@ func (c *MsgWithdrawDelegatorRewardParser) ParseMessage(cosmosMsg stdTypes.Msg,
 		return nil, errors.New("not a delegation message")
 	}
 
+	// Withdrawing no rewards emits an empty amount, and events may carry no attributes
 	amount := ""
-	if len(log.Events) > 0 {
-		if log.Events[len(log.Events)-1].Attributes[0].Key == "amount" {
-			amount = log.Events[len(log.Events)-1].Attributes[0].Value
-			amount = amount[0 : len(amount)-4]
+	if log != nil && len(log.Events) > 0 {
+		attributes := log.Events[len(log.Events)-1].Attributes
+		if len(attributes) > 0 && attributes[0].Key == "amount" {
+			amount = strings.TrimSuffix(attributes[0].Value, "amtt")
 		}
 	}
 
@@ -46,14 +47,15 @@ func (c *MsgWithdrawDelegatorRewardParser) ParseMessage(cosmosMsg stdTypes.Msg,
 	return &storageVal, nil
 }
 
-func (c *MsgWithdrawDelegatorRewardParser) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+func (c *MsgWithdrawDelegatorRewardParser) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
 	validatorRecord, ok := (*dataset).(types.ValidatorRecord)
 	if !ok {
 		return errors.New("not a ValidatorRecord type")
 	}
 	validatorRecord.TxHash = txhash
 	validatorRecord.DelegationTime = message.Tx.Block.TimeStamp
-	err := db.StoreRecord(ldb.DB, batch, &validatorRecord)
+	validatorRecord.Grantee = message.Grantee
+	err := view.StoreRecord(&validatorRecord)
 	if err != nil {
 		return err
 	}
@@ -61,7 +63,7 @@ func (c *MsgWithdrawDelegatorRewardParser) IndexMessage(ldb *db.LDB, batch *leve
 	outList := &types.DelegatorOutList{
 		Delegator: validatorRecord.Delegator,
 	}
-	record, err := ldb.GetRecordByType(outList)
+	record, err := view.GetRecordByType(outList)
 	if err != nil {
 		return err
 	}
@@ -78,18 +80,18 @@ func (c *MsgWithdrawDelegatorRewardParser) IndexMessage(ldb *db.LDB, batch *leve
 		}
 	}
 	outList.AddValidatorRecord(validatorRecord, false)
-	err = db.StoreRecord(ldb.DB, batch, outList)
+	err = view.StoreRecord(outList)
 	if err != nil {
 		return err
 	}
 
-	err = db.StoreRecord(ldb.DB, batch, validatorRecord.ToDelegate())
+	err = view.StoreRecord(validatorRecord.ToDelegate())
 	if err != nil {
 		return err
 	}
 
 	//save claimed24H record
-	IRecord, err := ldb.GetRecordByType(&types.Claimed24H{Validator: validatorRecord.Validator})
+	IRecord, err := view.GetRecordByType(&types.Claimed24H{Validator: validatorRecord.Validator})
 	if err != nil {
 		return err
 	}
@@ -108,6 +110,6 @@ func (c *MsgWithdrawDelegatorRewardParser) IndexMessage(ldb *db.LDB, batch *leve
 		amount = amount.Add(claimAmount)
 		storeRecord.Amount = amount.String()
 	}
-	return db.StoreRecord(ldb.DB, batch, storeRecord)
+	return view.StoreRecord(storeRecord)
 
 }
@@ -0,0 +1,40 @@
+package parsers
+
+import (
+	distributionTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
+	"mtt-indexer/types"
+	"testing"
+)
+
+func TestWithdrawDelegatorRewardParserAmount(t *testing.T) {
+	parser := &MsgWithdrawDelegatorRewardParser{Id: "withdraw_delegator_reward"}
+	msg := &distributionTypes.MsgWithdrawDelegatorReward{DelegatorAddress: "mtt12x07g3270742n42heupleuwvjuzn5j6x4dmysj", ValidatorAddress: testValidator}
+	withdrawLog := func(attributes ...indexerTxTypes.Attribute) *indexerTxTypes.LogMessage {
+		return &indexerTxTypes.LogMessage{Events: []indexerTxTypes.LogMessageEvent{
+			{Type: "message"},
+			{Type: "withdraw_rewards", Attributes: attributes},
+		}}
+	}
+
+	for _, test := range []struct {
+		name string
+		log  *indexerTxTypes.LogMessage
+		want string
+	}{
+		{"rewards", withdrawLog(indexerTxTypes.Attribute{Key: "amount", Value: "125amtt"}, indexerTxTypes.Attribute{Key: "validator", Value: testValidator}), "125"},
+		{"no rewards", withdrawLog(indexerTxTypes.Attribute{Key: "amount", Value: ""}), ""},
+		{"no attributes", withdrawLog(), ""},
+		{"no events", &indexerTxTypes.LogMessage{}, ""},
+		{"no log", nil, ""},
+	} {
+		dataset, err := parser.ParseMessage(msg, test.log)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		record := (*dataset).(types.ValidatorRecord)
+		if record.Amount != test.want || record.DelegationType != types.Claim {
+			t.Errorf("%s: got record %+v, want amount %q", test.name, record, test.want)
+		}
+	}
+}
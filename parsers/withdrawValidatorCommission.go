@@ -3,15 +3,15 @@ package parsers
 import (
 	sdkmath "cosmossdk.io/math"
 	"errors"
+	"fmt"
 	stdTypes "github.com/cosmos/cosmos-sdk/types"
 	distributionTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
-	"github.com/syndtr/goleveldb/leveldb"
 	indexerTxTypes "mtt-indexer/cosmos/modules/tx"
 	"mtt-indexer/db"
 	"mtt-indexer/types"
 )
 
-// This defines the custom message parsers for the delegation and undelegation message type
+// This defines the custom message parsers for the withdraw validator commission message type
 // It implements the MessageParser interface
 type MsgWithdrawValidatorCommission struct {
 	Id string
@@ -22,53 +22,55 @@ func (c *MsgWithdrawValidatorCommission) Identifier() string {
 }
 
 func (c *MsgWithdrawValidatorCommission) ParseMessage(cosmosMsg stdTypes.Msg, log *indexerTxTypes.LogMessage) (*any, error) {
-	msgWithdrawDelegator, ok := cosmosMsg.(*distributionTypes.MsgWithdrawValidatorCommission)
+	msgWithdrawCommission, ok := cosmosMsg.(*distributionTypes.MsgWithdrawValidatorCommission)
 	if !ok {
-		return nil, errors.New("not a delegation message")
+		return nil, errors.New("not a withdraw validator commission message")
 	}
 
-	amount := ""
-	if len(log.Events) > 0 {
-		if log.Events[len(log.Events)-1].Attributes[0].Key == "amount" {
-			amount = log.Events[len(log.Events)-1].Attributes[0].Value
-			amount = amount[0 : len(amount)-4]
-		}
-	}
-
-	storageVal := any(types.RewardRecord{
-		Validator: msgWithdrawDelegator.ValidatorAddress,
-		Amount:    amount,
+	storageVal := any(types.CommissionWithdrawal{
+		Validator: msgWithdrawCommission.ValidatorAddress,
 	})
 
 	return &storageVal, nil
 }
 
-func (c *MsgWithdrawValidatorCommission) IndexMessage(ldb *db.LDB, batch *leveldb.Batch, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
-	rewardRecord, ok := (*dataset).(types.RewardRecord)
+// IndexMessage stores the withdrawal with the coins of its withdraw_commission event and
+// adds the staking denom amount to the validator's CommissionWithdrawn.
+func (c *MsgWithdrawValidatorCommission) IndexMessage(view db.View, txhash string, dataset *any, message types.Message, messageEvents []MessageEventWithAttributes) error {
+	withdrawal, ok := (*dataset).(types.CommissionWithdrawal)
 	if !ok {
-		return errors.New("not a RewardRecord type")
+		return errors.New("not a CommissionWithdrawal type")
 	}
 
-	//save reward record
+	amount := GetMessageEventAttribute(messageEvents, distributionTypes.EventTypeWithdrawCommission, stdTypes.AttributeKeyAmount)
+	coins, err := stdTypes.ParseCoinsNormalized(amount)
+	if err != nil {
+		return fmt.Errorf("invalid withdrawn commission %q: %w", amount, err)
+	}
+	for _, coin := range coins {
+		withdrawal.Coins = append(withdrawal.Coins, types.Coin{Denom: coin.Denom, Amount: coin.Amount.String()})
+	}
+	withdrawal.Height = message.Tx.Block.Height
+	withdrawal.TxHash = txhash
+	withdrawal.Time = message.Tx.Block.TimeStamp
+	if err := view.StoreRecord(&withdrawal); err != nil {
+		return err
+	}
 
-	IRecord, err := ldb.GetRecordByType(&types.Claimed24H{Validator: rewardRecord.Validator})
+	IRecord, err := view.GetRecordByType(&types.CommissionWithdrawn{Validator: withdrawal.Validator})
 	if err != nil {
 		return err
 	}
-	storeRecord := &types.Claimed24H{}
-	if IRecord == nil {
-		storeRecord = &types.Claimed24H{
-			Validator: rewardRecord.Validator,
-			Amount:    rewardRecord.Amount,
-		}
-	} else {
-		if record, ok := IRecord.(*types.Claimed24H); ok {
-			storeRecord = record
-		}
-		amount, _ := sdkmath.NewIntFromString(storeRecord.Amount)
-		claimAmount, _ := sdkmath.NewIntFromString(rewardRecord.Amount)
-		amount = amount.Add(claimAmount)
-		storeRecord.Amount = amount.String()
+	storeRecord := &types.CommissionWithdrawn{
+		Validator: withdrawal.Validator,
+	}
+	if record, ok := IRecord.(*types.CommissionWithdrawn); ok {
+		storeRecord = record
+	}
+	withdrawn, ok := sdkmath.NewIntFromString(storeRecord.Amount)
+	if !ok {
+		withdrawn = sdkmath.ZeroInt()
 	}
-	return db.StoreRecord(ldb.DB, batch, storeRecord)
+	storeRecord.Amount = withdrawn.Add(withdrawal.AmountOf(types.StakeDenom)).String()
+	return view.StoreRecord(storeRecord)
 }
@@ -0,0 +1,83 @@
+package parsers
+
+import (
+	distributionTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+	"time"
+)
+
+func withdrawCommissionEvents(amount string) []MessageEventWithAttributes {
+	return []MessageEventWithAttributes{{
+		Event: types.MessageEvent{MessageEventType: types.MessageEventType{Type: distributionTypes.EventTypeWithdrawCommission}},
+		Attributes: []types.MessageEventAttribute{
+			{Value: amount, MessageEventAttributeKey: types.MessageEventAttributeKey{Key: "amount"}},
+		},
+	}}
+}
+
+func TestWithdrawValidatorCommissionLedger(t *testing.T) {
+	store := newTestLdb(t)
+	parser := &MsgWithdrawValidatorCommission{Id: "withdraw_validator_commission"}
+	blockTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	message := types.Message{Tx: types.Tx{Block: types.Block{Height: 8, TimeStamp: blockTime}}}
+	withdraw := func(txHash, amount string) error {
+		dataset, err := parser.ParseMessage(&distributionTypes.MsgWithdrawValidatorCommission{ValidatorAddress: testValidator}, nil)
+		if err != nil {
+			return err
+		}
+		return store.Transaction(func(view db.View) error {
+			return parser.IndexMessage(view, txHash, dataset, message, withdrawCommissionEvents(amount))
+		})
+	}
+
+	for txHash, amount := range map[string]string{"tx1": "100amtt,5uatom", "tx2": "20amtt"} {
+		if err := withdraw(txHash, amount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A validator without commission withdraws nothing
+	if err := withdraw("tx3", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := withdraw("tx4", "lots"); err == nil {
+		t.Error("indexed an invalid commission amount")
+	}
+
+	records, total, err := store.GetAllRecordsWithAutoId(&types.CommissionWithdrawal{Validator: testValidator}, 10, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Fatalf("got %d withdrawals, want 3", total)
+	}
+	byTx := map[string]*types.CommissionWithdrawal{}
+	for _, record := range records {
+		withdrawal := record.(*types.CommissionWithdrawal)
+		byTx[withdrawal.TxHash] = withdrawal
+	}
+	first := byTx["tx1"]
+	if first == nil || len(first.Coins) != 2 || first.AmountOf("amtt").String() != "100" || first.AmountOf("uatom").String() != "5" || first.Height != 8 || !first.Time.Equal(blockTime) {
+		t.Errorf("got withdrawal %+v", first)
+	}
+	if empty := byTx["tx3"]; empty == nil || len(empty.Coins) != 0 {
+		t.Errorf("got withdrawal %+v without commission", empty)
+	}
+
+	// Only the staking denom counts towards the withdrawn total, delegator claims stay apart
+	record, err := store.GetRecordByType(&types.CommissionWithdrawn{Validator: testValidator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withdrawn, ok := record.(*types.CommissionWithdrawn); !ok || withdrawn.Amount != "120" {
+		t.Errorf("got withdrawn commission %+v, want 120", record)
+	}
+	record, err = store.GetRecordByType(&types.Claimed24H{Validator: testValidator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := record.(*types.Claimed24H); ok {
+		t.Errorf("got claimed %+v from commission withdrawals", record)
+	}
+}
@@ -1,13 +1,14 @@
 package router
 
 import (
+	"crypto/subtle"
 	"github.com/gin-gonic/gin"
 	"mtt-indexer/controller"
 	"mtt-indexer/service"
 	"net/http"
 )
 
-func Init(s service.IService) *gin.Engine {
+func Init(s service.IService, adminToken, backupDir string) *gin.Engine {
 	r := gin.Default()
 	group := r.Group("")
 
@@ -18,10 +19,43 @@ func Init(s service.IService) *gin.Engine {
 	group.GET("/validatorHistory", controller.ValidatorHistoryEndpoint(s))
 	group.GET("/rewardHistory", controller.RewardHistoryEndpoint(s))
 	group.GET("/commissionRecord", controller.CommissionRecordEndpoint(s))
+	group.GET("/commissionWithdrawals", controller.CommissionWithdrawalsEndpoint(s))
 	group.GET("/height", controller.HeightEndpoint(s))
+	group.GET("/unbondings", controller.UnbondingsEndpoint(s))
+	group.GET("/transfers", controller.TransfersEndpoint(s))
+	group.GET("/authorizations", controller.AuthorizationsEndpoint(s))
+	group.GET("/proposals", controller.ProposalsEndpoint(s))
+	group.GET("/proposal", controller.ProposalEndpoint(s))
+	group.GET("/proposalVotes", controller.ProposalVotesEndpoint(s))
+	group.GET("/votes", controller.VotesEndpoint(s))
+	group.GET("/validatorIncidents", controller.ValidatorIncidentsEndpoint(s))
+	group.GET("/validatorLiveness", controller.ValidatorLivenessEndpoint(s))
+	group.GET("/failedBlocks", controller.FailedBlocksEndpoint(s))
+
+	admin := r.Group("/admin")
+	admin.Use(AdminAuth(adminToken))
+	admin.POST("/backup", controller.BackupEndpoint(s, backupDir))
+	admin.POST("/failedBlocks/requeue", controller.RequeueFailedBlockEndpoint(s))
 	return r
 }
 
+// AdminAuth requires "Authorization: Bearer <token>". With an empty token the admin
+// endpoints are disabled.
+func AdminAuth(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
 func Cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		method := c.Request.Method
@@ -4,6 +4,7 @@ import (
 	"context"
 	sdkmath "cosmossdk.io/math"
 	distributionTypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	slashingTypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
 	stakingTypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 	"time"
 
@@ -94,6 +95,48 @@ func AllValidator(cl *probeClient.ChainClient) ([]string, error) {
 	return validators, nil
 }
 
+// AllValidatorConsAddresses returns the consensus address of every validator keyed by operator address
+func AllValidatorConsAddresses(cl *probeClient.ChainClient) (map[string]string, error) {
+	client := stakingTypes.NewQueryClient(cl)
+	consAddresses := map[string]string{}
+	var key []byte
+	for {
+		res, err := client.Validators(context.Background(), &stakingTypes.QueryValidatorsRequest{
+			Pagination: &query.PageRequest{
+				Key: key,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.Validators {
+			err = v.UnpackInterfaces(cl.Codec.InterfaceRegistry)
+			if err != nil {
+				return nil, err
+			}
+			consAddr, err := v.GetConsAddr()
+			if err != nil {
+				return nil, err
+			}
+			consAddresses[v.OperatorAddress] = consAddr.String()
+		}
+		if res.Pagination == nil || len(res.Pagination.NextKey) == 0 {
+			break
+		}
+		key = res.Pagination.NextKey
+	}
+	return consAddresses, nil
+}
+
+func GetSlashingParams(cl *probeClient.ChainClient) (slashingTypes.Params, error) {
+	client := slashingTypes.NewQueryClient(cl)
+	res, err := client.Params(context.Background(), &slashingTypes.QueryParamsRequest{})
+	if err != nil {
+		return slashingTypes.Params{}, err
+	}
+	return res.Params, nil
+}
+
 // IsCatchingUp true if the node is catching up to the chain, false otherwise
 func IsCatchingUp(cl *probeClient.ChainClient) (bool, error) {
 	query := probeQuery.Query{Client: cl, Options: &probeQuery.QueryOptions{}}
@@ -170,3 +213,30 @@ func GetEarliestAndLatestBlockHeights(cl *probeClient.ChainClient) (int64, int64
 	}
 	return resStatus.SyncInfo.EarliestBlockHeight, resStatus.SyncInfo.LatestBlockHeight, nil
 }
+
+// GetDelegatorDelegations returns the delegated balance of delegator per validator at height.
+func GetDelegatorDelegations(cl *probeClient.ChainClient, delegator string, height int64) (map[string]sdkmath.Int, error) {
+	client := stakingTypes.NewQueryClient(cl)
+	ctx := probeClient.SetHeightOnContext(context.Background(), height)
+
+	balances := map[string]sdkmath.Int{}
+	var key []byte
+	for {
+		res, err := client.DelegatorDelegations(ctx, &stakingTypes.QueryDelegatorDelegationsRequest{
+			DelegatorAddr: delegator,
+			Pagination: &query.PageRequest{
+				Key: key,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range res.DelegationResponses {
+			balances[d.Delegation.ValidatorAddress] = d.Balance.Amount
+		}
+		if res.Pagination == nil || len(res.Pagination.NextKey) == 0 {
+			return balances, nil
+		}
+		key = res.Pagination.NextKey
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mtt-indexer/logger"
+	"mtt-indexer/types"
+	"sync"
+)
+
+const DefaultFetchWorkers = 4
+const DefaultFetchWindow = 32
+
+// ErrChainDivergence is returned when a fetched block does not build on the indexed block before it.
+var ErrChainDivergence = errors.New("chain divergence")
+
+type fetchResult struct {
+	height int64
+	data   *IndexerBlockEventData
+	err    error
+}
+
+// syncRange fetches every height in [from, to] with a pool of workers and hands the
+// results to processBlockData strictly in height order, see fetchInOrder. The blocking
+// send on txDataChan inside processBlockData throttles the whole pipeline to the commit rate.
+// Every block must reference the hash of the block indexed before it as its parent.
+func (s *ChainService) syncRange(from, to int64, onProcessed func(height int64, hash string)) error {
+	if from > to {
+		return nil
+	}
+
+	parentHash, err := s.expectedParentHash(from)
+	if err != nil {
+		return err
+	}
+
+	workers := s.fetchWorkers
+	if workers <= 0 {
+		workers = DefaultFetchWorkers
+	}
+	window := s.fetchWindow
+	if window <= 0 {
+		window = DefaultFetchWindow
+	}
+
+	return fetchInOrder(from, to, workers, window, s.fetchBlock, func(height int64, data *IndexerBlockEventData) error {
+		if data.BlockData.Block.Height != height {
+			return fmt.Errorf("fetched block height %d does not match requested height %d", data.BlockData.Block.Height, height)
+		}
+
+		hash := data.BlockData.BlockID.Hash.String()
+		lastBlockHash := data.BlockData.Block.LastBlockID.Hash.String()
+		if parentHash != "" && lastBlockHash != parentHash {
+			return fmt.Errorf("%w: block %d has parent hash %s but the indexed block %d has hash %s. The RPC node at %s serves a different chain history",
+				ErrChainDivergence, height, lastBlockHash, height-1, parentHash, s.cl.Config.RPCAddr)
+		}
+
+		err := s.processBlockData(s.handleFailedBlock, data)
+		if err != nil {
+			logger.Logger.Errorf("Error processing block data: %v", err)
+			return err
+		}
+
+		if onProcessed != nil {
+			onProcessed(height, hash)
+		}
+		parentHash = hash
+		return nil
+	})
+}
+
+// fetchInOrder fetches every height in [from, to] with workers goroutines and calls process
+// for each of them strictly in height order, reordering the results as they come in. At
+// most window heights are in flight (fetching or waiting in the reorder buffer) at any
+// time. It stops at the first fetch or process error and returns it.
+func fetchInOrder(from, to int64, workers, window int, fetch func(height int64) (*IndexerBlockEventData, error), process func(height int64, data *IndexerBlockEventData) error) error {
+	if window < workers {
+		window = workers
+	}
+
+	done := make(chan struct{})
+	slots := make(chan struct{}, window)
+	heights := make(chan int64)
+	results := make(chan fetchResult, window)
+
+	var wg sync.WaitGroup
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(heights)
+		for height := from; height <= to; height++ {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case heights <- height:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for height := range heights {
+				data, err := fetch(height)
+				select {
+				case results <- fetchResult{height: height, data: data, err: err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	pending := make(map[int64]fetchResult, window)
+	next := from
+	for next <= to {
+		result := <-results
+		pending[result.height] = result
+
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+
+			if ready.err != nil {
+				return ready.err
+			}
+			if err := process(next, ready.data); err != nil {
+				return err
+			}
+			<-slots
+			next++
+		}
+	}
+
+	return nil
+}
+
+// expectedParentHash returns the hash the block at height must name as its parent, or ""
+// when the previous block was indexed before hashes were tracked.
+func (s *ChainService) expectedParentHash(height int64) (string, error) {
+	chain := s.indexedChain()
+	if height == chain.Height+1 && chain.Hash != "" {
+		return chain.Hash, nil
+	}
+
+	record, err := s.store.GetRecordByType(&types.BlockHash{Height: height - 1})
+	if err != nil {
+		return "", err
+	}
+	if blockHash, ok := record.(*types.BlockHash); ok {
+		return blockHash.Hash, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/DefiantLabs/probe/client"
+	cmtbytes "github.com/cometbft/cometbft/libs/bytes"
+	ctypes "github.com/cometbft/cometbft/rpc/core/types"
+	cmttypes "github.com/cometbft/cometbft/types"
+	"mtt-indexer/db"
+	"mtt-indexer/rpc"
+	"mtt-indexer/types"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testBlockData(height int64) *IndexerBlockEventData {
+	return &IndexerBlockEventData{BlockData: &ctypes.ResultBlock{Block: &cmttypes.Block{Header: cmttypes.Header{Height: height}}}}
+}
+
+func TestFetchInOrderReordersResults(t *testing.T) {
+	const workers, window = 4, 6
+	var inFlight, maxInFlight int64
+
+	var processed []int64
+	err := fetchInOrder(1, 60, workers, window, func(height int64) (*IndexerBlockEventData, error) {
+		current := atomic.AddInt64(&inFlight, 1)
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, current) {
+				break
+			}
+		}
+		// Later heights of a batch finish first
+		time.Sleep(time.Duration(10-height%10) * time.Millisecond)
+		return testBlockData(height), nil
+	}, func(height int64, data *IndexerBlockEventData) error {
+		if data.BlockData.Block.Height != height {
+			t.Errorf("got block %d for height %d", data.BlockData.Block.Height, height)
+		}
+		processed = append(processed, height)
+		atomic.AddInt64(&inFlight, -1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, height := range processed {
+		if height != int64(i+1) {
+			t.Fatalf("processed heights %v out of order", processed)
+		}
+	}
+	if len(processed) != 60 {
+		t.Errorf("processed %d heights, want 60", len(processed))
+	}
+	if maxInFlight > window {
+		t.Errorf("got %d heights in flight, window is %d", maxInFlight, window)
+	}
+}
+
+func TestFetchInOrderStopsAtFirstError(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	errProcess := errors.New("process failed")
+
+	for _, test := range []struct {
+		name          string
+		fetchErr      error
+		processErr    error
+		wantProcessed int
+	}{
+		{"fetch", errFetch, nil, 6},
+		{"process", nil, errProcess, 7},
+	} {
+		var fetched int64
+		var processed []int64
+		err := fetchInOrder(1, 1000, 3, 5, func(height int64) (*IndexerBlockEventData, error) {
+			atomic.AddInt64(&fetched, 1)
+			if height == 7 && test.fetchErr != nil {
+				return nil, test.fetchErr
+			}
+			return testBlockData(height), nil
+		}, func(height int64, data *IndexerBlockEventData) error {
+			processed = append(processed, height)
+			if height == 7 && test.processErr != nil {
+				return test.processErr
+			}
+			return nil
+		})
+		if err == nil || (err != test.fetchErr && err != test.processErr) {
+			t.Errorf("%s: got error %v", test.name, err)
+		}
+		if len(processed) != test.wantProcessed {
+			t.Errorf("%s: processed heights %v", test.name, processed)
+		}
+		// Nothing beyond the window is fetched once the pipeline stopped
+		if fetched > int64(test.wantProcessed+5+1) {
+			t.Errorf("%s: fetched %d heights", test.name, fetched)
+		}
+	}
+}
+
+// storeRawBlocks stores empty blocks for [from, to] that build on each other, except for
+// the parents overridden in wrongParent.
+func storeRawBlocks(t *testing.T, s *ChainService, from, to int64, wrongParent map[int64]bool) {
+	t.Helper()
+	hash := func(height int64) cmtbytes.HexBytes {
+		return cmtbytes.HexBytes(fmt.Sprintf("%032d", height))
+	}
+	err := s.store.Transaction(func(view db.View) error {
+		for height := from; height <= to; height++ {
+			parent := hash(height - 1)
+			if wrongParent[height] {
+				parent = hash(0)
+			}
+			block := &ctypes.ResultBlock{
+				BlockID: cmttypes.BlockID{Hash: hash(height)},
+				Block: &cmttypes.Block{Header: cmttypes.Header{
+					Height:          height,
+					Time:            time.Unix(height, 0).UTC(),
+					LastBlockID:     cmttypes.BlockID{Hash: parent},
+					ProposerAddress: make([]byte, 20),
+				}},
+			}
+			rawBlock, err := newRawBlock(block, &rpc.CustomBlockResults{Height: height})
+			if err != nil {
+				return err
+			}
+			if err := view.StoreRecord(rawBlock); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// syncFromStorage runs syncToLatest's pipeline up to height against the stored raw blocks.
+func syncFromStorage(s *ChainService, to int64) error {
+	s.fromStorage = true
+	s.fetchWorkers = 3
+	s.fetchWindow = 4
+	s.cl = &client.ChainClient{Config: &client.ChainClientConfig{RPCAddr: "stored blocks"}}
+	s.txDataChan = make(chan *DBData, 10)
+	s.flushStopped = make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go s.flushData(&wg)
+
+	err := s.syncRange(s.indexedChain().Height+1, to, s.setChainHead)
+	close(s.txDataChan)
+	wg.Wait()
+	return err
+}
+
+func TestSyncRangeCommitsInOrder(t *testing.T) {
+	s := newTestChainService(t)
+	storeRawBlocks(t, s, 1, 40, nil)
+
+	if err := syncFromStorage(s, 40); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := s.store.GetRecordByType(&types.Chain{Name: "mtt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain, ok := record.(*types.Chain); !ok || chain.Height != 40 {
+		t.Fatalf("got stored chain %+v, want height 40", record)
+	}
+	undoLogs, err := s.store.GetUndoLogs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undoLogs) != 40 {
+		t.Fatalf("got %d commits, want 40", len(undoLogs))
+	}
+	for i, undoLog := range undoLogs {
+		if undoLog.Height != int64(40-i) {
+			t.Fatalf("block %d committed at position %d", undoLog.Height, len(undoLogs)-i)
+		}
+	}
+}
+
+func TestSyncRangeStopsAtDivergence(t *testing.T) {
+	s := newTestChainService(t)
+	storeRawBlocks(t, s, 1, 20, map[int64]bool{12: true})
+
+	err := syncFromStorage(s, 20)
+	if !errors.Is(err, ErrChainDivergence) {
+		t.Fatalf("got error %v, want a chain divergence", err)
+	}
+	if height := s.indexedChain().Height; height != 11 {
+		t.Errorf("got chain at %d, want 11 below the diverging block", height)
+	}
+	record, err := s.store.GetRecordByType(&types.BlockHash{Height: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := record.(*types.BlockHash); ok {
+		t.Error("the diverging block was committed")
+	}
+}
+
+func TestExpectedParentHash(t *testing.T) {
+	s := newTestChainService(t)
+	s.setChainHead(10, "hash10")
+	err := s.store.Transaction(func(view db.View) error {
+		return view.StoreRecord(&types.BlockHash{Height: 4, Hash: "hash4", ParentHash: "hash3"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		height int64
+		want   string
+	}{
+		// The next block follows the chain head
+		{11, "hash10"},
+		// A backfilled block follows the stored hash of the block before it
+		{5, "hash4"},
+		// Blocks indexed before hashes were tracked are not checked
+		{8, ""},
+	} {
+		got, err := s.expectedParentHash(test.height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("block %d: got parent hash %q, want %q", test.height, got, test.want)
+		}
+	}
+
+	// A chain head without a hash falls back to the stored block hashes
+	s.setChainHead(4, "")
+	if got, err := s.expectedParentHash(5); err != nil || got != "hash4" {
+		t.Errorf("got parent hash %q (%v), want hash4", got, err)
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"github.com/DefiantLabs/probe/client"
 	abci "github.com/cometbft/cometbft/abci/types"
@@ -9,7 +10,6 @@ import (
 	sdkTypes "github.com/cosmos/cosmos-sdk/types"
 	txTypes "github.com/cosmos/cosmos-sdk/types/tx"
 	"github.com/mtt-labs/mtt-chain/crypto/ethsecp256k1"
-	"github.com/syndtr/goleveldb/leveldb"
 	"mtt-indexer/core"
 	"mtt-indexer/db"
 	"mtt-indexer/filter"
@@ -34,6 +34,13 @@ type IndexerBlockEventData struct {
 	TxRequestsFailed         bool
 	IndexBlockEvents         bool
 	IndexTransactions        bool
+	// Backfill marks data that is re-indexed out of order and must not move the stored chain height.
+	Backfill bool
+	// RawBlock is the block to store for re-indexing, nil when store_raw_blocks is off
+	RawBlock *types.RawBlock
+	// FailedBlock is the queue entry of a retried block, holding the failures of this
+	// attempt. It is stored with the block, or removed when there were none.
+	FailedBlock *types.FailedBlock
 }
 
 type BlockEventFilterRegistries struct {
@@ -42,8 +49,11 @@ type BlockEventFilterRegistries struct {
 }
 
 type ChainService struct {
-	ldb   *db.LDB
+	store db.Store
 	chain *types.Chain
+	// chainLock guards chain, which the sync loop advances while the flush loop and the
+	// failed block retrier read it
+	chainLock sync.RWMutex
 
 	BlockEventFilterRegistries BlockEventFilterRegistries
 
@@ -59,7 +69,32 @@ type ChainService struct {
 	cl        *client.ChainClient
 	rpcClient rpc.URIClient
 
-	txDataChan chan *DBData
+	txDataChan   chan *DBData
+	flushStopped chan struct{}
+	backfilling  bool
+
+	fetchWorkers int
+	fetchWindow  int
+
+	storeRawBlocks bool
+	fromStorage    bool
+
+	failedBlockMaxAttempts int
+	undoRetention          int64
+}
+
+// indexedChain returns a copy of the chain as far as it has been handed to the flush loop.
+func (s *ChainService) indexedChain() *types.Chain {
+	s.chainLock.RLock()
+	defer s.chainLock.RUnlock()
+	return s.chain.Clone()
+}
+
+func (s *ChainService) setChainHead(height int64, hash string) {
+	s.chainLock.Lock()
+	defer s.chainLock.Unlock()
+	s.chain.Height = height
+	s.chain.Hash = hash
 }
 
 func NewChainClient(
@@ -89,13 +124,18 @@ func NewChainClient(
 }
 
 func NewChainService(
-	ldb *db.LDB,
+	store db.Store,
 	chain *types.Chain,
 	cl *client.ChainClient,
+	fetchWorkers int,
+	fetchWindow int,
+	storeRawBlocks bool,
+	failedBlockMaxAttempts int,
+	undoRetention int64,
 ) (*ChainService, error) {
 
 	return &ChainService{
-		ldb:   ldb,
+		store: store,
 		chain: chain,
 		BlockEventFilterRegistries: BlockEventFilterRegistries{
 			BeginBlockEventFilterRegistry: &filter.StaticBlockEventFilterRegistry{},
@@ -108,19 +148,104 @@ func NewChainService(
 			Address: cl.Config.RPCAddr,
 			Client:  &http.Client{},
 		},
-		txDataChan: make(chan *DBData, 10),
+		txDataChan:     make(chan *DBData, 10),
+		flushStopped:   make(chan struct{}),
+		fetchWorkers:   fetchWorkers,
+		fetchWindow:    fetchWindow,
+		storeRawBlocks: storeRawBlocks,
+
+		failedBlockMaxAttempts: failedBlockMaxAttempts,
+		undoRetention:          undoRetention,
 	}, nil
 }
 
 func (s *ChainService) Start(wg *sync.WaitGroup) error {
 	go s.syncBlockLoop()
 	go s.flushData(wg)
+	go s.retryFailedBlocksLoop()
+	return nil
+}
+
+// PrepareStartHeight decides where syncing resumes. A fresh database starts at startHeight
+// when it is set. If the node has pruned the next height to index, startup is refused unless
+// skipPruned is set, in which case indexing jumps forward to the node's earliest block.
+func (s *ChainService) PrepareStartHeight(startHeight int64, skipPruned bool) error {
+	if s.chain.Height == 0 && startHeight > 1 {
+		logger.Logger.Infof("Fresh database, starting from configured start height %d", startHeight)
+		s.chain.Height = startHeight - 1
+	}
+
+	earliest, latest, err := rpc.GetEarliestAndLatestBlockHeights(s.cl)
+	if err != nil {
+		return err
+	}
+
+	next := s.chain.Height + 1
+	if earliest <= next {
+		return nil
+	}
+
+	if !skipPruned {
+		return fmt.Errorf("node history is pruned: earliest available block is %d (latest %d) but the next height to index is %d; set start_height or skip_pruned", earliest, latest, next)
+	}
+
+	logger.Logger.Warnf("!!! Node history is pruned: earliest available block is %d but the next height to index is %d. SKIPPING %d BLOCKS, data for heights %d to %d will be missing !!!", earliest, next, earliest-next, next, earliest-1)
+	s.chain.Height = earliest - 1
 	return nil
 }
 
+// SyncValidatorConsAddresses stores the consensus address of every current validator so
+// slashing events can be attributed to operator addresses and votes to validators, including
+// for genesis validators.
+func (s *ChainService) SyncValidatorConsAddresses() error {
+	consAddresses, err := rpc.AllValidatorConsAddresses(s.cl)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Transaction(func(view db.View) error {
+		for operator, consAddress := range consAddresses {
+			err := view.StoreRecord(&types.ValidatorConsAddress{
+				ConsAddress: consAddress,
+				Operator:    operator,
+			})
+			if err != nil {
+				return err
+			}
+			err = view.StoreRecord(&types.ValidatorOperator{
+				Operator:    operator,
+				ConsAddress: consAddress,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Backfill re-indexes the inclusive height range [from, to] and returns once every block
+// has been committed. The stored chain height is not modified.
+func (s *ChainService) Backfill(from, to int64) error {
+	s.backfilling = true
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go s.flushData(&wg)
+
+	err := s.syncRange(from, to, nil)
+	close(s.txDataChan)
+	wg.Wait()
+	return err
+}
+
 func (s *ChainService) syncBlockLoop() {
 	if err := s.syncToLatest(); err != nil {
 		logger.Logger.Error("syncToLatest error %v", err)
+		if errors.Is(err, ErrChainDivergence) {
+			logger.Logger.Errorf("Indexing halted: %v. Fix the rpc setting or run rollback --to-height below the diverging block", err)
+			return
+		}
 	}
 
 	ticker := time.NewTicker(time.Second * 3)
@@ -130,6 +255,10 @@ func (s *ChainService) syncBlockLoop() {
 		case <-ticker.C:
 			if err := s.syncToLatest(); err != nil {
 				logger.Logger.Error("syncToLatest error %v", err)
+				if errors.Is(err, ErrChainDivergence) {
+					logger.Logger.Errorf("Indexing halted: %v. Fix the rpc setting or run rollback --to-height below the diverging block", err)
+					return
+				}
 			}
 		}
 	}
@@ -140,24 +269,8 @@ func (s *ChainService) syncToLatest() error {
 	if err != nil {
 		return err
 	}
-	for {
-		if s.chain.Height != height {
-			data, err := s.GetIndexerBlockEventData(s.chain.Height + 1)
-			if err != nil {
-				return err
-			}
-
-			err = s.processBlockData(core.HandleFailedBlock, data)
-			if err != nil {
-				logger.Logger.Errorf("Error processing block data: %v", err)
-				return err
-			}
 
-			s.chain.Height = data.BlockData.Block.Height
-		} else {
-			return nil
-		}
-	}
+	return s.syncRange(s.indexedChain().Height+1, height, s.setChainHead)
 }
 
 func (s *ChainService) processBlockData(failedBlockHandler core.FailedBlockHandler, blockData *IndexerBlockEventData) error {
@@ -167,38 +280,40 @@ func (s *ChainService) processBlockData(failedBlockHandler core.FailedBlockHandl
 		return err
 	}
 
-	if blockData.IndexBlockEvents && !blockData.BlockEventRequestsFailed {
+	var blockDBWrapper *model.BlockDBWrapper
+	if blockData.IndexBlockEvents && !blockData.BlockEventRequestsFailed && blockData.BlockResultsData != nil {
 		logger.Logger.Info("Parsing block events")
-		blockDBWrapper, err := core.ProcessRPCBlockResults(block, blockData.BlockResultsData, s.CustomBeginBlockEventParserRegistry, s.CustomEndBlockEventParserRegistry)
+		parsedBlockDBWrapper, err := core.ProcessRPCBlockResults(block, blockData.BlockResultsData, s.CustomBeginBlockEventParserRegistry, s.CustomEndBlockEventParserRegistry)
 		if err != nil {
 			logger.Logger.Errorf("Failed to process block events during block %d event processing, adding to failed block events table", block.Height)
+			failedBlockHandler(block.Height, core.FailedBlockEventHandling, err)
 		} else {
 			logger.Logger.Infof("Finished parsing block event data for block %d", block.Height)
 
 			var beginBlockFilterError error
 			var endBlockFilterError error
 			if s.BlockEventFilterRegistries.BeginBlockEventFilterRegistry != nil && s.BlockEventFilterRegistries.BeginBlockEventFilterRegistry.NumFilters() > 0 {
-				blockDBWrapper.BeginBlockEvents, beginBlockFilterError = core.FilterRPCBlockEvents(blockDBWrapper.BeginBlockEvents, *s.BlockEventFilterRegistries.BeginBlockEventFilterRegistry)
+				parsedBlockDBWrapper.BeginBlockEvents, beginBlockFilterError = core.FilterRPCBlockEvents(parsedBlockDBWrapper.BeginBlockEvents, *s.BlockEventFilterRegistries.BeginBlockEventFilterRegistry)
 			}
 
 			if s.BlockEventFilterRegistries.EndBlockEventFilterRegistry != nil && s.BlockEventFilterRegistries.EndBlockEventFilterRegistry.NumFilters() > 0 {
-				blockDBWrapper.EndBlockEvents, endBlockFilterError = core.FilterRPCBlockEvents(blockDBWrapper.EndBlockEvents, *s.BlockEventFilterRegistries.EndBlockEventFilterRegistry)
+				parsedBlockDBWrapper.EndBlockEvents, endBlockFilterError = core.FilterRPCBlockEvents(parsedBlockDBWrapper.EndBlockEvents, *s.BlockEventFilterRegistries.EndBlockEventFilterRegistry)
 			}
 
 			if beginBlockFilterError == nil && endBlockFilterError == nil {
-				//blockEventsDataChan <- &BlockEventsDBData{
-				//	blockDBWrapper: blockDBWrapper,
-				//}
+				blockDBWrapper = parsedBlockDBWrapper
 			} else {
 				logger.Logger.Errorf("Failed to filter block events during block %d event processing, adding to failed block events table. Begin blocker filter error %s. End blocker filter error %s", block.Height, beginBlockFilterError, endBlockFilterError)
-				failedBlockHandler(block.Height, core.FailedBlockEventHandling, err)
+				failedBlockHandler(block.Height, core.FailedBlockEventHandling, fmt.Errorf("begin block filter error: %v, end block filter error: %v", beginBlockFilterError, endBlockFilterError))
 			}
 		}
+	} else if blockData.IndexBlockEvents {
+		failedBlockHandler(block.Height, core.BlockQueryError, fmt.Errorf("block results request failed"))
 	}
 
+	var txDBWrappers []model.TxDBWrapper
 	if blockData.IndexTransactions && !blockData.TxRequestsFailed {
 		logger.Logger.Info("Parsing transactions")
-		var txDBWrappers []model.TxDBWrapper
 		var err error
 
 		if blockData.GetTxsResponse != nil {
@@ -212,13 +327,25 @@ func (s *ChainService) processBlockData(failedBlockHandler core.FailedBlockHandl
 		if err != nil {
 			logger.Logger.Error("ProcessRpcTxs: unhandled error", err)
 			failedBlockHandler(block.Height, core.UnprocessableTxError, err)
-		} else {
-			s.txDataChan <- &DBData{
-				txDBWrappers: txDBWrappers,
-				block:        block,
-			}
+			txDBWrappers = nil
 		}
+	} else if blockData.IndexTransactions {
+		failedBlockHandler(block.Height, core.NodeMissingBlockTxs, fmt.Errorf("tx search and block results requests failed"))
+	}
 
+	// Block events and transactions of one block are committed together in a single batch,
+	// which is sent even when nothing could be indexed so the block hash is still recorded
+	select {
+	case s.txDataChan <- &DBData{
+		txDBWrappers:   txDBWrappers,
+		blockDBWrapper: blockDBWrapper,
+		block:          block,
+		rawBlock:       blockData.RawBlock,
+		backfill:       s.backfilling || blockData.Backfill,
+		failedBlock:    blockData.FailedBlock,
+	}:
+	case <-s.flushStopped:
+		return fmt.Errorf("flush loop stopped, cannot commit block %d", block.Height)
 	}
 
 	return nil
@@ -226,6 +353,7 @@ func (s *ChainService) processBlockData(failedBlockHandler core.FailedBlockHandl
 
 func (s *ChainService) flushData(wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer close(s.flushStopped)
 
 	for {
 		// break out of loop once all channels are fully consumed
@@ -242,8 +370,25 @@ func (s *ChainService) flushData(wg *sync.WaitGroup) {
 				continue
 			}
 
-			err := s.ldb.Transaction(
-				func(ldb *db.LDB, batch *leveldb.Batch) error {
+			// Raw blocks are kept out of the undo log, a rollback must not lose them
+			if data.rawBlock != nil {
+				err := s.store.Transaction(func(view db.View) error {
+					return view.StoreRecord(data.rawBlock)
+				})
+				if err != nil {
+					logger.Logger.Fatalf("Failed to store raw block %d due to error %v", data.block.Height, err)
+				}
+			}
+
+			err := s.store.TransactionWithUndo(data.block.Height, s.undoRetentionDepth(),
+				func(view db.View) error {
+					if data.blockDBWrapper != nil {
+						err := s.indexBlockEvents(view, data.block, data.blockDBWrapper.BeginBlockEvents, s.BlockEventFilterRegistries.BeginBlockEventFilterRegistry)
+						if err != nil {
+							return err
+						}
+					}
+
 					for _, tx := range data.txDBWrappers {
 						for _, message := range tx.Messages {
 							if len(message.MessageParsedDatasets) != 0 {
@@ -254,37 +399,100 @@ func (s *ChainService) flushData(wg *sync.WaitGroup) {
 											attrs := event.Attributes
 											combinedEventsWithAttribues = append(combinedEventsWithAttribues, parsers.MessageEventWithAttributes{Event: event.MessageEvent, Attributes: attrs})
 										}
-										err := (*parsedData.Parser).IndexMessage(ldb, batch, tx.Tx.Hash, parsedData.Data, message.Message, combinedEventsWithAttribues)
+										err := (*parsedData.Parser).IndexMessage(view, tx.Tx.Hash, parsedData.Data, message.Message, combinedEventsWithAttribues)
 										if err != nil {
 											logger.Logger.Error("Error indexing message.", err)
 											return err
 										}
-									} else {
+									} else if parsedData.Error != nil {
 										logger.Logger.Infof("Error inserting message parser error.%v", parsedData)
-										continue
 									}
 								}
 							}
 						}
 					}
 
-					newChain := s.chain.Clone()
+					if data.blockDBWrapper != nil {
+						err := s.indexBlockEvents(view, data.block, data.blockDBWrapper.EndBlockEvents, s.BlockEventFilterRegistries.EndBlockEventFilterRegistry)
+						if err != nil {
+							return err
+						}
+					}
+
+					err := view.StoreRecord(&types.BlockHash{
+						Height:     data.block.Height,
+						Hash:       data.block.Hash,
+						ParentHash: data.block.ParentHash,
+					})
+					if err != nil {
+						return err
+					}
+
+					if data.failedBlock != nil {
+						if len(data.failedBlock.Codes) == 0 {
+							err = view.DeleteRecord(data.failedBlock)
+						} else {
+							err = view.StoreRecord(data.failedBlock)
+						}
+						if err != nil {
+							return err
+						}
+					}
+
+					if data.backfill {
+						return nil
+					}
+
+					newChain := s.indexedChain()
 					newChain.Height = data.block.Height
+					newChain.Hash = data.block.Hash
 
-					err := db.StoreRecord(ldb.DB, batch, newChain)
+					err = view.StoreRecord(newChain)
 					if err != nil {
 						return err
 					}
 					return nil
 				})
+			// Blocks after this one are already on their way, carrying on would leave a gap
 			if err != nil {
-				logger.Logger.Errorf("Failed to flush data due to error %v", err)
-				return
+				logger.Logger.Fatalf("Failed to flush data of block %d due to error %v", data.block.Height, err)
 			}
 
 			logger.Logger.Infof("Finished indexing %v TXs from block %d", len(data.txDBWrappers), data.block.Height)
+			if data.failedBlock != nil && len(data.failedBlock.Codes) == 0 {
+				logger.Logger.Infof("Failed block %d recovered after %d attempts", data.block.Height, data.failedBlock.Attempts)
+			}
+		}
+	}
+}
+
+// indexBlockEvents stores the events that matched the registry filters and hands every
+// successfully parsed event to its parser. Without registered filters nothing is stored
+// verbatim, since that would mean keeping every BeginBlock/EndBlock event of the chain.
+func (s *ChainService) indexBlockEvents(view db.View, block types.Block, blockEvents []model.BlockEventDBWrapper, filterRegistry *filter.StaticBlockEventFilterRegistry) error {
+	persist := filterRegistry != nil && filterRegistry.NumFilters() > 0
+
+	for _, blockEvent := range blockEvents {
+		if persist {
+			err := view.StoreRecord(types.NewBlockEventRecord(block, blockEvent.BlockEvent, blockEvent.Attributes))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, parsedData := range blockEvent.BlockEventParsedDatasets {
+			if parsedData.Error == nil && parsedData.Data != nil && parsedData.Parser != nil {
+				err := (*parsedData.Parser).IndexBlockEvent(view, parsedData.Data, block, blockEvent.BlockEvent, blockEvent.Attributes)
+				if err != nil {
+					logger.Logger.Error("Error indexing block event.", err)
+					return err
+				}
+			} else if parsedData.Error != nil {
+				logger.Logger.Infof("Error inserting block event parser error.%v", parsedData)
+			}
 		}
 	}
+	return nil
 }
 
 func (s *ChainService) RegisterMessageTypeFilter(filter filter.MessageTypeFilter) {
@@ -312,7 +520,7 @@ func (s *ChainService) RegisterCustomBeginBlockEventParser(eventKey string, pars
 
 func (s *ChainService) RegisterCustomEndBlockEventParser(eventKey string, parser parsers.BlockEventParser) {
 	var err error
-	s.CustomBeginBlockEventParserRegistry, err = customBlockEventRegistration(
+	s.CustomEndBlockEventParserRegistry, err = customBlockEventRegistration(
 		s.CustomEndBlockEventParserRegistry,
 		eventKey,
 		parser,
@@ -387,6 +595,19 @@ func (s *ChainService) GetIndexerBlockEventData(height int64) (*IndexerBlockEven
 			currentHeightIndexerData.GetTxsResponse = txsEventResp
 		}
 	}
+
+	if s.storeRawBlocks {
+		if currentHeightIndexerData.BlockResultsData == nil {
+			logger.Logger.Warnf("No block results for block %v, its raw block is not stored and it cannot be re-indexed", height)
+		} else {
+			rawBlock, err := newRawBlock(blockData, currentHeightIndexerData.BlockResultsData)
+			if err != nil {
+				logger.Logger.Errorf("Error encoding raw block %v. Err: %v", height, err)
+			} else {
+				currentHeightIndexerData.RawBlock = rawBlock
+			}
+		}
+	}
 	return currentHeightIndexerData, nil
 }
 
@@ -440,11 +661,11 @@ func customBlockEventRegistration(registry map[string][]parsers.BlockEventParser
 	return registry, nil
 }
 
-type BlockEventsDBData struct {
-	blockDBWrapper *model.BlockDBWrapper
-}
-
 type DBData struct {
-	txDBWrappers []model.TxDBWrapper
-	block        types.Block
+	txDBWrappers   []model.TxDBWrapper
+	blockDBWrapper *model.BlockDBWrapper
+	block          types.Block
+	rawBlock       *types.RawBlock
+	backfill       bool
+	failedBlock    *types.FailedBlock
 }
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/DefiantLabs/probe/client"
+	abci "github.com/cometbft/cometbft/abci/types"
+	rpcclient "github.com/cometbft/cometbft/rpc/client"
+	ctypes "github.com/cometbft/cometbft/rpc/core/types"
+	"mtt-indexer/db"
+	"mtt-indexer/filter"
+	"mtt-indexer/model"
+	"mtt-indexer/parsers"
+	"mtt-indexer/types"
+	"testing"
+	"time"
+)
+
+func newTestChainService(t *testing.T) *ChainService {
+	t.Helper()
+	store := db.NewLdb(db.LdbConfig{Path: t.TempDir()})
+	t.Cleanup(func() { store.Close() })
+	return &ChainService{
+		store: store,
+		chain: &types.Chain{Name: "mtt", ChainID: "mtt_6880-1"},
+	}
+}
+
+func TestBackfillKeepsChainHeight(t *testing.T) {
+	s := newTestChainService(t)
+	s.chain.Height = 10
+	err := s.store.Transaction(func(view db.View) error {
+		return view.StoreRecord(s.chain.Clone())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.backfilling = true
+	var data []*DBData
+	for height := int64(3); height <= 5; height++ {
+		data = append(data, &DBData{block: types.Block{Height: height}, backfill: true})
+	}
+	flush(s, data...)
+
+	if height := s.indexedChain().Height; height != 10 {
+		t.Errorf("got chain at %d after backfilling, want 10", height)
+	}
+	record, err := s.store.GetRecordByType(&types.Chain{Name: "mtt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain, ok := record.(*types.Chain); !ok || chain.Height != 10 {
+		t.Errorf("got stored chain %+v, want height 10", record)
+	}
+}
+
+// statusClient answers Status like a node that keeps the blocks [earliest, latest].
+type statusClient struct {
+	rpcclient.Client
+	earliest, latest int64
+}
+
+func (c *statusClient) Status(context.Context) (*ctypes.ResultStatus, error) {
+	return &ctypes.ResultStatus{SyncInfo: ctypes.SyncInfo{EarliestBlockHeight: c.earliest, LatestBlockHeight: c.latest}}, nil
+}
+
+func TestPrepareStartHeight(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		indexed     int64
+		startHeight int64
+		earliest    int64
+		skipPruned  bool
+		want        int64
+		wantErr     bool
+	}{
+		{name: "full history", earliest: 1, want: 0},
+		{name: "start height", startHeight: 100, earliest: 1, want: 99},
+		{name: "start height on an indexed database", indexed: 50, startHeight: 100, earliest: 1, want: 50},
+		{name: "start height within pruned history", startHeight: 100, earliest: 100, want: 99},
+		{name: "pruned history", indexed: 50, earliest: 80, wantErr: true},
+		{name: "skip pruned history", indexed: 50, earliest: 80, skipPruned: true, want: 79},
+	} {
+		s := &ChainService{
+			chain: &types.Chain{Name: "mtt", Height: test.indexed},
+			cl: &client.ChainClient{
+				Config:    &client.ChainClientConfig{Timeout: "1s"},
+				RPCClient: &statusClient{earliest: test.earliest, latest: 1000},
+			},
+		}
+		err := s.PrepareStartHeight(test.startHeight, test.skipPruned)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v", test.name, err)
+			continue
+		}
+		if !test.wantErr && s.chain.Height != test.want {
+			t.Errorf("%s: got height %d, want %d", test.name, s.chain.Height, test.want)
+		}
+	}
+}
+
+// addressParser stores an empty out list for the address carried by a block event.
+type addressParser struct{}
+
+func (p *addressParser) Identifier() string { return "address" }
+
+func (p *addressParser) ParseBlockEvent(event abci.Event) (*any, error) {
+	address := any(parsers.GetBlockEventAttribute(event, "address"))
+	return &address, nil
+}
+
+func (p *addressParser) IndexBlockEvent(view db.View, dataset *any, block types.Block, event types.BlockEvent, attributes []types.BlockEventAttribute) error {
+	return view.StoreRecord(&types.DelegatorOutList{Delegator: (*dataset).(string)})
+}
+
+func blockEvent(position types.BlockLifecyclePosition, index uint64, eventType string, parsed parsers.BlockEventParsedData) model.BlockEventDBWrapper {
+	return model.BlockEventDBWrapper{
+		BlockEvent: types.BlockEvent{Index: index, LifecyclePosition: position, BlockEventType: types.BlockEventType{Type: eventType}},
+		Attributes: []types.BlockEventAttribute{
+			{Value: "mttvalcons1", BlockEventAttributeKey: types.BlockEventAttributeKey{Key: "address"}},
+		},
+		BlockEventParsedDatasets: []parsers.BlockEventParsedData{parsed},
+	}
+}
+
+func TestFlushDataIndexesBlockEvents(t *testing.T) {
+	s := newTestChainService(t)
+	s.BlockEventFilterRegistries.BeginBlockEventFilterRegistry = &filter.StaticBlockEventFilterRegistry{}
+	s.BlockEventFilterRegistries.BeginBlockEventFilterRegistry.RegisterBlockEventFilter(filter.NewDefaultBlockEventTypeFilter("liveness", true))
+
+	var parser parsers.BlockEventParser = &addressParser{}
+	address := any("mttvalcons1")
+	block := types.Block{Height: 7, TimeStamp: time.Unix(7, 0).UTC()}
+	flush(s, &DBData{
+		block: block,
+		blockDBWrapper: &model.BlockDBWrapper{
+			BeginBlockEvents: []model.BlockEventDBWrapper{
+				blockEvent(types.BeginBlockEvent, 0, "liveness", parsers.BlockEventParsedData{Data: &address, Parser: &parser}),
+			},
+			EndBlockEvents: []model.BlockEventDBWrapper{
+				blockEvent(types.EndBlockEvent, 0, "complete_unbonding", parsers.BlockEventParsedData{Error: errors.New("unparsable"), Parser: &parser}),
+			},
+		},
+	})
+
+	record, err := s.store.GetRecordByType(&types.BlockEventRecord{Height: 7, LifecyclePosition: types.BeginBlockEvent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, ok := record.(*types.BlockEventRecord)
+	if !ok || stored.Type != "liveness" || len(stored.Attributes) != 1 || stored.Attributes[0] != (types.BlockEventRecordAttribute{Key: "address", Value: "mttvalcons1"}) {
+		t.Errorf("got begin block event %+v", record)
+	}
+	// Without filters EndBlock events are only handed to their parsers
+	record, err = s.store.GetRecordByType(&types.BlockEventRecord{Height: 7, LifecyclePosition: types.EndBlockEvent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := record.(*types.BlockEventRecord); ok {
+		t.Error("stored an end block event without a filter")
+	}
+
+	record, err = s.store.GetRecordByType(&types.DelegatorOutList{Delegator: "mttvalcons1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := record.(*types.DelegatorOutList); !ok {
+		t.Error("the block event parser did not run")
+	}
+	record, err = s.store.GetRecordByType(&types.Chain{Name: "mtt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain, ok := record.(*types.Chain); !ok || chain.Height != 7 {
+		t.Errorf("got chain %+v, want the block committed", record)
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"mtt-indexer/core"
+	"mtt-indexer/db"
+	"mtt-indexer/logger"
+	"mtt-indexer/rpc"
+	"mtt-indexer/types"
+	"slices"
+	"time"
+)
+
+const FailedBlockRetryInterval = 30 * time.Second
+const FailedBlockRetryMaxWait = time.Hour
+
+// DefaultFailedBlockMaxAttempts is how often a failed block is tried before the retrier
+// leaves it to be requeued, see RequeueFailedBlock.
+const DefaultFailedBlockMaxAttempts = 20
+
+// handleFailedBlock logs the failure and stores it in the failed block queue so the retrier picks it up.
+func (s *ChainService) handleFailedBlock(height int64, code core.BlockProcessingFailure, err error) {
+	core.HandleFailedBlock(height, code, err)
+
+	failedBlock := &types.FailedBlock{Height: height}
+	record, getErr := s.store.GetRecordByType(failedBlock)
+	if getErr != nil {
+		logger.Logger.Errorf("Failed to load failed block %d: %v", height, getErr)
+		return
+	}
+	if stored, ok := record.(*types.FailedBlock); ok {
+		failedBlock = stored
+	}
+
+	failedBlock.Attempts++
+	addFailure(failedBlock, code, err)
+
+	storeErr := s.store.Transaction(func(view db.View) error {
+		return view.StoreRecord(failedBlock)
+	})
+	if storeErr != nil {
+		logger.Logger.Errorf("Failed to store failed block %d: %v", height, storeErr)
+	}
+}
+
+// addFailure records a failure of the block in its queue entry.
+func addFailure(failedBlock *types.FailedBlock, code core.BlockProcessingFailure, err error) {
+	if !slices.Contains(failedBlock.Codes, int(code)) {
+		failedBlock.Codes = append(failedBlock.Codes, int(code))
+	}
+	failedBlock.Code = int(code)
+	failedBlock.FailedAt = time.Now()
+	failedBlock.NextRetry = failedBlock.FailedAt.Add(failedBlockBackoff(failedBlock.Attempts))
+	failedBlock.Error = code.String()
+	if err != nil {
+		failedBlock.Error = err.Error()
+	}
+}
+
+// failureScope returns the parts of a block to index again for its failures.
+func failureScope(codes []int) (blockEvents, transactions bool) {
+	for _, code := range codes {
+		switch core.BlockProcessingFailure(code) {
+		case core.FailedBlockEventHandling, core.BlockQueryError:
+			blockEvents = true
+		case core.RolledBackBlock:
+			blockEvents = true
+			transactions = true
+		default:
+			transactions = true
+		}
+	}
+	return blockEvents, transactions
+}
+
+func failedBlockBackoff(attempts int) time.Duration {
+	backoff, _ := rpc.GetBackoffDurationForAttempts(int64(attempts), FailedBlockRetryMaxWait)
+	return backoff
+}
+
+func (s *ChainService) retryFailedBlocksLoop() {
+	ticker := time.NewTicker(FailedBlockRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.retryFailedBlocks(); err != nil {
+				logger.Logger.Errorf("retryFailedBlocks error %v", err)
+			}
+		}
+	}
+}
+
+func (s *ChainService) retryFailedBlocks() error {
+	records, err := s.store.GetAllRecordsWithPrefix(&types.FailedBlock{})
+	if err != nil {
+		return err
+	}
+
+	maxAttempts := s.failedBlockMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultFailedBlockMaxAttempts
+	}
+	height := s.indexedChain().Height
+	now := time.Now()
+	for _, record := range records {
+		failedBlock, ok := record.(*types.FailedBlock)
+		if !ok || failedBlock.Attempts >= maxAttempts || failedBlock.NextRetry.After(now) {
+			continue
+		}
+		if failedBlock.Height > height {
+			// The sync loop has not reached this height again yet.
+			continue
+		}
+		s.retryFailedBlock(failedBlock)
+	}
+	return nil
+}
+
+// retryFailedBlock re-runs only the parts of the block that failed, or all of a block a
+// rollback unwound. The stored chain height is not touched. The queue entry is updated in
+// the same commit as the block, and removed when the block processes without a new failure.
+func (s *ChainService) retryFailedBlock(failedBlock *types.FailedBlock) {
+	logger.Logger.Infof("Retrying failed block %d (attempt %d)", failedBlock.Height, failedBlock.Attempts+1)
+
+	code := core.BlockProcessingFailure(failedBlock.Code)
+	data, err := s.GetIndexerBlockEventData(failedBlock.Height)
+	if err != nil {
+		s.handleFailedBlock(failedBlock.Height, code, err)
+		return
+	}
+
+	data.IndexBlockEvents, data.IndexTransactions = failureScope(failedBlock.Codes)
+	data.Backfill = true
+	data.FailedBlock = &types.FailedBlock{
+		Height:   failedBlock.Height,
+		Attempts: failedBlock.Attempts + 1,
+	}
+
+	err = s.processBlockData(func(height int64, code core.BlockProcessingFailure, err error) {
+		core.HandleFailedBlock(height, code, err)
+		addFailure(data.FailedBlock, code, err)
+	}, data)
+	if err != nil {
+		s.handleFailedBlock(failedBlock.Height, code, err)
+	}
+}
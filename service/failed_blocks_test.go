@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mtt-indexer/core"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flush commits data the way the flush loop does for processed blocks.
+func flush(s *ChainService, data ...*DBData) {
+	s.txDataChan = make(chan *DBData, len(data))
+	s.flushStopped = make(chan struct{})
+	for _, d := range data {
+		s.txDataChan <- d
+	}
+	close(s.txDataChan)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	s.flushData(wg)
+}
+
+func failedBlocks(t *testing.T, s *ChainService) []*types.FailedBlock {
+	t.Helper()
+	records, err := s.store.GetAllRecordsWithPrefix(&types.FailedBlock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed []*types.FailedBlock
+	for _, record := range records {
+		failed = append(failed, record.(*types.FailedBlock))
+	}
+	return failed
+}
+
+func storedFailedBlock(t *testing.T, s *ChainService, height int64) *types.FailedBlock {
+	t.Helper()
+	record, err := s.store.GetRecordByType(&types.FailedBlock{Height: height})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failedBlock, _ := record.(*types.FailedBlock)
+	return failedBlock
+}
+
+func TestHandleFailedBlockKeysByHeight(t *testing.T) {
+	s := newTestChainService(t)
+
+	s.handleFailedBlock(5, core.FailedBlockEventHandling, errors.New("events"))
+	s.handleFailedBlock(5, core.NodeMissingBlockTxs, nil)
+
+	failed := failedBlocks(t, s)
+	if len(failed) != 1 {
+		t.Fatalf("got %d failed blocks for one height", len(failed))
+	}
+	failedBlock := failed[0]
+	if failedBlock.Attempts != 2 || failedBlock.Code != int(core.NodeMissingBlockTxs) || failedBlock.Error != core.NodeMissingBlockTxs.String() {
+		t.Errorf("got failed block %+v", failedBlock)
+	}
+	if events, txs := failureScope(failedBlock.Codes); !events || !txs {
+		t.Errorf("got scope events %v, transactions %v for %v, want both", events, txs, failedBlock.Codes)
+	}
+}
+
+func TestFailureScope(t *testing.T) {
+	for _, test := range []struct {
+		codes       []int
+		events, txs bool
+	}{
+		{[]int{int(core.FailedBlockEventHandling)}, true, false},
+		{[]int{int(core.BlockQueryError)}, true, false},
+		{[]int{int(core.NodeMissingBlockTxs)}, false, true},
+		{[]int{int(core.RolledBackBlock)}, true, true},
+	} {
+		if events, txs := failureScope(test.codes); events != test.events || txs != test.txs {
+			t.Errorf("got scope events %v, transactions %v for %v", events, txs, test.codes)
+		}
+	}
+}
+
+func TestFailedBlocksIterateInHeightOrder(t *testing.T) {
+	s := newTestChainService(t)
+	for _, height := range []int64{100, 9, 1000, 10} {
+		s.handleFailedBlock(height, core.NodeMissingBlockTxs, nil)
+	}
+
+	var heights []int64
+	for _, failedBlock := range failedBlocks(t, s) {
+		heights = append(heights, failedBlock.Height)
+	}
+	if fmt.Sprint(heights) != "[9 10 100 1000]" {
+		t.Errorf("got failed blocks %v, want them in height order", heights)
+	}
+}
+
+func TestRetrierStopsAtMaxAttempts(t *testing.T) {
+	s := newTestChainService(t)
+	s.chain.Height = 10
+	s.failedBlockMaxAttempts = 3
+
+	// Unprocessable transactions stay on the backoff like every other failure
+	s.handleFailedBlock(5, core.UnprocessableTxError, errors.New("bad tx"))
+	failedBlock := storedFailedBlock(t, s, 5)
+	if failedBlock == nil || !failedBlock.NextRetry.After(failedBlock.FailedAt) {
+		t.Fatalf("got failed block %+v, want it scheduled for a retry", failedBlock)
+	}
+
+	// Retrying would query the node, which the test service does not have
+	failedBlock.Attempts = 3
+	failedBlock.NextRetry = time.Time{}
+	err := s.store.Transaction(func(view db.View) error {
+		return view.StoreRecord(failedBlock)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.retryFailedBlocks(); err != nil {
+		t.Fatal(err)
+	}
+
+	requeued, err := NewService(s.store).RequeueFailedBlock(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requeued == nil || requeued.Attempts != 0 || requeued.Code != int(core.UnprocessableTxError) {
+		t.Errorf("got requeued block %+v", requeued)
+	}
+	if failedBlock := storedFailedBlock(t, s, 5); failedBlock == nil || failedBlock.Attempts != 0 || failedBlock.NextRetry.After(time.Now()) {
+		t.Errorf("got failed block %+v after requeueing, want it due with no attempts", failedBlock)
+	}
+	if missing, err := NewService(s.store).RequeueFailedBlock(6); missing != nil || err != nil {
+		t.Errorf("got %+v (%v) for a block that is not queued", missing, err)
+	}
+}
+
+func TestRetriedBlockCommitUpdatesQueue(t *testing.T) {
+	s := newTestChainService(t)
+	s.handleFailedBlock(5, core.FailedBlockEventHandling, nil)
+	s.handleFailedBlock(6, core.FailedBlockEventHandling, nil)
+
+	failedAgain := &types.FailedBlock{Height: 6, Attempts: 2}
+	addFailure(failedAgain, core.BlockQueryError, nil)
+	flush(s,
+		&DBData{block: types.Block{Height: 5}, backfill: true, failedBlock: &types.FailedBlock{Height: 5, Attempts: 2}},
+		&DBData{block: types.Block{Height: 6}, backfill: true, failedBlock: failedAgain},
+	)
+
+	if failedBlock := storedFailedBlock(t, s, 5); failedBlock != nil {
+		t.Errorf("got failed block %+v after a successful retry", failedBlock)
+	}
+	failedBlock := storedFailedBlock(t, s, 6)
+	if failedBlock == nil || failedBlock.Attempts != 2 || len(failedBlock.Codes) != 1 || failedBlock.Codes[0] != int(core.BlockQueryError) {
+		t.Errorf("got failed block %+v, want the failure of the retry", failedBlock)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	"mtt-indexer/types"
+	"sort"
+)
+
+// GetProposals returns all indexed proposals, newest first.
+func (s *Service) GetProposals() ([]*types.Proposal, error) {
+	recordsIFace, err := s.store.GetAllRecordsWithPrefix(&types.Proposal{})
+	if err != nil {
+		return nil, err
+	}
+	records := []*types.Proposal{}
+
+	for _, record := range recordsIFace {
+		if proposal, ok := record.(*types.Proposal); ok {
+			records = append(records, proposal)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ProposalID > records[j].ProposalID })
+	return records, nil
+}
+
+// GetProposal returns the proposal, or nil when it is not indexed.
+func (s *Service) GetProposal(proposalID uint64) (*types.Proposal, error) {
+	record, err := s.store.GetRecordByType(&types.Proposal{ProposalID: proposalID})
+	if err != nil {
+		return nil, err
+	}
+	if proposal, ok := record.(*types.Proposal); ok {
+		return proposal, nil
+	}
+	return nil, nil
+}
+
+// GetProposalTimeline returns the timeline of a proposal in chain order.
+func (s *Service) GetProposalTimeline(proposalID uint64, limit, offset int, cursor string) ([]*types.ProposalEvent, string, int, error) {
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.ProposalEvent{ProposalID: proposalID}, limit, offset, cursor, true)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	records := []*types.ProposalEvent{}
+
+	for _, record := range recordsIFace {
+		if event, ok := record.(*types.ProposalEvent); ok {
+			records = append(records, event)
+		}
+	}
+	return records, next, total, nil
+}
+
+// GetProposalVotes returns the votes on a proposal, or only the votes of validators.
+func (s *Service) GetProposalVotes(proposalID uint64, validators bool, limit, offset int, cursor string) ([]*types.ProposalVote, string, int, error) {
+	var record types.DbRecordAutoId = &types.ProposalVote{ProposalID: proposalID}
+	if validators {
+		record = &types.ValidatorVote{ProposalID: proposalID}
+	}
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(record, limit, offset, cursor, false)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	records := []*types.ProposalVote{}
+
+	for _, record := range recordsIFace {
+		switch vote := record.(type) {
+		case *types.ProposalVote:
+			records = append(records, vote)
+		case *types.ValidatorVote:
+			records = append(records, (*types.ProposalVote)(vote))
+		}
+	}
+	return records, next, total, nil
+}
+
+// GetVotes returns the voting history of an account. A validator operator address is
+// taken as the account of the validator.
+func (s *Service) GetVotes(address string, limit, offset int, cursor string) ([]*types.ProposalVote, string, int, error) {
+	if operator, err := stdTypes.ValAddressFromBech32(address); err == nil {
+		address = stdTypes.AccAddress(operator).String()
+	}
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.VoterVote{Voter: address}, limit, offset, cursor, false)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	records := []*types.ProposalVote{}
+
+	for _, record := range recordsIFace {
+		if vote, ok := record.(*types.VoterVote); ok {
+			records = append(records, (*types.ProposalVote)(vote))
+		}
+	}
+	return records, next, total, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	stdTypes "github.com/cosmos/cosmos-sdk/types"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+)
+
+func TestGetProposalKeepsItsOwnTimeline(t *testing.T) {
+	s := newTestChainService(t)
+	err := s.store.Transaction(func(view db.View) error {
+		for _, proposalID := range []uint64{1, 10} {
+			if err := view.StoreRecord(&types.Proposal{ProposalID: proposalID}); err != nil {
+				return err
+			}
+			for _, eventType := range []types.ProposalEventType{types.ProposalEventSubmit, types.ProposalEventVotingStart} {
+				if err := view.StoreRecord(&types.ProposalEvent{ProposalID: proposalID, Type: eventType}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(s.store)
+	proposal, err := service.GetProposal(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, next, total, err := service.GetProposalTimeline(1, 1, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal == nil || total != 2 || len(events) != 1 || events[0].Type != types.ProposalEventSubmit || next == "" {
+		t.Errorf("got proposal %+v with timeline %+v", proposal, events)
+	}
+	events, next, _, err = service.GetProposalTimeline(1, 1, 0, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != types.ProposalEventVotingStart || events[0].ProposalID != 1 || next != "" {
+		t.Errorf("got second timeline page %+v", events)
+	}
+	if proposal, err := service.GetProposal(2); err != nil || proposal != nil {
+		t.Errorf("got proposal %+v (%v) that was never indexed", proposal, err)
+	}
+
+	proposals, err := service.GetProposals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proposals) != 2 || proposals[0].ProposalID != 10 {
+		t.Errorf("got proposals %+v, want newest first", proposals)
+	}
+}
+
+func TestGetVotesOfValidatorOperator(t *testing.T) {
+	s := newTestChainService(t)
+	account := stdTypes.AccAddress([]byte("validator-account-01"))
+	err := s.store.Transaction(func(view db.View) error {
+		vote := &types.ProposalVote{ProposalID: 4, Voter: account.String()}
+		return view.StoreRecord(vote.ToVoter())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(s.store)
+	for _, address := range []string{account.String(), stdTypes.ValAddress(account).String()} {
+		votes, _, total, err := service.GetVotes(address, 10, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(votes) != 1 || votes[0].ProposalID != 4 {
+			t.Errorf("%s: got votes %+v", address, votes)
+		}
+	}
+}
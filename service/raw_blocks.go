@@ -0,0 +1,246 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	tmjson "github.com/cometbft/cometbft/libs/json"
+	ctypes "github.com/cometbft/cometbft/rpc/core/types"
+	"io"
+	"mtt-indexer/core"
+	"mtt-indexer/logger"
+	"mtt-indexer/parsers"
+	"mtt-indexer/rpc"
+	"mtt-indexer/types"
+	"strings"
+	"sync"
+)
+
+// ErrRawBlockMissing is returned when a block to re-index was not stored with store_raw_blocks.
+var ErrRawBlockMissing = errors.New("raw block not stored")
+
+// newRawBlock compresses the RPC responses of a block for storage. results must already be
+// normalized, since stored blocks are not normalized again when loaded.
+func newRawBlock(block *ctypes.ResultBlock, results *rpc.CustomBlockResults) (*types.RawBlock, error) {
+	blockData, err := compressJSON(block)
+	if err != nil {
+		return nil, err
+	}
+	resultsData, err := compressJSON(results)
+	if err != nil {
+		return nil, err
+	}
+	return &types.RawBlock{
+		Height:  block.Block.Height,
+		Block:   blockData,
+		Results: resultsData,
+	}, nil
+}
+
+func compressJSON(v interface{}) ([]byte, error) {
+	data, err := tmjson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressJSON(data []byte, v interface{}) error {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return tmjson.Unmarshal(decompressed, v)
+}
+
+// GetStoredBlockEventData loads a block stored with store_raw_blocks. Its transactions are
+// decoded from the raw tx bytes with ProcessRPCBlockByHeightTXs, as no tx search response
+// is stored.
+func (s *ChainService) GetStoredBlockEventData(height int64) (*IndexerBlockEventData, error) {
+	record, err := s.store.GetRecordByType(&types.RawBlock{Height: height})
+	if err != nil {
+		return nil, err
+	}
+	rawBlock, ok := record.(*types.RawBlock)
+	if !ok {
+		return nil, fmt.Errorf("%w for block %d", ErrRawBlockMissing, height)
+	}
+
+	data := &IndexerBlockEventData{
+		BlockData:         &ctypes.ResultBlock{},
+		BlockResultsData:  &rpc.CustomBlockResults{},
+		IndexBlockEvents:  true,
+		IndexTransactions: true,
+	}
+	if err := decompressJSON(rawBlock.Block, data.BlockData); err != nil {
+		return nil, fmt.Errorf("failed to decode stored block %d: %w", height, err)
+	}
+	if err := decompressJSON(rawBlock.Results, data.BlockResultsData); err != nil {
+		return nil, fmt.Errorf("failed to decode stored block results %d: %w", height, err)
+	}
+	return data, nil
+}
+
+// fetchBlock gets the data of a block from the RPC node, or from the stored raw blocks
+// while re-indexing.
+func (s *ChainService) fetchBlock(height int64) (*IndexerBlockEventData, error) {
+	if s.fromStorage {
+		return s.GetStoredBlockEventData(height)
+	}
+	return s.GetIndexerBlockEventData(height)
+}
+
+// Reindex re-indexes the chain from the first block at or above from in which one of the
+// given parsers has a message or event to parse, using the stored raw blocks instead of the
+// RPC node. Parsers share records (balances, unbonding entries, the order of every
+// history), so the index is rolled back to before that block and every block up to the
+// indexed height is replayed through all registered parsers. The other parsers write the
+// same records again, so only the output of the given parsers changes.
+func (s *ChainService) Reindex(parserIds []string, from int64) error {
+	tip := s.indexedChain().Height
+	if from <= 0 || from > tip {
+		return fmt.Errorf("reindex height %d must be between 1 and the indexed height %d", from, tip)
+	}
+
+	selected, err := s.selectParsers(parserIds)
+	if err != nil {
+		return err
+	}
+	messageParsers := filterParsers(s.CustomMessageParserRegistry, selected)
+	beginBlockParsers := filterParsers(s.CustomBeginBlockEventParserRegistry, selected)
+	endBlockParsers := filterParsers(s.CustomEndBlockEventParserRegistry, selected)
+
+	// Every block that will be replayed must be stored before anything is rolled back
+	start := int64(0)
+	for height := from; height <= tip; height++ {
+		if start != 0 {
+			record, err := s.store.GetRecordByType(&types.RawBlock{Height: height})
+			if err != nil {
+				return err
+			}
+			if _, ok := record.(*types.RawBlock); !ok {
+				return fmt.Errorf("%w for block %d", ErrRawBlockMissing, height)
+			}
+			continue
+		}
+
+		data, err := s.GetStoredBlockEventData(height)
+		if err != nil {
+			return err
+		}
+		parsed, err := s.hasParsedData(data, messageParsers, beginBlockParsers, endBlockParsers)
+		if err != nil {
+			return fmt.Errorf("failed to process stored block %d: %w", height, err)
+		}
+		if parsed {
+			start = height
+		}
+	}
+	if start == 0 {
+		logger.Logger.Infof("No block between %d and %d has anything for parsers %s, nothing to re-index", from, tip, strings.Join(parserIds, ","))
+		return nil
+	}
+
+	logger.Logger.Infof("Re-indexing blocks %d to %d from stored raw blocks", start, tip)
+	if err := s.Rollback(start - 1); err != nil {
+		return err
+	}
+
+	s.fromStorage = true
+	defer func() { s.fromStorage = false }()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go s.flushData(&wg)
+
+	err = s.syncRange(start, tip, s.setChainHead)
+	close(s.txDataChan)
+	wg.Wait()
+	return err
+}
+
+// selectParsers returns the identifiers in ids, failing for any that is not registered.
+func (s *ChainService) selectParsers(ids []string) (map[string]bool, error) {
+	registered := map[string]bool{}
+	for _, registry := range s.CustomMessageParserRegistry {
+		for _, parser := range registry {
+			registered[parser.Identifier()] = true
+		}
+	}
+	for _, registry := range []map[string][]parsers.BlockEventParser{s.CustomBeginBlockEventParserRegistry, s.CustomEndBlockEventParserRegistry} {
+		for _, parsersForEvent := range registry {
+			for _, parser := range parsersForEvent {
+				registered[parser.Identifier()] = true
+			}
+		}
+	}
+
+	selected := map[string]bool{}
+	for _, id := range ids {
+		if !registered[id] {
+			return nil, fmt.Errorf("unknown parser %q, registered parsers are %s", id, strings.Join(sortedKeys(registered), ","))
+		}
+		selected[id] = true
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no parsers given")
+	}
+	return selected, nil
+}
+
+func filterParsers[P interface{ Identifier() string }](registry map[string][]P, selected map[string]bool) map[string][]P {
+	filtered := map[string][]P{}
+	for key, registered := range registry {
+		for _, parser := range registered {
+			if selected[parser.Identifier()] {
+				filtered[key] = append(filtered[key], parser)
+			}
+		}
+	}
+	return filtered
+}
+
+// hasParsedData reports whether the given parsers parse anything in the block.
+func (s *ChainService) hasParsedData(data *IndexerBlockEventData, messageParsers map[string][]parsers.MessageParser, beginBlockParsers, endBlockParsers map[string][]parsers.BlockEventParser) (bool, error) {
+	block, err := core.ProcessBlock(data.BlockData, 1)
+	if err != nil {
+		return false, err
+	}
+
+	blockDBWrapper, err := core.ProcessRPCBlockResults(block, data.BlockResultsData, beginBlockParsers, endBlockParsers)
+	if err != nil {
+		return false, err
+	}
+	for _, blockEvent := range append(blockDBWrapper.BeginBlockEvents, blockDBWrapper.EndBlockEvents...) {
+		if len(blockEvent.BlockEventParsedDatasets) > 0 {
+			return true, nil
+		}
+	}
+
+	txDBWrappers, _, err := core.ProcessRPCBlockByHeightTXs(s.cl, s.MessageTypeFilters, s.MessageFilters, data.BlockData, data.BlockResultsData, messageParsers)
+	if err != nil {
+		return false, err
+	}
+	for _, tx := range txDBWrappers {
+		for _, message := range tx.Messages {
+			if len(message.MessageParsedDatasets) > 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"mtt-indexer/db"
+	"mtt-indexer/parsers"
+	"mtt-indexer/types"
+	"testing"
+)
+
+// addEndBlockEvent stores the raw block at height again with event appended to its EndBlock events.
+func addEndBlockEvent(t *testing.T, s *ChainService, height int64, event abci.Event) {
+	t.Helper()
+	data, err := s.GetStoredBlockEventData(height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.BlockResultsData.EndBlockEvents = append(data.BlockResultsData.EndBlockEvents, event)
+	rawBlock, err := newRawBlock(data.BlockData, data.BlockResultsData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.store.Transaction(func(view db.View) error {
+		return view.StoreRecord(rawBlock)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoredBlockRoundTrip(t *testing.T) {
+	s := newTestChainService(t)
+	storeRawBlocks(t, s, 3, 3, nil)
+
+	data, err := s.GetStoredBlockEventData(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.BlockData.Block.Height != 3 || data.BlockData.BlockID.Hash.String() == "" || data.BlockResultsData.Height != 3 || !data.IndexTransactions || !data.IndexBlockEvents {
+		t.Errorf("got stored block %+v", data)
+	}
+
+	if _, err := s.GetStoredBlockEventData(4); !errors.Is(err, ErrRawBlockMissing) {
+		t.Errorf("got error %v for a block that was not stored", err)
+	}
+}
+
+func TestReindexReplaysFromFirstParsedBlock(t *testing.T) {
+	s := newTestChainService(t)
+	storeRawBlocks(t, s, 1, 10, nil)
+	if err := syncFromStorage(s, 10); err != nil {
+		t.Fatal(err)
+	}
+	s.CustomEndBlockEventParserRegistry = map[string][]parsers.BlockEventParser{"liveness": {&addressParser{}}}
+	addEndBlockEvent(t, s, 6, abci.Event{Type: "liveness", Attributes: []abci.EventAttribute{{Key: "address", Value: testDelegator}}})
+
+	if err := s.Reindex([]string{"unknown"}, 1); err == nil {
+		t.Fatal("re-indexed an unknown parser")
+	}
+
+	s.txDataChan = make(chan *DBData, 10)
+	s.flushStopped = make(chan struct{})
+	if err := s.Reindex([]string{"address"}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := s.store.GetRecordByType(&types.DelegatorOutList{Delegator: testDelegator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := record.(*types.DelegatorOutList); !ok {
+		t.Error("the re-indexed parser did not run")
+	}
+	if height := s.indexedChain().Height; height != 10 {
+		t.Errorf("got chain at %d after re-indexing, want 10", height)
+	}
+
+	// Only the blocks from the first one with something to parse were replayed
+	undoLogs, err := s.store.GetUndoLogs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undoLogs) != 10 {
+		t.Fatalf("got %d undo logs, want 10", len(undoLogs))
+	}
+	for i, undoLog := range undoLogs[:5] {
+		if want := int64(10 - i); undoLog.Height != want {
+			t.Errorf("got undo log of block %d at position %d, want the replayed block %d", undoLog.Height, i, want)
+		}
+	}
+	if undoLogs[5].Height != 5 || undoLogs[5].ID != 5 {
+		t.Errorf("got undo log %+v, want block 5 left as first indexed", undoLogs[5])
+	}
+}
+
+func TestReindexNeedsEveryStoredBlock(t *testing.T) {
+	s := newTestChainService(t)
+	storeRawBlocks(t, s, 1, 5, nil)
+	if err := syncFromStorage(s, 5); err != nil {
+		t.Fatal(err)
+	}
+	s.CustomEndBlockEventParserRegistry = map[string][]parsers.BlockEventParser{"liveness": {&addressParser{}}}
+	addEndBlockEvent(t, s, 2, abci.Event{Type: "liveness"})
+	err := s.store.Transaction(func(view db.View) error {
+		return view.DeleteRecord(&types.RawBlock{Height: 4})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Reindex([]string{"address"}, 1); !errors.Is(err, ErrRawBlockMissing) {
+		t.Fatalf("got error %v, want the missing block reported", err)
+	}
+	// Nothing was rolled back
+	undoLogs, err := s.store.GetUndoLogs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height := s.indexedChain().Height; height != 5 || len(undoLogs) != 5 {
+		t.Errorf("got chain at %d with %d undo logs, want it untouched", height, len(undoLogs))
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"fmt"
+	"mtt-indexer/core"
+	"mtt-indexer/db"
+	"mtt-indexer/logger"
+	"mtt-indexer/types"
+)
+
+// DefaultUndoRetention is how many blocks below the indexed height a rollback can reach
+// when undo_retention is not set.
+const DefaultUndoRetention = 10000
+
+// undoRetentionDepth returns how many blocks of undo logs are kept below the indexed height.
+func (s *ChainService) undoRetentionDepth() int64 {
+	if s.undoRetention <= 0 {
+		return DefaultUndoRetention
+	}
+	return s.undoRetention
+}
+
+// Rollback unwinds every commit made since the database was last at toHeight, newest
+// first, using the undo log written with each commit, and resets the chain height to
+// toHeight. Each commit is unwound in its own transaction together with the chain height,
+// so an interrupted rollback can be re-run.
+// Blocks re-indexed out of order (backfill, failed block retry) are committed after the
+// blocks above them. When such a block is at or below toHeight, unwinding it puts it back
+// into the failed block queue so it is indexed again.
+// Undo logs are only kept for the undo_retention blocks below the indexed height, targets
+// further down are refused.
+func (s *ChainService) Rollback(toHeight int64) error {
+	tip := s.indexedChain().Height
+	if toHeight < 0 || toHeight >= tip {
+		return fmt.Errorf("rollback height %d must be below the indexed height %d", toHeight, tip)
+	}
+	if retention := s.undoRetentionDepth(); toHeight < tip-retention {
+		return fmt.Errorf("rollback height %d is below the retained undo window, undo logs are kept for %d blocks down to height %d", toHeight, retention, tip-retention)
+	}
+
+	undoLogs, err := s.store.GetUndoLogs(toHeight)
+	if err != nil {
+		return err
+	}
+	logged := map[int64]bool{}
+	for _, undoLog := range undoLogs {
+		logged[undoLog.Height] = true
+	}
+	for height := tip; height > toHeight; height-- {
+		if !logged[height] {
+			return fmt.Errorf("block %d has no undo log, it was indexed before undo logs were recorded and cannot be rolled back", height)
+		}
+	}
+
+	for i, undoLog := range undoLogs {
+		// The commits left after this one reach up to the next log's MaxHeight
+		newHeight := toHeight
+		if i+1 < len(undoLogs) {
+			newHeight = undoLogs[i+1].MaxHeight
+		}
+		if newHeight > tip {
+			newHeight = tip
+		}
+
+		hash := ""
+		record, err := s.store.GetRecordByType(&types.BlockHash{Height: newHeight})
+		if err != nil {
+			return err
+		}
+		if blockHash, ok := record.(*types.BlockHash); ok {
+			hash = blockHash.Hash
+		}
+
+		newChain := s.indexedChain()
+		newChain.Height = newHeight
+		newChain.Hash = hash
+
+		err = s.store.Transaction(func(view db.View) error {
+			if err := view.Undo(undoLog); err != nil {
+				return err
+			}
+
+			failedBlocks, err := view.GetAllRecordsWithPrefix(&types.FailedBlock{})
+			if err != nil {
+				return err
+			}
+			queued := false
+			for _, record := range failedBlocks {
+				failedBlock, ok := record.(*types.FailedBlock)
+				if !ok {
+					continue
+				}
+				// Blocks above the chain height are indexed again by the sync loop
+				if failedBlock.Height > newHeight {
+					if err := view.DeleteRecord(failedBlock); err != nil {
+						return err
+					}
+				} else if failedBlock.Height == undoLog.Height {
+					queued = true
+				}
+			}
+			if undoLog.Height <= newHeight && !queued {
+				err := view.StoreRecord(&types.FailedBlock{
+					Height: undoLog.Height,
+					Code:   int(core.RolledBackBlock),
+					Error:  core.RolledBackBlock.String(),
+					Codes:  []int{int(core.RolledBackBlock)},
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return view.StoreRecord(newChain)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to roll back block %d: %w", undoLog.Height, err)
+		}
+
+		s.setChainHead(newChain.Height, newChain.Hash)
+		tip = newChain.Height
+		logger.Logger.Infof("Rolled back block %d", undoLog.Height)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"mtt-indexer/core"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+)
+
+// commitBlock stores the balance of each delegator and a validator record like a block
+// would. Out of order commits (backfill) leave the chain height alone.
+func commitBlock(t *testing.T, s *ChainService, height int64, backfill bool, amounts map[string]string) {
+	t.Helper()
+	err := s.store.TransactionWithUndo(height, s.undoRetentionDepth(), func(view db.View) error {
+		for delegator, amount := range amounts {
+			err := view.StoreRecord(&types.DelegatorOutList{
+				Delegator:  delegator,
+				Validators: []string{testValidator},
+				Amounts:    []string{amount},
+				Denom:      "amtt",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err := view.StoreRecord(&types.ValidatorRecord{Validator: testValidator, Delegator: testDelegator, TxHash: fmt.Sprintf("tx%d", height)})
+		if err != nil {
+			return err
+		}
+		if backfill {
+			return nil
+		}
+		err = view.StoreRecord(&types.BlockHash{Height: height, Hash: fmt.Sprintf("hash%d", height)})
+		if err != nil {
+			return err
+		}
+		s.chain.Height = height
+		s.chain.Hash = fmt.Sprintf("hash%d", height)
+		return view.StoreRecord(s.chain.Clone())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func outListAmount(t *testing.T, s *ChainService, delegator string) string {
+	t.Helper()
+	record, err := s.store.GetRecordByType(&types.DelegatorOutList{Delegator: delegator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outList, ok := record.(*types.DelegatorOutList)
+	if !ok {
+		return ""
+	}
+	return outList.Amounts[0]
+}
+
+func TestRollbackPastRerunBlock(t *testing.T) {
+	s := newTestChainService(t)
+	commitBlock(t, s, 1, false, map[string]string{testDelegator: "100"})
+	commitBlock(t, s, 2, false, map[string]string{testDelegator: "200"})
+	commitBlock(t, s, 3, false, map[string]string{testDelegator: "300", testOtherDelegator: "30"})
+	// Re-running block 2 touches a key block 2 did not write the first time
+	commitBlock(t, s, 2, true, map[string]string{testDelegator: "250", testOtherDelegator: "20"})
+
+	if err := s.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if amount := outListAmount(t, s, testDelegator); amount != "100" {
+		t.Errorf("got amount %q, want 100", amount)
+	}
+	if amount := outListAmount(t, s, testOtherDelegator); amount != "" {
+		t.Errorf("got amount %q for a delegator first seen above the rollback height", amount)
+	}
+	records, total, err := s.store.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: testValidator}, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(records) != 1 || records[0].(*types.ValidatorRecord).TxHash != "tx1" {
+		t.Errorf("got %d validator records, total %d, want the one of block 1", len(records), total)
+	}
+	if s.chain.Height != 1 || s.chain.Hash != "hash1" {
+		t.Errorf("got chain at %d (%s), want 1 (hash1)", s.chain.Height, s.chain.Hash)
+	}
+	if failed := failedBlocks(t, s); len(failed) != 0 {
+		t.Errorf("got failed blocks %+v above the rollback height", failed)
+	}
+
+	// The ids of the unwound records are handed out again
+	commitBlock(t, s, 2, false, map[string]string{testDelegator: "200"})
+	records, _, err = s.store.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: testValidator}, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].(*types.ValidatorRecord).ID != 2 {
+		t.Errorf("got validator records %+v after re-indexing block 2", records)
+	}
+}
+
+func TestRollbackRequeuesRerunBlockBelowHeight(t *testing.T) {
+	s := newTestChainService(t)
+	commitBlock(t, s, 1, false, map[string]string{testDelegator: "100"})
+	commitBlock(t, s, 2, false, map[string]string{testDelegator: "200"})
+	commitBlock(t, s, 3, false, map[string]string{testDelegator: "300"})
+	commitBlock(t, s, 2, true, map[string]string{testDelegator: "250"})
+
+	if err := s.Rollback(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if amount := outListAmount(t, s, testDelegator); amount != "200" {
+		t.Errorf("got amount %q, want 200", amount)
+	}
+	if s.chain.Height != 2 || s.chain.Hash != "hash2" {
+		t.Errorf("got chain at %d (%s), want 2 (hash2)", s.chain.Height, s.chain.Hash)
+	}
+	failed := failedBlocks(t, s)
+	if len(failed) != 1 || failed[0].Height != 2 || failed[0].Code != int(core.RolledBackBlock) {
+		t.Errorf("got failed blocks %+v, want block 2 queued again", failed)
+	}
+}
+
+func TestRollbackWithoutUndoLog(t *testing.T) {
+	s := newTestChainService(t)
+	commitBlock(t, s, 2, false, map[string]string{testDelegator: "200"})
+	s.chain.Height = 3
+
+	if err := s.Rollback(1); err == nil {
+		t.Fatal("rolled back blocks without undo logs")
+	}
+	if amount := outListAmount(t, s, testDelegator); amount != "200" {
+		t.Errorf("got amount %q after a refused rollback, want 200", amount)
+	}
+}
+
+func TestRollbackRestoresRetriedBlock(t *testing.T) {
+	s := newTestChainService(t)
+	commitBlock(t, s, 5, false, map[string]string{testDelegator: "500"})
+	commitBlock(t, s, 6, false, map[string]string{testDelegator: "600"})
+	s.handleFailedBlock(5, core.FailedBlockEventHandling, nil)
+	flush(s, &DBData{block: types.Block{Height: 5}, backfill: true, failedBlock: &types.FailedBlock{Height: 5, Attempts: 2}})
+	commitBlock(t, s, 7, false, map[string]string{testDelegator: "700"})
+
+	if err := s.Rollback(5); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unwinding the retry puts its queue entry back as it was
+	failed := failedBlocks(t, s)
+	if len(failed) != 1 || failed[0].Height != 5 || failed[0].Attempts != 1 || failed[0].Code != int(core.FailedBlockEventHandling) {
+		t.Errorf("got failed blocks %+v, want the entry before the retry", failed)
+	}
+	if s.chain.Height != 5 {
+		t.Errorf("got chain at %d, want 5", s.chain.Height)
+	}
+}
+
+func TestRollbackStaysInRetainedWindow(t *testing.T) {
+	s := newTestChainService(t)
+	s.undoRetention = 2
+	for height := int64(1); height <= 6; height++ {
+		commitBlock(t, s, height, false, map[string]string{testDelegator: fmt.Sprintf("%d00", height)})
+	}
+	// A re-run block's log stays as long as the logs of the blocks committed before it
+	commitBlock(t, s, 5, true, map[string]string{testDelegator: "550"})
+
+	undoLogs, err := s.store.GetUndoLogs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var heights []int64
+	for _, undoLog := range undoLogs {
+		heights = append(heights, undoLog.Height)
+	}
+	if fmt.Sprint(heights) != "[5 6 5]" {
+		t.Errorf("got undo logs of blocks %v, want those above height 4", heights)
+	}
+
+	if err := s.Rollback(3); err == nil {
+		t.Fatal("rolled back below the retained window")
+	}
+	if amount := outListAmount(t, s, testDelegator); amount != "550" {
+		t.Errorf("got amount %q after a refused rollback, want 550", amount)
+	}
+
+	if err := s.Rollback(4); err != nil {
+		t.Fatal(err)
+	}
+	if amount := outListAmount(t, s, testDelegator); amount != "400" {
+		t.Errorf("got amount %q, want 400", amount)
+	}
+}
@@ -1,32 +1,52 @@
 package service
 
 import (
+	"errors"
 	"mtt-indexer/db"
 	"mtt-indexer/types"
+	"time"
 )
 
 type IService interface {
 	GetChainHeight() (int64, error)
 	GetDelegatorList(delegator string) (*types.DelegatorOutList, error)
-	GetDelegatorHistory(delegator string, limit, offset int, asc bool) ([]*types.DelegatorRecord, int, error)
-	GetValidatorHistory(Validator string, limit, offset int, asc bool) ([]*types.ValidatorRecord, int, error)
-	GetCommissionRecord(Validator string, limit, offset int) ([]*types.CommissionRecord, int, error)
-	GetRewardHistory(validator string, limit, offset int) ([]*types.RewardRecord, int, error)
+	GetDelegatorHistory(delegator string, limit, offset int, cursor string, asc bool) ([]*types.DelegatorRecord, string, int, error)
+	GetValidatorHistory(Validator string, limit, offset int, cursor string, asc bool) ([]*types.ValidatorRecord, string, int, error)
+	GetCommissionRecord(Validator string, limit, offset int, cursor string) ([]*types.CommissionRecord, string, int, error)
+	GetRewardHistory(validator string, limit, offset int, cursor string) ([]*types.RewardRecord, string, int, error)
+	GetCommissionWithdrawals(validator string, limit, offset int, cursor string) ([]*types.CommissionWithdrawal, string, int, error)
+	GetTransfers(address string, limit, offset int, cursor string) ([]*types.TransferRecord, string, int, error)
+	GetAuthorizations(granter string, limit, offset int, cursor string) ([]*types.AuthorizationRecord, string, int, error)
+	GetProposals() ([]*types.Proposal, error)
+	GetProposal(proposalID uint64) (*types.Proposal, error)
+	GetProposalTimeline(proposalID uint64, limit, offset int, cursor string) ([]*types.ProposalEvent, string, int, error)
+	GetProposalVotes(proposalID uint64, validators bool, limit, offset int, cursor string) ([]*types.ProposalVote, string, int, error)
+	GetVotes(address string, limit, offset int, cursor string) ([]*types.ProposalVote, string, int, error)
+	GetFailedBlocks() ([]*types.FailedBlock, error)
+	RequeueFailedBlock(height int64) (*types.FailedBlock, error)
+	GetDelegatorUnbondings(delegator string) ([]*types.UnbondingEntry, error)
+	GetValidatorUnbondings(validator string) ([]*types.UnbondingEntry, error)
+	GetValidatorIncidents(validator string, limit, offset int, cursor string) ([]*types.ValidatorIncident, string, int, error)
+	GetValidatorLiveness(validator string) (*types.ValidatorLiveness, error)
+	Backup(path string) (*db.BackupManifest, error)
 }
 
+// ErrBackupUnsupported is returned by Backup for storage backends that have their own tools.
+var ErrBackupUnsupported = errors.New("backup is not supported by this storage backend")
+
 type Service struct {
-	ldb *db.LDB
+	store db.Store
 }
 
-func NewService(db *db.LDB) *Service {
-	return &Service{ldb: db}
+func NewService(store db.Store) *Service {
+	return &Service{store: store}
 }
 
 func (s *Service) GetChainHeight() (int64, error) {
 	chain := &types.Chain{
 		Name: "mtt",
 	}
-	record, err := s.ldb.GetRecordByType(chain)
+	record, err := s.store.GetRecordByType(chain)
 	if err != nil {
 		return 0, err
 	}
@@ -42,7 +62,7 @@ func (s *Service) GetDelegatorList(delegator string) (*types.DelegatorOutList, e
 	outList := &types.DelegatorOutList{
 		Delegator: delegator,
 	}
-	record, err := s.ldb.GetRecordByType(outList)
+	record, err := s.store.GetRecordByType(outList)
 	if err != nil {
 		return nil, err
 	}
@@ -54,11 +74,11 @@ func (s *Service) GetDelegatorList(delegator string) (*types.DelegatorOutList, e
 	return outList, nil
 }
 
-func (s *Service) GetDelegatorHistory(delegator string, limit, offset int, asc bool) ([]*types.DelegatorRecord, int, error) {
-	recordsIFace, total, err := s.ldb.GetAllRecordsWithAutoId(&types.DelegatorRecord{Delegator: delegator}, limit, offset, asc)
+func (s *Service) GetDelegatorHistory(delegator string, limit, offset int, cursor string, asc bool) ([]*types.DelegatorRecord, string, int, error) {
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.DelegatorRecord{Delegator: delegator}, limit, offset, cursor, asc)
 	records := []*types.DelegatorRecord{}
 	if err != nil {
-		return nil, total, err
+		return nil, "", total, err
 	} else {
 		for _, record := range recordsIFace {
 			if delegatorRecord, ok := record.(*types.DelegatorRecord); ok {
@@ -66,14 +86,14 @@ func (s *Service) GetDelegatorHistory(delegator string, limit, offset int, asc b
 			}
 		}
 	}
-	return records, total, nil
+	return records, next, total, nil
 }
 
-func (s *Service) GetValidatorHistory(Validator string, limit, offset int, asc bool) ([]*types.ValidatorRecord, int, error) {
-	recordsIFace, total, err := s.ldb.GetAllRecordsWithAutoId(&types.ValidatorRecord{Validator: Validator}, limit, offset, asc)
+func (s *Service) GetValidatorHistory(Validator string, limit, offset int, cursor string, asc bool) ([]*types.ValidatorRecord, string, int, error) {
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.ValidatorRecord{Validator: Validator}, limit, offset, cursor, asc)
 	records := []*types.ValidatorRecord{}
 	if err != nil {
-		return nil, total, err
+		return nil, "", total, err
 	} else {
 		for _, record := range recordsIFace {
 			if validatorRecord, ok := record.(*types.ValidatorRecord); ok {
@@ -81,14 +101,14 @@ func (s *Service) GetValidatorHistory(Validator string, limit, offset int, asc b
 			}
 		}
 	}
-	return records, total, nil
+	return records, next, total, nil
 }
 
-func (s *Service) GetCommissionRecord(Validator string, limit, offset int) ([]*types.CommissionRecord, int, error) {
-	recordsIFace, total, err := s.ldb.GetAllRecordsWithAutoId(&types.CommissionRecord{Validator: Validator}, limit, offset, false)
+func (s *Service) GetCommissionRecord(Validator string, limit, offset int, cursor string) ([]*types.CommissionRecord, string, int, error) {
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.CommissionRecord{Validator: Validator}, limit, offset, cursor, false)
 	records := []*types.CommissionRecord{}
 	if err != nil {
-		return nil, total, err
+		return nil, "", total, err
 	} else {
 		for _, record := range recordsIFace {
 			if commissionRecord, ok := record.(*types.CommissionRecord); ok {
@@ -96,13 +116,13 @@ func (s *Service) GetCommissionRecord(Validator string, limit, offset int) ([]*t
 			}
 		}
 	}
-	return records, total, nil
+	return records, next, total, nil
 }
 
-func (s *Service) GetRewardHistory(validator string, limit, offset int) ([]*types.RewardRecord, int, error) {
-	recordsIFace, total, err := s.ldb.GetAllRecordsWithAutoId(&types.RewardRecord{Validator: validator}, limit, offset, false)
+func (s *Service) GetRewardHistory(validator string, limit, offset int, cursor string) ([]*types.RewardRecord, string, int, error) {
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.RewardRecord{Validator: validator}, limit, offset, cursor, false)
 	if err != nil {
-		return nil, 0, err
+		return nil, "", 0, err
 	}
 	records := []*types.RewardRecord{}
 
@@ -111,5 +131,158 @@ func (s *Service) GetRewardHistory(validator string, limit, offset int) ([]*type
 			records = append(records, validatorRecord)
 		}
 	}
-	return records, total, nil
+	return records, next, total, nil
+}
+
+func (s *Service) GetCommissionWithdrawals(validator string, limit, offset int, cursor string) ([]*types.CommissionWithdrawal, string, int, error) {
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.CommissionWithdrawal{Validator: validator}, limit, offset, cursor, false)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	records := []*types.CommissionWithdrawal{}
+
+	for _, record := range recordsIFace {
+		if withdrawal, ok := record.(*types.CommissionWithdrawal); ok {
+			records = append(records, withdrawal)
+		}
+	}
+	return records, next, total, nil
+}
+
+func (s *Service) GetTransfers(address string, limit, offset int, cursor string) ([]*types.TransferRecord, string, int, error) {
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.TransferRecord{Address: address}, limit, offset, cursor, false)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	records := []*types.TransferRecord{}
+
+	for _, record := range recordsIFace {
+		if transfer, ok := record.(*types.TransferRecord); ok {
+			records = append(records, transfer)
+		}
+	}
+	return records, next, total, nil
+}
+
+func (s *Service) GetAuthorizations(granter string, limit, offset int, cursor string) ([]*types.AuthorizationRecord, string, int, error) {
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.AuthorizationRecord{Granter: granter}, limit, offset, cursor, false)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	records := []*types.AuthorizationRecord{}
+
+	for _, record := range recordsIFace {
+		if authorization, ok := record.(*types.AuthorizationRecord); ok {
+			records = append(records, authorization)
+		}
+	}
+	return records, next, total, nil
+}
+
+func (s *Service) GetFailedBlocks() ([]*types.FailedBlock, error) {
+	recordsIFace, err := s.store.GetAllRecordsWithPrefix(&types.FailedBlock{})
+	if err != nil {
+		return nil, err
+	}
+	records := []*types.FailedBlock{}
+
+	for _, record := range recordsIFace {
+		if failedBlock, ok := record.(*types.FailedBlock); ok {
+			records = append(records, failedBlock)
+		}
+	}
+	return records, nil
+}
+
+// RequeueFailedBlock resets the attempts of a queued block, so the retrier tries it on its
+// next round again, also after it reached the max attempts. It returns nil when the block
+// is not queued.
+func (s *Service) RequeueFailedBlock(height int64) (*types.FailedBlock, error) {
+	var failedBlock *types.FailedBlock
+	err := s.store.Transaction(func(view db.View) error {
+		record, err := view.GetRecordByType(&types.FailedBlock{Height: height})
+		if err != nil {
+			return err
+		}
+		stored, ok := record.(*types.FailedBlock)
+		if !ok {
+			return nil
+		}
+		stored.Attempts = 0
+		stored.NextRetry = time.Now()
+		failedBlock = stored
+		return view.StoreRecord(stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return failedBlock, nil
+}
+
+func (s *Service) GetDelegatorUnbondings(delegator string) ([]*types.UnbondingEntry, error) {
+	recordsIFace, err := s.store.GetAllRecordsWithPrefix(&types.UnbondingEntry{Delegator: delegator})
+	if err != nil {
+		return nil, err
+	}
+	records := []*types.UnbondingEntry{}
+
+	for _, record := range recordsIFace {
+		if entry, ok := record.(*types.UnbondingEntry); ok {
+			records = append(records, entry)
+		}
+	}
+	return records, nil
+}
+
+func (s *Service) GetValidatorUnbondings(validator string) ([]*types.UnbondingEntry, error) {
+	recordsIFace, err := s.store.GetAllRecordsWithPrefix(&types.ValidatorUnbondingEntry{Validator: validator})
+	if err != nil {
+		return nil, err
+	}
+	records := []*types.UnbondingEntry{}
+
+	for _, record := range recordsIFace {
+		if entry, ok := record.(*types.ValidatorUnbondingEntry); ok {
+			records = append(records, entry.ToDelegator())
+		}
+	}
+	return records, nil
+}
+
+func (s *Service) GetValidatorIncidents(validator string, limit, offset int, cursor string) ([]*types.ValidatorIncident, string, int, error) {
+	recordsIFace, next, total, err := s.store.GetRecordsWithAutoIdCursor(&types.ValidatorIncident{Validator: validator}, limit, offset, cursor, false)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	records := []*types.ValidatorIncident{}
+
+	for _, record := range recordsIFace {
+		if incident, ok := record.(*types.ValidatorIncident); ok {
+			records = append(records, incident)
+		}
+	}
+	return records, next, total, nil
+}
+
+func (s *Service) GetValidatorLiveness(validator string) (*types.ValidatorLiveness, error) {
+	liveness := &types.ValidatorLiveness{
+		Validator: validator,
+	}
+	record, err := s.store.GetRecordByType(liveness)
+	if err != nil {
+		return nil, err
+	}
+	if storedLiveness, ok := record.(*types.ValidatorLiveness); ok {
+		liveness = storedLiveness
+	}
+	return liveness, nil
+}
+
+// Backup writes a backup archive of the store to path while indexing carries on.
+func (s *Service) Backup(path string) (*db.BackupManifest, error) {
+	backuper, ok := s.store.(db.Backuper)
+	if !ok {
+		return nil, ErrBackupUnsupported
+	}
+	return db.BackupToFile(backuper, path, "mtt")
 }
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+)
+
+const (
+	testDelegator      = "mtt12x07g3270742n42heupleuwvjuzn5j6x4dmysj"
+	testOtherDelegator = "mtt10wpwl4mqpgdgz8597kphgahx3a8degvg58kjx5"
+	testValidator      = "mttvaloper12x07g3270742n42heupleuwvjuzn5j6x2ekcn0"
+)
+
+func TestGetUnbondingsOfDelegatorAndValidator(t *testing.T) {
+	s := newTestChainService(t)
+	err := s.store.Transaction(func(view db.View) error {
+		for _, entry := range []*types.UnbondingEntry{
+			{Delegator: testDelegator, Validator: testValidator, CreationHeight: 3, Balance: "10"},
+			{Delegator: testOtherDelegator, Validator: testValidator, CreationHeight: 4, Balance: "20"},
+		} {
+			if err := view.StoreRecord(entry); err != nil {
+				return err
+			}
+			if err := view.StoreRecord(entry.ToValidator()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(s.store)
+	entries, err := service.GetDelegatorUnbondings(testDelegator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Validator != testValidator || entries[0].Balance != "10" {
+		t.Errorf("got delegator unbondings %+v", entries)
+	}
+
+	entries, err = service.GetValidatorUnbondings(testValidator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d validator unbondings, want 2", len(entries))
+	}
+	delegators := map[string]string{}
+	for _, entry := range entries {
+		delegators[entry.Delegator] = entry.Balance
+	}
+	if delegators[testDelegator] != "10" || delegators[testOtherDelegator] != "20" {
+		t.Errorf("got validator unbondings %+v", entries)
+	}
+}
+
+func TestGetValidatorIncidentsPagesByCursor(t *testing.T) {
+	s := newTestChainService(t)
+	err := s.store.Transaction(func(view db.View) error {
+		for height := int64(1); height <= 5; height++ {
+			if err := view.StoreRecord(&types.ValidatorIncident{Validator: testValidator, Height: height}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(s.store)
+	var heights []int64
+	cursor := ""
+	for {
+		records, next, total, err := service.GetValidatorIncidents(testValidator, 2, 0, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 5 {
+			t.Errorf("got total %d, want 5", total)
+		}
+		for _, record := range records {
+			heights = append(heights, record.Height)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if fmt.Sprint(heights) != "[5 4 3 2 1]" {
+		t.Errorf("got incidents at %v, want newest first", heights)
+	}
+
+	if _, _, _, err := service.GetValidatorIncidents(testValidator, 2, 0, "not a cursor"); !errors.Is(err, db.ErrInvalidCursor) {
+		t.Errorf("got error %v for a broken cursor", err)
+	}
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	sdkmath "cosmossdk.io/math"
+	"fmt"
+	"mtt-indexer/db"
+	"mtt-indexer/logger"
+	"mtt-indexer/rpc"
+	"mtt-indexer/types"
+	"sort"
+)
+
+// VerifyMismatch is a value that differs between the stored state and the replayed history,
+// or between the replayed history and the chain.
+type VerifyMismatch struct {
+	// Record is the key of the checked record, or the delegator address for chain checks
+	Record    string
+	Validator string
+	// Actual is the stored value, or the replayed stake for chain checks
+	Actual string
+	// Expected is the replayed value, or the balance on chain for chain checks
+	Expected string
+}
+
+type VerifyReport struct {
+	Height     int64
+	Delegators int
+	Validators int
+	// Mismatches are DelegatorOutList, Claimed24H and CommissionWithdrawn values that differ
+	// from the history
+	Mismatches []VerifyMismatch
+	// ChainMismatches are replayed stakes that differ from the Delegation on chain
+	ChainMismatches []VerifyMismatch
+	// Unresolved are history records that could not be replayed
+	Unresolved []string
+	// Fixed is the number of records rewritten
+	Fixed int
+}
+
+// replayedState is the derived state recomputed from the ValidatorRecord and
+// CommissionWithdrawal history. Stake is what is delegated on chain. DelegatorOutList also
+// takes claimed rewards off the stake, so it is kept separately.
+type replayedState struct {
+	stake     map[string]map[string]sdkmath.Int
+	outList   map[string]map[string]sdkmath.Int
+	claimed   map[string]sdkmath.Int
+	withdrawn map[string]sdkmath.Int
+	denoms    map[string]string
+}
+
+type redelegationKey struct {
+	delegator string
+	txHash    string
+	validator string
+	amount    string
+}
+
+// Verify replays the delegation history and compares the result with the stored
+// DelegatorOutList, Claimed24H and CommissionWithdrawn records, rewriting the ones that
+// differ when fix is set.
+// With onChain the replayed stakes are also compared with the delegations on chain at the
+// indexed height, which only match for delegations that were never slashed. The indexer
+// must not be running.
+func (s *ChainService) Verify(fix, onChain bool) (*VerifyReport, error) {
+	state, report, err := s.replayHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	var fixes []types.DbRecord
+	outListFixes, err := s.verifyOutLists(state, report)
+	if err != nil {
+		return nil, err
+	}
+	fixes = append(fixes, outListFixes...)
+	claimedFixes, err := s.verifyClaimed(state, report)
+	if err != nil {
+		return nil, err
+	}
+	fixes = append(fixes, claimedFixes...)
+	withdrawnFixes, err := s.verifyWithdrawn(state, report)
+	if err != nil {
+		return nil, err
+	}
+	fixes = append(fixes, withdrawnFixes...)
+
+	if fix && len(fixes) > 0 {
+		err := s.store.Transaction(func(view db.View) error {
+			for _, record := range fixes {
+				if err := view.StoreRecord(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to store corrected records: %w", err)
+		}
+		report.Fixed = len(fixes)
+	}
+
+	if onChain {
+		if err := s.verifyOnChain(state, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// replayHistory sums every ValidatorRecord. The two records of a redelegation share the
+// type and tx hash, so which one left the source validator is taken from the delegator
+// history, where the source record is always stored first.
+func (s *ChainService) replayHistory() (*replayedState, *VerifyReport, error) {
+	redelegations := map[string][]*types.DelegatorRecord{}
+	err := s.store.ForEachRecord(&types.DelegatorRecord{}, func(record interface{}) error {
+		if delegatorRecord, ok := record.(*types.DelegatorRecord); ok && delegatorRecord.DelegationType == types.Redelegate {
+			redelegations[delegatorRecord.Delegator] = append(redelegations[delegatorRecord.Delegator], delegatorRecord)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fromSource := map[redelegationKey][]bool{}
+	for _, records := range redelegations {
+		sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+		seen := map[string]int{}
+		for _, record := range records {
+			key := redelegationKey{record.Delegator, record.TxHash, record.Validator, record.Amount}
+			fromSource[key] = append(fromSource[key], seen[record.TxHash]%2 == 0)
+			seen[record.TxHash]++
+		}
+	}
+
+	state := &replayedState{
+		stake:     map[string]map[string]sdkmath.Int{},
+		outList:   map[string]map[string]sdkmath.Int{},
+		claimed:   map[string]sdkmath.Int{},
+		withdrawn: map[string]sdkmath.Int{},
+		denoms:    map[string]string{},
+	}
+	report := &VerifyReport{Height: s.chain.Height}
+	err = s.store.ForEachRecord(&types.ValidatorRecord{}, func(record interface{}) error {
+		validatorRecord, ok := record.(*types.ValidatorRecord)
+		if !ok {
+			return nil
+		}
+		amount, ok := sdkmath.NewIntFromString(validatorRecord.Amount)
+		if !ok {
+			amount = sdkmath.ZeroInt()
+		}
+		if _, ok := state.denoms[validatorRecord.Delegator]; !ok {
+			state.denoms[validatorRecord.Delegator] = validatorRecord.Denom
+		}
+
+		switch validatorRecord.DelegationType {
+		case types.Delegate, types.CancelUnbonding:
+			state.add(validatorRecord.Delegator, validatorRecord.Validator, amount, amount)
+		case types.Undelegate:
+			state.add(validatorRecord.Delegator, validatorRecord.Validator, amount.Neg(), amount.Neg())
+		case types.Claim:
+			state.add(validatorRecord.Delegator, validatorRecord.Validator, sdkmath.ZeroInt(), amount.Neg())
+			addTotal(state.claimed, validatorRecord.Validator, amount)
+		case types.Redelegate:
+			key := redelegationKey{validatorRecord.Delegator, validatorRecord.TxHash, validatorRecord.Validator, validatorRecord.Amount}
+			directions := fromSource[key]
+			if len(directions) == 0 {
+				report.Unresolved = append(report.Unresolved, fmt.Sprintf("%s: redelegation in tx %s has no matching DelegatorRecord", validatorRecord.Key(), validatorRecord.TxHash))
+				return nil
+			}
+			fromSource[key] = directions[1:]
+			if directions[0] {
+				amount = amount.Neg()
+			}
+			state.add(validatorRecord.Delegator, validatorRecord.Validator, amount, amount)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.store.ForEachRecord(&types.CommissionWithdrawal{}, func(record interface{}) error {
+		if withdrawal, ok := record.(*types.CommissionWithdrawal); ok {
+			addTotal(state.withdrawn, withdrawal.Validator, withdrawal.AmountOf(types.StakeDenom))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return state, report, nil
+}
+
+func (r *replayedState) add(delegator, validator string, stake, outList sdkmath.Int) {
+	addAmount(r.stake, delegator, validator, stake)
+	addAmount(r.outList, delegator, validator, outList)
+}
+
+func addTotal(totals map[string]sdkmath.Int, validator string, amount sdkmath.Int) {
+	total, ok := totals[validator]
+	if !ok {
+		total = sdkmath.ZeroInt()
+	}
+	totals[validator] = total.Add(amount)
+}
+
+func addAmount(amounts map[string]map[string]sdkmath.Int, delegator, validator string, delta sdkmath.Int) {
+	if amounts[delegator] == nil {
+		amounts[delegator] = map[string]sdkmath.Int{}
+	}
+	amount, ok := amounts[delegator][validator]
+	if !ok {
+		amount = sdkmath.ZeroInt()
+	}
+	amounts[delegator][validator] = amount.Add(delta)
+}
+
+// verifyOutLists returns the corrected DelegatorOutList of every delegator that has a mismatch.
+func (s *ChainService) verifyOutLists(state *replayedState, report *VerifyReport) ([]types.DbRecord, error) {
+	var fixes []types.DbRecord
+	checked := map[string]bool{}
+	check := func(outList *types.DelegatorOutList) {
+		checked[outList.Delegator] = true
+		expected := state.outList[outList.Delegator]
+		corrected := &types.DelegatorOutList{
+			Delegator: outList.Delegator,
+			Denom:     outList.Denom,
+		}
+		if corrected.Denom == "" {
+			corrected.Denom = state.denoms[outList.Delegator]
+		}
+
+		mismatch := false
+		stored := map[string]bool{}
+		for i, validator := range outList.Validators {
+			stored[validator] = true
+			actual := ""
+			if i < len(outList.Amounts) {
+				actual = outList.Amounts[i]
+			}
+			want := amountOrZero(expected, validator)
+			if !sameAmount(actual, want) {
+				mismatch = true
+				report.Mismatches = append(report.Mismatches, VerifyMismatch{Record: outList.Key(), Validator: validator, Actual: actual, Expected: want.String()})
+			}
+			corrected.Validators = append(corrected.Validators, validator)
+			corrected.Amounts = append(corrected.Amounts, want.String())
+		}
+		for _, validator := range sortedKeys(expected) {
+			if stored[validator] || expected[validator].IsZero() {
+				continue
+			}
+			mismatch = true
+			report.Mismatches = append(report.Mismatches, VerifyMismatch{Record: outList.Key(), Validator: validator, Actual: "", Expected: expected[validator].String()})
+			corrected.Validators = append(corrected.Validators, validator)
+			corrected.Amounts = append(corrected.Amounts, expected[validator].String())
+		}
+		if mismatch {
+			fixes = append(fixes, corrected)
+		}
+	}
+
+	err := s.store.ForEachRecord(&types.DelegatorOutList{}, func(record interface{}) error {
+		if outList, ok := record.(*types.DelegatorOutList); ok {
+			check(outList)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, delegator := range sortedKeys(state.outList) {
+		if !checked[delegator] {
+			check(&types.DelegatorOutList{Delegator: delegator})
+		}
+	}
+	report.Delegators = len(checked)
+	return fixes, nil
+}
+
+// verifyClaimed returns the corrected Claimed24H of every validator that has a mismatch.
+func (s *ChainService) verifyClaimed(state *replayedState, report *VerifyReport) ([]types.DbRecord, error) {
+	var fixes []types.DbRecord
+	checked := map[string]bool{}
+	check := func(claimed *types.Claimed24H) {
+		checked[claimed.Validator] = true
+		want, ok := state.claimed[claimed.Validator]
+		if !ok {
+			want = sdkmath.ZeroInt()
+		}
+		if sameAmount(claimed.Amount, want) {
+			return
+		}
+		report.Mismatches = append(report.Mismatches, VerifyMismatch{Record: claimed.Key(), Validator: claimed.Validator, Actual: claimed.Amount, Expected: want.String()})
+		fixes = append(fixes, &types.Claimed24H{Validator: claimed.Validator, Amount: want.String()})
+	}
+
+	err := s.store.ForEachRecord(&types.Claimed24H{}, func(record interface{}) error {
+		if claimed, ok := record.(*types.Claimed24H); ok {
+			check(claimed)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, validator := range sortedKeys(state.claimed) {
+		if !checked[validator] {
+			check(&types.Claimed24H{Validator: validator})
+		}
+	}
+	report.Validators = len(checked)
+	return fixes, nil
+}
+
+// verifyWithdrawn returns the corrected CommissionWithdrawn of every validator that has a mismatch.
+func (s *ChainService) verifyWithdrawn(state *replayedState, report *VerifyReport) ([]types.DbRecord, error) {
+	var fixes []types.DbRecord
+	checked := map[string]bool{}
+	check := func(withdrawn *types.CommissionWithdrawn) {
+		checked[withdrawn.Validator] = true
+		want, ok := state.withdrawn[withdrawn.Validator]
+		if !ok {
+			want = sdkmath.ZeroInt()
+		}
+		if sameAmount(withdrawn.Amount, want) {
+			return
+		}
+		report.Mismatches = append(report.Mismatches, VerifyMismatch{Record: withdrawn.Key(), Validator: withdrawn.Validator, Actual: withdrawn.Amount, Expected: want.String()})
+		fixes = append(fixes, &types.CommissionWithdrawn{Validator: withdrawn.Validator, Amount: want.String()})
+	}
+
+	err := s.store.ForEachRecord(&types.CommissionWithdrawn{}, func(record interface{}) error {
+		if withdrawn, ok := record.(*types.CommissionWithdrawn); ok {
+			check(withdrawn)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, validator := range sortedKeys(state.withdrawn) {
+		if !checked[validator] {
+			check(&types.CommissionWithdrawn{Validator: validator})
+		}
+	}
+	return fixes, nil
+}
+
+// verifyOnChain compares the replayed stake of every delegator with the chain at the
+// indexed height. The node must still have the state of that height.
+func (s *ChainService) verifyOnChain(state *replayedState, report *VerifyReport) error {
+	for _, delegator := range sortedKeys(state.stake) {
+		balances, err := rpc.GetDelegatorDelegations(s.cl, delegator, s.chain.Height)
+		if err != nil {
+			return fmt.Errorf("failed to query delegations of %s at height %d: %w", delegator, s.chain.Height, err)
+		}
+
+		stake := state.stake[delegator]
+		validators := map[string]bool{}
+		for validator := range stake {
+			validators[validator] = true
+		}
+		for validator := range balances {
+			validators[validator] = true
+		}
+		for _, validator := range sortedKeys(validators) {
+			replayed := amountOrZero(stake, validator)
+			onChain := amountOrZero(balances, validator)
+			if !replayed.Equal(onChain) {
+				report.ChainMismatches = append(report.ChainMismatches, VerifyMismatch{Record: delegator, Validator: validator, Actual: replayed.String(), Expected: onChain.String()})
+			}
+		}
+	}
+	logger.Logger.Infof("Checked the delegations of %d delegators on chain at height %d", len(state.stake), s.chain.Height)
+	return nil
+}
+
+func amountOrZero(amounts map[string]sdkmath.Int, validator string) sdkmath.Int {
+	if amount, ok := amounts[validator]; ok {
+		return amount
+	}
+	return sdkmath.ZeroInt()
+}
+
+// sameAmount treats an empty or unparsable stored amount as zero, as the parsers do.
+func sameAmount(stored string, want sdkmath.Int) bool {
+	amount, ok := sdkmath.NewIntFromString(stored)
+	if !ok {
+		amount = sdkmath.ZeroInt()
+	}
+	return amount.Equal(want)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"fmt"
+	"mtt-indexer/db"
+	"mtt-indexer/types"
+	"testing"
+)
+
+const testOtherValidator = "mttvaloper10wpwl4mqpgdgz8597kphgahx3a8degvg9nd8r6"
+
+func TestVerifyRecomputesDerivedState(t *testing.T) {
+	s := newTestChainService(t)
+	record := func(validator string, delegationType types.DelegationType, amount, txHash string) *types.ValidatorRecord {
+		return &types.ValidatorRecord{Delegator: testDelegator, Validator: validator, Amount: amount, Denom: "amtt", DelegationType: delegationType, TxHash: txHash}
+	}
+	err := s.store.Transaction(func(view db.View) error {
+		history := []*types.ValidatorRecord{
+			record(testValidator, types.Delegate, "100", "tx1"),
+			record(testValidator, types.Claim, "10", "tx2"),
+			record(testValidator, types.Undelegate, "30", "tx3"),
+			// A redelegation stores the source first and then the destination
+			record(testValidator, types.Redelegate, "20", "tx4"),
+			record(testOtherValidator, types.Redelegate, "20", "tx4"),
+		}
+		for _, validatorRecord := range history {
+			if err := view.StoreRecord(validatorRecord); err != nil {
+				return err
+			}
+			if err := view.StoreRecord(validatorRecord.ToDelegate()); err != nil {
+				return err
+			}
+		}
+
+		// Stored state that drifted from the history
+		err := view.StoreRecord(&types.DelegatorOutList{Delegator: testDelegator, Validators: []string{testValidator}, Amounts: []string{"70"}, Denom: "amtt"})
+		if err != nil {
+			return err
+		}
+		return view.StoreRecord(&types.Claimed24H{Validator: testValidator, Amount: "5"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.Verify(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprint([]VerifyMismatch{
+		{Record: "DelegatorOutList_" + testDelegator, Validator: testValidator, Actual: "70", Expected: "40"},
+		{Record: "DelegatorOutList_" + testDelegator, Validator: testOtherValidator, Actual: "", Expected: "20"},
+		{Record: "Claimed24H_" + testValidator, Validator: testValidator, Actual: "5", Expected: "10"},
+	})
+	if got := fmt.Sprint(report.Mismatches); got != want {
+		t.Errorf("got mismatches %s, want %s", got, want)
+	}
+	if report.Fixed != 0 || len(report.Unresolved) != 0 || report.Delegators != 1 || report.Validators != 1 {
+		t.Errorf("got report %+v", report)
+	}
+
+	report, err = s.Verify(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Fixed != 2 {
+		t.Errorf("fixed %d records, want the out list and the claimed total", report.Fixed)
+	}
+	report, err = s.Verify(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Mismatches) != 0 {
+		t.Errorf("got mismatches %+v after fixing", report.Mismatches)
+	}
+	stored, err := s.store.GetRecordByType(&types.DelegatorOutList{Delegator: testDelegator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outList, ok := stored.(*types.DelegatorOutList); !ok || fmt.Sprint(outList.Validators, outList.Amounts) != fmt.Sprint([]string{testValidator, testOtherValidator}, []string{"40", "20"}) {
+		t.Errorf("got corrected out list %+v", stored)
+	}
+}
+
+func TestVerifyReportsUnmatchedRedelegation(t *testing.T) {
+	s := newTestChainService(t)
+	err := s.store.Transaction(func(view db.View) error {
+		return view.StoreRecord(&types.ValidatorRecord{Delegator: testDelegator, Validator: testValidator, Amount: "5", DelegationType: types.Redelegate, TxHash: "tx1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.Verify(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unresolved) != 1 || len(report.Mismatches) != 0 {
+		t.Errorf("got report %+v, want the redelegation unresolved", report)
+	}
+}
+
+func TestVerifyKeepsWithdrawnCommissionApart(t *testing.T) {
+	s := newTestChainService(t)
+	err := s.store.Transaction(func(view db.View) error {
+		err := view.StoreRecord(&types.CommissionWithdrawal{Validator: testValidator, Coins: []types.Coin{{Denom: types.StakeDenom, Amount: "50"}}})
+		if err != nil {
+			return err
+		}
+		// Withdrawn commission used to be counted in Claimed24H
+		return view.StoreRecord(&types.Claimed24H{Validator: testValidator, Amount: "50"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.Verify(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprint([]VerifyMismatch{
+		{Record: "Claimed24H_" + testValidator, Validator: testValidator, Actual: "50", Expected: "0"},
+		{Record: "CommissionWithdrawn_" + testValidator, Validator: testValidator, Actual: "", Expected: "50"},
+	})
+	if got := fmt.Sprint(report.Mismatches); got != want || report.Fixed != 2 {
+		t.Errorf("got mismatches %s with %d fixed, want %s", got, report.Fixed, want)
+	}
+
+	stored, err := s.store.GetRecordByType(&types.CommissionWithdrawn{Validator: testValidator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withdrawn, ok := stored.(*types.CommissionWithdrawn); !ok || withdrawn.Amount != "50" {
+		t.Errorf("got withdrawn commission %+v after fixing", stored)
+	}
+}
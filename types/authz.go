@@ -0,0 +1,46 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+type AuthorizationChangeType uint8
+
+const (
+	AuthorizationGrant AuthorizationChangeType = iota
+	AuthorizationRevoke
+)
+
+// AuthorizationRecord is an entry of a granter's authz history, a grant or a revoke of the
+// authorization of a grantee to execute MsgTypeURL messages for the granter.
+type AuthorizationRecord struct {
+	ID         uint64
+	Granter    string
+	Grantee    string
+	Type       AuthorizationChangeType
+	MsgTypeURL string
+	// Authorization is the type URL of the granted authorization, empty for revokes
+	Authorization string
+	// Expiration is zero for grants without expiration and for revokes
+	Expiration time.Time
+	Height     int64
+	TxHash     string
+	Time       time.Time
+}
+
+func (a *AuthorizationRecord) Key() string {
+	return fmt.Sprintf("AuthorizationRecord_%s_%d", a.Granter, a.ID)
+}
+
+func (a *AuthorizationRecord) Prefix() string {
+	return fmt.Sprintf("AuthorizationRecord_%s", a.Granter)
+}
+
+func (a *AuthorizationRecord) SetId(id uint64) {
+	a.ID = id
+}
+
+func (a *AuthorizationRecord) GetId() uint64 {
+	return a.ID
+}
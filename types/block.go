@@ -1,6 +1,7 @@
 package types
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -8,6 +9,8 @@ type Block struct {
 	ID                    uint
 	TimeStamp             time.Time
 	Height                int64
+	Hash                  string
+	ParentHash            string
 	ChainID               uint
 	Chain                 Chain
 	ProposerConsAddress   Address
@@ -60,16 +63,65 @@ type BlockEventAttributeKey struct {
 	Key string `gorm:"uniqueIndex"`
 }
 
+// BlockEventRecord is a stored BeginBlock or EndBlock event.
+type BlockEventRecord struct {
+	Height            int64
+	Time              time.Time
+	LifecyclePosition BlockLifecyclePosition
+	Index             uint64
+	Type              string
+	Attributes        []BlockEventRecordAttribute
+}
+
+type BlockEventRecordAttribute struct {
+	Key   string
+	Value string
+}
+
+func NewBlockEventRecord(block Block, event BlockEvent, attributes []BlockEventAttribute) *BlockEventRecord {
+	record := &BlockEventRecord{
+		Height:            block.Height,
+		Time:              block.TimeStamp,
+		LifecyclePosition: event.LifecyclePosition,
+		Index:             event.Index,
+		Type:              event.BlockEventType.Type,
+	}
+	for _, attribute := range attributes {
+		record.Attributes = append(record.Attributes, BlockEventRecordAttribute{
+			Key:   attribute.BlockEventAttributeKey.Key,
+			Value: attribute.Value,
+		})
+	}
+	return record
+}
+
+func (b *BlockEventRecord) Key() string {
+	return fmt.Sprintf("BlockEventRecord_%d_%d_%d", b.Height, b.LifecyclePosition, b.Index)
+}
+
+// FailedBlock is a height that could not be fully indexed. It is kept until a retry succeeds,
+// after the max attempts it waits to be requeued. Code holds the latest
+// core.BlockProcessingFailure of it.
 type FailedBlock struct {
-	ID           uint
-	Height       int64 `gorm:"uniqueIndex:failedchainheight"`
-	BlockchainID uint  `gorm:"uniqueIndex:failedchainheight"`
-	Chain        Chain `gorm:"foreignKey:BlockchainID"`
+	Height    int64
+	Code      int
+	Error     string
+	Attempts  int
+	FailedAt  time.Time
+	NextRetry time.Time
+	// Codes are the failures of the latest attempt, a retry re-runs the parts of the block
+	// they cover
+	Codes []int
 }
 
-type FailedEventBlock struct {
-	ID           uint
-	Height       int64 `gorm:"uniqueIndex:failedchaineventheight"`
-	BlockchainID uint  `gorm:"uniqueIndex:failedchaineventheight"`
-	Chain        Chain `gorm:"foreignKey:BlockchainID"`
+func (f *FailedBlock) Key() string {
+	return fmt.Sprintf("FailedBlock_%d", f.Height)
+}
+
+func (f *FailedBlock) Prefix() string {
+	return "FailedBlock_"
+}
+
+func (f *FailedBlock) GetHeight() int64 {
+	return f.Height
 }
@@ -7,6 +7,8 @@ type Chain struct {
 	Rpc     string
 	ChainID string
 	Height  int64
+	// Hash of the block at Height, used to check the parent hash of the next block
+	Hash string
 }
 
 func (c *Chain) Key() string {
@@ -19,5 +21,17 @@ func (c *Chain) Clone() *Chain {
 		Rpc:     c.Rpc,
 		ChainID: c.ChainID,
 		Height:  c.Height,
+		Hash:    c.Hash,
 	}
 }
+
+// BlockHash records the hash of an indexed block and of its parent.
+type BlockHash struct {
+	Height     int64
+	Hash       string
+	ParentHash string
+}
+
+func (b *BlockHash) Key() string {
+	return fmt.Sprintf("BlockHash_%d", b.Height)
+}
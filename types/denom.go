@@ -4,3 +4,6 @@ type Denom struct {
 	ID   uint
 	Base string
 }
+
+// StakeDenom is the denom stakes, rewards and the claimed totals are counted in.
+const StakeDenom = "amtt"
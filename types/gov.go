@@ -0,0 +1,191 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+type ProposalStatus uint8
+
+const (
+	ProposalDepositPeriod ProposalStatus = iota
+	ProposalVotingPeriod
+	ProposalPassed
+	ProposalRejected
+	ProposalFailed
+	ProposalDropped
+)
+
+// Proposal is the latest state of a governance proposal. Proposals submitted before the
+// indexed range only have what later deposits, votes and results told about them.
+type Proposal struct {
+	ProposalID uint64
+	Title      string
+	Summary    string
+	Metadata   string
+	Proposer   string
+	// Messages are the type URLs of the proposal messages, or of the content of v1beta1 proposals
+	Messages          []string
+	Status            ProposalStatus
+	TotalDeposit      []Coin
+	SubmitHeight      int64
+	SubmitTime        time.Time
+	SubmitTxHash      string
+	VotingStartHeight int64
+	VotingStartTime   time.Time
+	EndHeight         int64
+	EndTime           time.Time
+}
+
+func (p *Proposal) Key() string {
+	return fmt.Sprintf("Proposal_%d", p.ProposalID)
+}
+
+func (p *Proposal) Prefix() string {
+	return "Proposal_"
+}
+
+type ProposalEventType uint8
+
+const (
+	ProposalEventSubmit ProposalEventType = iota
+	ProposalEventDeposit
+	ProposalEventVotingStart
+	ProposalEventPassed
+	ProposalEventRejected
+	ProposalEventFailed
+	ProposalEventDropped
+)
+
+// ProposalEvent is an entry of a proposal's timeline. Address and Coins are the proposer or
+// depositor and the deposit, results have neither.
+type ProposalEvent struct {
+	ID         uint64
+	ProposalID uint64
+	Type       ProposalEventType
+	Address    string
+	Coins      []Coin
+	Height     int64
+	TxHash     string
+	Time       time.Time
+}
+
+func (p *ProposalEvent) Key() string {
+	return fmt.Sprintf("ProposalEvent_%d_%d", p.ProposalID, p.ID)
+}
+
+func (p *ProposalEvent) Prefix() string {
+	return fmt.Sprintf("ProposalEvent_%d_", p.ProposalID)
+}
+
+func (p *ProposalEvent) SetId(id uint64) {
+	p.ID = id
+}
+
+func (p *ProposalEvent) GetId() uint64 {
+	return p.ID
+}
+
+// VoteOption is one option of a vote with its weight, "1.000000000000000000" for plain votes.
+type VoteOption struct {
+	Option string
+	Weight string
+}
+
+// ProposalVote is a vote in the vote list of a proposal. A voter can vote again until the
+// voting period ends, only the last vote counts and stays in the list. Validator is the
+// operator address when the voter is the account of a validator.
+type ProposalVote struct {
+	ID         uint64
+	ProposalID uint64
+	Voter      string
+	Validator  string
+	Options    []VoteOption
+	Height     int64
+	TxHash     string
+	Time       time.Time
+}
+
+func (v *ProposalVote) Key() string {
+	return fmt.Sprintf("ProposalVote_%d_%d", v.ProposalID, v.ID)
+}
+
+func (v *ProposalVote) Prefix() string {
+	return fmt.Sprintf("ProposalVote_%d_", v.ProposalID)
+}
+
+func (v *ProposalVote) SetId(id uint64) {
+	v.ID = id
+}
+
+func (v *ProposalVote) GetId() uint64 {
+	return v.ID
+}
+
+func (v *ProposalVote) ToVoter() *VoterVote {
+	vote := VoterVote(*v)
+	vote.ID = 0
+	return &vote
+}
+
+func (v *ProposalVote) ToValidator() *ValidatorVote {
+	vote := ValidatorVote(*v)
+	vote.ID = 0
+	return &vote
+}
+
+// VoterVote is a vote in the voting history of an account, which keeps every vote.
+type VoterVote ProposalVote
+
+func (v *VoterVote) Key() string {
+	return fmt.Sprintf("VoterVote_%s_%d", v.Voter, v.ID)
+}
+
+func (v *VoterVote) Prefix() string {
+	return fmt.Sprintf("VoterVote_%s", v.Voter)
+}
+
+func (v *VoterVote) SetId(id uint64) {
+	v.ID = id
+}
+
+func (v *VoterVote) GetId() uint64 {
+	return v.ID
+}
+
+// ValidatorVote is a validator's vote in the validator vote list of a proposal.
+type ValidatorVote ProposalVote
+
+func (v *ValidatorVote) Key() string {
+	return fmt.Sprintf("ValidatorVote_%d_%d", v.ProposalID, v.ID)
+}
+
+func (v *ValidatorVote) Prefix() string {
+	return fmt.Sprintf("ValidatorVote_%d_", v.ProposalID)
+}
+
+func (v *ValidatorVote) SetId(id uint64) {
+	v.ID = id
+}
+
+func (v *ValidatorVote) GetId() uint64 {
+	return v.ID
+}
+
+// CurrentVote is the vote of a voter that counts on a proposal, overwritten when the voter
+// votes again. VoteID and ValidatorVoteID are the IDs of the vote in the vote lists of the
+// proposal, ValidatorVoteID is 0 when the voter is not a validator.
+type CurrentVote struct {
+	ProposalID      uint64
+	Voter           string
+	VoteID          uint64
+	ValidatorVoteID uint64
+}
+
+func (v *CurrentVote) Key() string {
+	return fmt.Sprintf("CurrentVote_%d_%s", v.ProposalID, v.Voter)
+}
+
+func (v *CurrentVote) Prefix() string {
+	return fmt.Sprintf("CurrentVote_%d_", v.ProposalID)
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+type IncidentType uint8
+
+const (
+	Slash IncidentType = iota
+	Jail
+	Unjail
+)
+
+// ValidatorIncident is an entry of a validator's slashing and jailing history.
+// Validator is the operator address, or the consensus address when it could not be resolved.
+type ValidatorIncident struct {
+	ID           uint64
+	Validator    string
+	ConsAddress  string
+	Type         IncidentType
+	Reason       string
+	SlashedPower string
+	BurnedCoins  string
+	Jailed       bool
+	JailedUntil  time.Time
+	Height       int64
+	TxHash       string
+	Time         time.Time
+}
+
+func (v *ValidatorIncident) Key() string {
+	return fmt.Sprintf("ValidatorIncident_%s_%d", v.Validator, v.ID)
+}
+
+func (v *ValidatorIncident) Prefix() string {
+	return fmt.Sprintf("ValidatorIncident_%s", v.Validator)
+}
+
+func (v *ValidatorIncident) SetId(id uint64) {
+	v.ID = id
+}
+
+func (v *ValidatorIncident) GetId() uint64 {
+	return v.ID
+}
+
+// ValidatorLiveness is the latest missed block counter the chain reported for a validator.
+type ValidatorLiveness struct {
+	Validator        string
+	ConsAddress      string
+	MissedBlocks     int64
+	LastMissedHeight int64
+	LastMissedTime   time.Time
+}
+
+func (v *ValidatorLiveness) Key() string {
+	return fmt.Sprintf("ValidatorLiveness_%s", v.Validator)
+}
+
+// ValidatorConsAddress maps a consensus address, as used in slashing events, to the operator address.
+type ValidatorConsAddress struct {
+	ConsAddress string
+	Operator    string
+}
+
+func (v *ValidatorConsAddress) Key() string {
+	return fmt.Sprintf("ValidatorConsAddress_%s", v.ConsAddress)
+}
+
+func (v *ValidatorConsAddress) Prefix() string {
+	return "ValidatorConsAddress_"
+}
+
+// ValidatorOperator marks an operator address as a validator, it is the reverse of ValidatorConsAddress.
+type ValidatorOperator struct {
+	Operator    string
+	ConsAddress string
+}
+
+func (v *ValidatorOperator) Key() string {
+	return fmt.Sprintf("ValidatorOperator_%s", v.Operator)
+}
+
+func (v *ValidatorOperator) Prefix() string {
+	return "ValidatorOperator_"
+}
@@ -0,0 +1,17 @@
+package types
+
+import "fmt"
+
+// RawBlock keeps what the RPC node returned for a block, gzip compressed, so the block can
+// be re-indexed without fetching it again.
+type RawBlock struct {
+	Height int64
+	// Block is the JSON of the block response, including the raw tx bytes
+	Block []byte
+	// Results is the JSON of the normalized block_results response
+	Results []byte
+}
+
+func (r *RawBlock) Key() string {
+	return fmt.Sprintf("RawBlock_%d", r.Height)
+}
@@ -14,16 +14,34 @@ const (
 	Claim
 	CancelUnbonding
 	Redelegate
+	CompleteUnbonding
+	CompleteRedelegation
 )
 
 type DbRecord interface {
 	Key() string
 }
 
-type DbRecordAutoId interface {
+type DbRecordPrefix interface {
 	DbRecord
 	Prefix() string
+}
+
+// DbRecordAutoId records are stored by the db package under an order-preserving key
+// built from Prefix and GetId. Key returns the legacy text key they were stored under
+// before, which is only used to migrate existing databases.
+type DbRecordAutoId interface {
+	DbRecordPrefix
 	SetId(uint64)
+	GetId() uint64
+}
+
+// DbRecordHeight records are kept once per block height. The db package stores them under
+// the same order-preserving key as auto-ID records, with the height in place of the ID, so
+// they iterate in height order. Key returns the text key the SQL backend uses.
+type DbRecordHeight interface {
+	DbRecordPrefix
+	GetHeight() int64
 }
 
 type Record struct {
@@ -39,6 +57,8 @@ type ValidatorRecord struct {
 	TxHash         string
 	DelegationType DelegationType //true add false rm
 	DelegationTime time.Time
+	// Grantee executed the message for the delegator through authz, empty otherwise
+	Grantee string
 }
 
 type RedelegateRecord struct {
@@ -65,6 +85,10 @@ func (v *ValidatorRecord) SetId(id uint64) {
 	v.ID = id
 }
 
+func (v *ValidatorRecord) GetId() uint64 {
+	return v.ID
+}
+
 func (v *ValidatorRecord) ToDelegate() *DelegatorRecord {
 	return &DelegatorRecord{
 		ID:             0,
@@ -75,6 +99,7 @@ func (v *ValidatorRecord) ToDelegate() *DelegatorRecord {
 		TxHash:         v.TxHash,
 		DelegationType: v.DelegationType,
 		DelegationTime: v.DelegationTime,
+		Grantee:        v.Grantee,
 	}
 }
 
@@ -87,6 +112,8 @@ type DelegatorRecord struct {
 	TxHash         string
 	DelegationType DelegationType //true add false rm
 	DelegationTime time.Time
+	// Grantee executed the message for the delegator through authz, empty otherwise
+	Grantee string
 }
 
 func (v *DelegatorRecord) Key() string {
@@ -101,6 +128,10 @@ func (v *DelegatorRecord) SetId(id uint64) {
 	v.ID = id
 }
 
+func (v *DelegatorRecord) GetId() uint64 {
+	return v.ID
+}
+
 func (v *DelegatorRecord) ToValidator() *ValidatorRecord {
 	return &ValidatorRecord{
 		Delegator:      v.Delegator,
@@ -110,14 +141,32 @@ func (v *DelegatorRecord) ToValidator() *ValidatorRecord {
 		TxHash:         v.TxHash,
 		DelegationType: v.DelegationType,
 		DelegationTime: v.DelegationTime,
+		Grantee:        v.Grantee,
 	}
 }
 
+type CommissionChangeType uint8
+
+const (
+	CommissionCreate CommissionChangeType = iota
+	CommissionEdit
+)
+
+// CommissionRecord is an entry of a validator's commission rate history. Rates are decimal
+// strings as the chain reports them, Commission is Rate as a float.
 type CommissionRecord struct {
 	ID         uint64
 	Validator  string
 	Commission float64
 	Time       time.Time
+	Type       CommissionChangeType
+	Rate       string
+	// PreviousRate is empty when the rate before the change was not indexed
+	PreviousRate  string
+	MaxRate       string
+	MaxChangeRate string
+	Height        int64
+	TxHash        string
 }
 
 func (c *CommissionRecord) Key() string {
@@ -132,6 +181,23 @@ func (c *CommissionRecord) SetId(id uint64) {
 	c.ID = id
 }
 
+func (c *CommissionRecord) GetId() uint64 {
+	return c.ID
+}
+
+// ValidatorCommission is the latest commission rates of a validator.
+type ValidatorCommission struct {
+	Validator     string
+	Rate          string
+	MaxRate       string
+	MaxChangeRate string
+	UpdateTime    time.Time
+}
+
+func (v *ValidatorCommission) Key() string {
+	return fmt.Sprintf("ValidatorCommission_%s", v.Validator)
+}
+
 type DelegatorOutList struct {
 	Delegator  string
 	Validators []string
@@ -1,7 +1,9 @@
 package types
 
 import (
+	sdkmath "cosmossdk.io/math"
 	"fmt"
+	"time"
 )
 
 type RewardRecord struct {
@@ -23,6 +25,10 @@ func (v *RewardRecord) SetId(id uint64) {
 	v.ID = id
 }
 
+func (v *RewardRecord) GetId() uint64 {
+	return v.ID
+}
+
 type Claimed24H struct {
 	Validator string
 	Amount    string
@@ -31,3 +37,59 @@ type Claimed24H struct {
 func (d *Claimed24H) Key() string {
 	return fmt.Sprintf("Claimed24H_%s", d.Validator)
 }
+
+// CommissionWithdrawn is the staking denom commission a validator has withdrawn in total,
+// kept apart from the delegator rewards claimed in Claimed24H.
+type CommissionWithdrawn struct {
+	Validator string
+	Amount    string
+}
+
+func (c *CommissionWithdrawn) Key() string {
+	return fmt.Sprintf("CommissionWithdrawn_%s", c.Validator)
+}
+
+// Coin is the amount of one denom.
+type Coin struct {
+	Denom  string
+	Amount string
+}
+
+// CommissionWithdrawal is a withdrawal of a validator's accumulated commission.
+type CommissionWithdrawal struct {
+	ID        uint64
+	Validator string
+	Coins     []Coin
+	Height    int64
+	TxHash    string
+	Time      time.Time
+}
+
+func (c *CommissionWithdrawal) Key() string {
+	return fmt.Sprintf("CommissionWithdrawal_%s_%d", c.Validator, c.ID)
+}
+
+func (c *CommissionWithdrawal) Prefix() string {
+	return fmt.Sprintf("CommissionWithdrawal_%s", c.Validator)
+}
+
+func (c *CommissionWithdrawal) SetId(id uint64) {
+	c.ID = id
+}
+
+func (c *CommissionWithdrawal) GetId() uint64 {
+	return c.ID
+}
+
+// AmountOf returns the withdrawn amount of denom.
+func (c *CommissionWithdrawal) AmountOf(denom string) sdkmath.Int {
+	for _, coin := range c.Coins {
+		if coin.Denom != denom {
+			continue
+		}
+		if amount, ok := sdkmath.NewIntFromString(coin.Amount); ok {
+			return amount
+		}
+	}
+	return sdkmath.ZeroInt()
+}
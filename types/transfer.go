@@ -0,0 +1,45 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+type TransferDirection uint8
+
+const (
+	TransferIn TransferDirection = iota
+	TransferOut
+)
+
+// TransferRecord is an entry of an account's transfer history. Every transferred denom is
+// stored once under the sender, as TransferOut, and once under the recipient, as TransferIn.
+type TransferRecord struct {
+	ID           uint64
+	Address      string
+	Counterparty string
+	Direction    TransferDirection
+	Amount       string
+	Denom        string
+	// MessageType is the type URL of the message the transfer was part of
+	MessageType string
+	Height      int64
+	TxHash      string
+	Time        time.Time
+}
+
+func (t *TransferRecord) Key() string {
+	return fmt.Sprintf("TransferRecord_%s_%d", t.Address, t.ID)
+}
+
+func (t *TransferRecord) Prefix() string {
+	return fmt.Sprintf("TransferRecord_%s", t.Address)
+}
+
+func (t *TransferRecord) SetId(id uint64) {
+	t.ID = id
+}
+
+func (t *TransferRecord) GetId() uint64 {
+	return t.ID
+}
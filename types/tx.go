@@ -53,7 +53,14 @@ type Message struct {
 	MessageTypeID uint
 	MessageType   MessageType
 	MessageIndex  int
-	MessageBytes  []byte
+	// ExecIndex is the position of a message executed through authz MsgExec among the
+	// messages of each MsgExec around it, outermost first. It is empty for the messages of
+	// the tx body, so together with MessageIndex it tells every message of a tx apart.
+	ExecIndex    []int
+	MessageBytes []byte
+	// Grantee is the account that executed the message through an authz MsgExec, the
+	// message acts for its own signer, like the delegator of a delegation
+	Grantee string
 }
 
 type FailedMessage struct {
@@ -0,0 +1,49 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// UnbondingEntry mirrors an entry of the chain's unbonding queue. Balance starts at
+// InitialBalance and is reduced by MsgCancelUnbondingDelegation. The entry is removed
+// once the chain emits complete_unbonding for it.
+type UnbondingEntry struct {
+	Delegator      string
+	Validator      string
+	CreationHeight int64
+	CompletionTime time.Time
+	InitialBalance string
+	Balance        string
+	Denom          string
+	TxHash         string
+}
+
+func (u *UnbondingEntry) Key() string {
+	return fmt.Sprintf("UnbondingEntry_%s_%s_%d", u.Delegator, u.Validator, u.CreationHeight)
+}
+
+func (u *UnbondingEntry) Prefix() string {
+	return fmt.Sprintf("UnbondingEntry_%s_", u.Delegator)
+}
+
+func (u *UnbondingEntry) ToValidator() *ValidatorUnbondingEntry {
+	entry := ValidatorUnbondingEntry(*u)
+	return &entry
+}
+
+// ValidatorUnbondingEntry is the copy of an UnbondingEntry indexed by validator.
+type ValidatorUnbondingEntry UnbondingEntry
+
+func (u *ValidatorUnbondingEntry) Key() string {
+	return fmt.Sprintf("ValidatorUnbondingEntry_%s_%s_%d", u.Validator, u.Delegator, u.CreationHeight)
+}
+
+func (u *ValidatorUnbondingEntry) Prefix() string {
+	return fmt.Sprintf("ValidatorUnbondingEntry_%s_", u.Validator)
+}
+
+func (u *ValidatorUnbondingEntry) ToDelegator() *UnbondingEntry {
+	entry := UnbondingEntry(*u)
+	return &entry
+}
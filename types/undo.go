@@ -0,0 +1,39 @@
+package types
+
+import "fmt"
+
+// UndoLog holds, for every key written by one commit, the value the key had before the
+// commit. Restoring the entries unwinds the commit.
+// Every commit gets its own log, numbered by ID in commit order, so a block that is
+// indexed again (backfill, failed block retry) adds a log instead of sharing one with
+// the commits made in between. Logs have to be undone newest first.
+type UndoLog struct {
+	Height  int64
+	Entries []UndoEntry
+	ID      uint64
+	// MaxHeight is the highest height committed up to and including this log. It never
+	// decreases with ID, so the logs to undo for a rollback are the newest ones above it.
+	MaxHeight int64
+}
+
+type UndoEntry struct {
+	Key []byte
+	// Value is nil when the key did not exist before the commit
+	Value []byte
+}
+
+func (u *UndoLog) Key() string {
+	return fmt.Sprintf("UndoLog__%d", u.ID)
+}
+
+func (u *UndoLog) Prefix() string {
+	return "UndoLog_"
+}
+
+func (u *UndoLog) SetId(id uint64) {
+	u.ID = id
+}
+
+func (u *UndoLog) GetId() uint64 {
+	return u.ID
+}